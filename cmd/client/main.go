@@ -71,6 +71,7 @@ func createTusUpload(fileSize int64, fileName string) (string, error) {
 	req.Header.Set("Upload-Metadata", encodeMetadata(map[string]string{
 		"filename": fileName,
 	}))
+	setAuthorization(req)
 
 	// Отправляем запрос
 	resp, err := client.Do(req)
@@ -126,6 +127,7 @@ func uploadFileChunks(uploadID string, file *os.File) error {
 		req.Header.Set("Upload-Offset", fmt.Sprintf("%d", offset))
 		req.Header.Set("Content-Type", "application/offset+octet-stream")
 		req.Header.Set("Upload-Checksum", fmt.Sprintf("md5 %s", checksum))
+		setAuthorization(req)
 
 		// Отправляем запрос
 		var resp *http.Response
@@ -175,3 +177,10 @@ func generateChecksum(data []byte) string {
 	hash := md5.Sum(data)
 	return base64.StdEncoding.EncodeToString(hash[:])
 }
+
+// Добавляет токен из переменной окружения GORYNYCH_TOKEN
+func setAuthorization(req *http.Request) {
+	if token := os.Getenv("GORYNYCH_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
@@ -0,0 +1,74 @@
+// Command rotate-keys re-encrypts the keys of all accounts with the current master key.
+// Move the old key to secrets.previous, configure the new one as secrets.key, run the
+// command, then the old key can be removed from the configuration unless objects are
+// encrypted with it. The data keys of objects stay wrapped with the master key current
+// at upload, so their master keys must be kept; the command lists them.
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/inview-team/gorynych/config"
+	"github.com/inview-team/gorynych/internal/infrastructure/mongo"
+	"github.com/inview-team/gorynych/internal/infrastructure/secrets"
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	configPath := os.Getenv("SERVICE_CONFIG_PATH")
+	if configPath == "" {
+		configPath = "./config.yaml"
+	}
+
+	cfg, err := config.LoadFile(configPath)
+	if err != nil {
+		log.Errorf(err.Error())
+		os.Exit(1)
+	}
+
+	ctx := context.TODO()
+
+	keyring, err := secrets.NewKeyring(cfg.Secrets)
+	if err != nil {
+		log.Errorf("failed to load master keys: %v", err.Error())
+		os.Exit(1)
+	}
+
+	client, err := mongo.NewClient(ctx, cfg.Database)
+	if err != nil {
+		log.Errorf("failed to init database: %v", err.Error())
+		os.Exit(1)
+	}
+
+	aRepo := mongo.NewAccountRepository(client, keyring)
+	err = aRepo.CheckKeys(ctx)
+	if err != nil {
+		log.Errorf(err.Error())
+		os.Exit(1)
+	}
+	uRepo := mongo.NewUploadRepository(client)
+	err = uRepo.CheckKeys(ctx, keyring)
+	if err != nil {
+		log.Errorf(err.Error())
+		os.Exit(1)
+	}
+
+	count, err := aRepo.Rotate(ctx)
+	if err != nil {
+		log.Errorf("failed to rotate keys after %d accounts: %v", count, err.Error())
+		os.Exit(1)
+	}
+	log.Infof("re-encrypted %d accounts with master key %s", count, cfg.Secrets.Key.ID)
+
+	keyIDs, err := uRepo.EncryptionKeyIDs(ctx)
+	if err != nil {
+		log.Errorf("failed to list master keys of encrypted objects: %v", err.Error())
+		os.Exit(1)
+	}
+	for _, keyID := range keyIDs {
+		if keyID != cfg.Secrets.Key.ID {
+			log.Warnf("objects are encrypted with master key %s, keep it in secrets.previous", keyID)
+		}
+	}
+}
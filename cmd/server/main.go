@@ -7,6 +7,7 @@ import (
 	"github.com/inview-team/gorynych/config"
 	"github.com/inview-team/gorynych/internal/application"
 	server "github.com/inview-team/gorynych/internal/infrastructure/http"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/middleware"
 	"github.com/inview-team/gorynych/internal/infrastructure/mongo"
 	log "github.com/sirupsen/logrus"
 )
@@ -25,6 +26,12 @@ func main() {
 
 	log.Info(cfg)
 
+	auth, err := middleware.NewAuthenticator(cfg.Auth)
+	if err != nil {
+		log.Errorf("failed to init authentication: %v", err.Error())
+		os.Exit(1)
+	}
+
 	ctx := context.TODO()
 
 	client, err := mongo.NewClient(ctx, cfg.Database)
@@ -33,12 +40,16 @@ func main() {
 		os.Exit(1)
 	}
 
-	app, err := application.New(ctx, client)
+	app, err := application.New(ctx, client, cfg)
 	if err != nil {
 		log.Errorf("failed to init application: %v", err.Error())
 		os.Exit(1)
 	}
 
-	srv := server.NewServer(app)
+	srv, err := server.NewServer(app, auth, client, cfg.Server)
+	if err != nil {
+		log.Errorf("failed to init server: %v", err.Error())
+		os.Exit(1)
+	}
 	srv.Start(ctx)
 }
@@ -4,17 +4,41 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/inview-team/gorynych/internal/domain/service"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/listener"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/middleware"
 	"github.com/inview-team/gorynych/internal/infrastructure/mongo"
+	"github.com/inview-team/gorynych/internal/infrastructure/secrets"
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	Database mongo.Config `yaml:"database,omitempty"`
+	Server    listener.Config          `yaml:"server,omitempty"`
+	Database  mongo.Config             `yaml:"database,omitempty"`
+	Bandwidth service.BandwidthConfig  `yaml:"bandwidth,omitempty"`
+	Scheduler service.SchedulerConfig  `yaml:"scheduler,omitempty"`
+	Uploads   service.UploadConfig     `yaml:"uploads,omitempty"`
+	Placement service.PlacementConfig  `yaml:"placement,omitempty"`
+	Quotas    service.QuotasConfig     `yaml:"quotas,omitempty"`
+	Providers []service.ProviderConfig `yaml:"providers,omitempty"`
+	Secrets   secrets.Config           `yaml:"secrets,omitempty"`
+	Auth      middleware.AuthConfig    `yaml:"auth,omitempty"`
+	Audit     service.AuditConfig      `yaml:"audit,omitempty"`
 }
 
 var (
 	DefaultConfig Config = Config{
-		Database: mongo.DefaultConfig,
+		Server:    listener.DefaultConfig,
+		Database:  mongo.DefaultConfig,
+		Bandwidth: service.DefaultBandwidthConfig,
+		Scheduler: service.DefaultSchedulerConfig,
+		Uploads:   service.DefaultUploadConfig,
+		Placement: service.DefaultPlacementConfig,
+		Quotas:    service.DefaultQuotasConfig,
+		Providers: service.DefaultProviderConfigs,
+		Secrets:   secrets.DefaultConfig,
+		Auth:      middleware.DefaultAuthConfig,
+		Audit:     service.DefaultAuditConfig,
 	}
 )
 
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/infrastructure/http/listener"
+)
+
+func TestLoadServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    listener.Config
+		wantErr bool
+	}{
+		{name: "defaults", want: listener.DefaultConfig},
+		{
+			name: "listener settings",
+			yaml: `
+server:
+  address: ":8443"
+  max_header_bytes: 4096
+  max_body_bytes: 1048576
+  timeout:
+    write: 2m
+  tls:
+    cert_file: /etc/gorynych/tls.crt
+    key_file: /etc/gorynych/tls.key
+    client_ca_file: /etc/gorynych/ca.crt
+    client_auth: verify_if_given
+  admin:
+    address: "127.0.0.1:9090"
+    profiling: true
+`,
+			want: listener.Config{
+				Address:        ":8443",
+				MaxHeaderBytes: 4096,
+				MaxBodyBytes:   1 << 20,
+				// Timeouts which are not set keep their default
+				Timeout: listener.TimeoutConfig{
+					Idle:       listener.DefaultConfig.Timeout.Idle,
+					Read:       listener.DefaultConfig.Timeout.Read,
+					ReadHeader: listener.DefaultConfig.Timeout.ReadHeader,
+					Write:      2 * time.Minute,
+					Shutdown:   listener.DefaultConfig.Timeout.Shutdown,
+				},
+				TLS: listener.TLSConfig{
+					CertFile:       "/etc/gorynych/tls.crt",
+					KeyFile:        "/etc/gorynych/tls.key",
+					ClientCAFile:   "/etc/gorynych/ca.crt",
+					ClientAuth:     "verify_if_given",
+					ReloadInterval: listener.DefaultConfig.TLS.ReloadInterval,
+				},
+				Admin: listener.AdminConfig{Address: "127.0.0.1:9090", Profiling: true},
+			},
+		},
+		{name: "invalid timeout", yaml: "server:\n  timeout:\n    read: soon\n", wantErr: true},
+		{name: "invalid size", yaml: "server:\n  max_body_bytes: big\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(tt.yaml)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(cfg.Server, tt.want) {
+				t.Fatalf("Load() server = %+v, want %+v", cfg.Server, tt.want)
+			}
+		})
+	}
+}
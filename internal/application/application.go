@@ -3,29 +3,85 @@ package application
 import (
 	"context"
 
+	"github.com/inview-team/gorynych/config"
+	"github.com/inview-team/gorynych/internal/domain/entity"
 	"github.com/inview-team/gorynych/internal/domain/service"
+	"github.com/inview-team/gorynych/internal/infrastructure/auditlog"
 	"github.com/inview-team/gorynych/internal/infrastructure/mongo"
+	"github.com/inview-team/gorynych/internal/infrastructure/secrets"
 )
 
 type Application struct {
-	UploadService  *service.UploadService
-	AccountService *service.AccountService
-	TaskService    *service.TaskService
+	UploadService    *service.UploadService
+	AccountService   *service.AccountService
+	ProviderService  *service.ProviderService
+	TaskService      *service.TaskService
+	SchedulerService *service.SchedulerService
+	PolicyService    *service.PolicyService
+	BandwidthLimiter *service.BandwidthLimiter
+	AuditService     *service.AuditService
 }
 
-func New(ctx context.Context, client *mongo.Client) (*Application, error) {
-	aRepo := mongo.NewAccountRepository(client)
+func New(ctx context.Context, client *mongo.Client, cfg *config.Config) (*Application, error) {
+	keyring, err := secrets.NewKeyring(cfg.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	aRepo := mongo.NewAccountRepository(client, keyring)
+	// Accounts encrypted with a missing key could not be used
+	err = aRepo.CheckKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
 	uRepo := mongo.NewUploadRepository(client)
-	pRepo, err := mongo.NewProviderRepository(ctx, client)
+	// Objects encrypted with a missing key could not be read
+	err = uRepo.CheckKeys(ctx, keyring)
+	if err != nil {
+		return nil, err
+	}
+	err = uRepo.EnsureIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pRepo := mongo.NewProviderRepository(client)
 	tRepo := mongo.NewTaskRepository(client)
+	sRepo := mongo.NewScheduleRepository(client)
+	polRepo := mongo.NewPolicyRepository(client)
+	pdRepo := mongo.NewPendingDeletionRepository(client)
+	var sinks []entity.AuditSink
+	if cfg.Audit.File != "" {
+		fileSink, err := auditlog.NewFileSink(cfg.Audit.File)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, fileSink)
+	}
+	auditService := service.NewAuditService(mongo.NewAuditRepository(client), sinks...)
+	providerService := service.NewProviderService(pRepo, aRepo)
+	err = providerService.Seed(ctx, cfg.Providers)
+	if err != nil {
+		return nil, err
+	}
+	placement, err := service.NewPlacement(cfg.Placement, uRepo)
 	if err != nil {
 		return nil, err
 	}
-	taskService := service.NewTaskService(aRepo, pRepo, tRepo, 5)
+	limiter := service.NewBandwidthLimiter(cfg.Bandwidth)
+	taskService := service.NewTaskService(aRepo, pRepo, tRepo, pdRepo, limiter, auditService, 5)
 	taskService.Start(ctx)
+	schedulerService := service.NewSchedulerService(sRepo, tRepo, taskService, cfg.Scheduler)
+	schedulerService.Start(ctx)
+	policyService := service.NewPolicyService(polRepo, taskService)
+	uploadService := service.NewUploadService(uRepo, aRepo, pRepo, limiter, policyService, placement, service.NewQuotas(cfg.Quotas, uRepo), auditService, keyring, cfg.Uploads)
+	uploadService.Start(ctx)
 	return &Application{
-		service.NewUploadService(uRepo, aRepo, pRepo),
-		service.NewAccountService(aRepo),
-		taskService,
+		UploadService:    uploadService,
+		AccountService:   service.NewAccountService(aRepo, pRepo, uRepo, taskService, auditService),
+		ProviderService:  providerService,
+		TaskService:      taskService,
+		SchedulerService: schedulerService,
+		PolicyService:    policyService,
+		BandwidthLimiter: limiter,
+		AuditService:     auditService,
 	}, nil
 }
@@ -5,22 +5,82 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"io"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type ServiceAccount struct {
 	ID         string
+	TenantID   string
 	ProviderID string
 	Region     string
 	AccessKey  string
 	Secret     string
+	// Disabled accounts are kept but not used for uploads and replication.
+	Disabled bool
+	// Buckets the account could access when it was last verified.
+	Buckets    []BucketAccess
+	VerifiedAt time.Time
+}
+
+// BucketAccess records what an account may do in a bucket.
+type BucketAccess struct {
+	Bucket string
+	Read   bool
+	Write  bool
+}
+
+// CanWrite reports whether the account may write to the bucket. Buckets of accounts
+// which were never verified are assumed to be writable.
+func (a *ServiceAccount) CanWrite(bucket string) bool {
+	if a.VerifiedAt.IsZero() {
+		return true
+	}
+	for _, access := range a.Buckets {
+		if access.Bucket == bucket {
+			return access.Write
+		}
+	}
+	return false
+}
+
+// BucketNames returns the buckets recorded by the last verification.
+func (a *ServiceAccount) BucketNames() []string {
+	names := make([]string, 0, len(a.Buckets))
+	for _, access := range a.Buckets {
+		names = append(names, access.Bucket)
+	}
+	return names
 }
 
 type Provider struct {
 	ID       string
 	Name     string
 	Endpoint string
+	// Region is used by accounts which do not set their own.
+	Region string
+	// PathStyle addresses buckets as endpoint/bucket instead of bucket.endpoint.
+	PathStyle bool
+	// SkipTLSVerify disables verification of the endpoint certificate.
+	SkipTLSVerify    bool
+	SignatureVersion SignatureVersion
+	// Encryption is the server side encryption of objects written to the provider
+	// when the request does not ask for one. SSE-C is not allowed as keys aren't stored.
+	Encryption *ServerSideEncryption
+}
+
+type SignatureVersion string
+
+const (
+	SignatureV4 SignatureVersion = "v4"
+	// SignatureV4UnsignedPayload signs requests with SigV4 but leaves the payload
+	// unsigned, as some S3 compatible storages require.
+	SignatureV4UnsignedPayload SignatureVersion = "v4-unsigned-payload"
+)
+
+func (v SignatureVersion) Valid() bool {
+	return v == SignatureV4 || v == SignatureV4UnsignedPayload
 }
 
 func NewAccountID() string {
@@ -44,20 +104,35 @@ func NewServiceAccount(id string, providerID string, region string, keyId string
 
 func NewProvider(id string, name string, endpoint string) *Provider {
 	return &Provider{
-		ID:       id,
-		Name:     name,
-		Endpoint: endpoint,
+		ID:               id,
+		Name:             name,
+		Endpoint:         endpoint,
+		SignatureVersion: SignatureV4,
 	}
 }
 
+func NewProviderID() string {
+	id := make([]byte, 16)
+	_, err := io.ReadFull(rand.Reader, id)
+	if err != nil {
+		log.Error("failed to generate id")
+	}
+	return hex.EncodeToString(id)
+}
+
 type AccountRepository interface {
 	Add(ctx context.Context, account *ServiceAccount) error
 	GetByID(ctx context.Context, accountID string) (*ServiceAccount, error)
 	ListByProvider(ctx context.Context, provider string) ([]*ServiceAccount, error)
 	List(ctx context.Context) ([]*ServiceAccount, error)
+	Update(ctx context.Context, account *ServiceAccount) error
+	Delete(ctx context.Context, accountID string) error
 }
 
 type ProviderRepository interface {
+	Add(ctx context.Context, provider *Provider) error
 	GetByID(ctx context.Context, providerID string) (*Provider, error)
 	List(ctx context.Context) ([]*Provider, error)
+	Update(ctx context.Context, provider *Provider) error
+	Delete(ctx context.Context, providerID string) error
 }
@@ -0,0 +1,107 @@
+package entity
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Audited actions
+const (
+	AuditAccountAdd     = "account.add"
+	AuditAccountUpdate  = "account.update"
+	AuditAccountVerify  = "account.verify"
+	AuditAccountDisable = "account.disable"
+	AuditAccountEnable  = "account.enable"
+	AuditAccountDelete  = "account.delete"
+
+	AuditTaskReplicate = "task.replicate"
+	AuditTaskSync      = "task.sync"
+	AuditTaskMirror    = "task.mirror"
+	AuditTaskMove      = "task.move"
+	AuditTaskBandwidth = "task.bandwidth"
+
+	AuditUploadCreate   = "upload.create"
+	AuditUploadComplete = "upload.complete"
+	AuditUploadFail     = "upload.fail"
+	AuditUploadExpire   = "upload.expire"
+
+	AuditUploadTokenMint = "upload_token.mint"
+)
+
+// AuditSystemActor is the actor of calls made by the service itself, like scheduled tasks.
+const AuditSystemActor = "system"
+
+type AuditEvent struct {
+	ID       string
+	TenantID string
+	Time     time.Time
+	Actor    string
+	Action   string
+	// Target is the resource acted on, like account/<id>.
+	Target    string
+	RequestID string
+	SourceIP  string
+	Outcome   AuditOutcome
+	Error     string
+	Details   map[string]string
+}
+
+type AuditOutcome int
+
+const (
+	AuditSuccess AuditOutcome = iota + 1
+	AuditFailure
+)
+
+func NewAuditEventID() string {
+	id := make([]byte, 16)
+	_, err := io.ReadFull(rand.Reader, id)
+	if err != nil {
+		log.Error("failed to generate id")
+	}
+	return hex.EncodeToString(id)
+}
+
+// AuditFilter selects audit events. Empty fields match every event.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// AuditSink receives audit events. Sinks only ever append.
+type AuditSink interface {
+	Add(ctx context.Context, event *AuditEvent) error
+}
+
+type AuditRepository interface {
+	AuditSink
+	// List returns the matching events, newest first.
+	List(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error)
+}
+
+// RequestInfo describes the request a call is made for.
+type RequestInfo struct {
+	ID       string
+	SourceIP string
+}
+
+type requestInfoKey struct{}
+
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the request of the call, empty for background work.
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
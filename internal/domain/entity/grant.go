@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrGrantUsed is returned by upload repositories adding a second upload of a grant.
+var ErrGrantUsed = errors.New("upload grant is already used")
+
+// UploadGrant is what an upload token lets its holder do: create one upload, no larger
+// than MaxSize, and write it until the token expires.
+type UploadGrant struct {
+	// ID is the id of the token. The upload created with it records it.
+	ID      string
+	MaxSize int64
+	// Storage pins the upload to a storage. Nil leaves the choice to the placement.
+	Storage *Storage
+	// Metadata is set on the upload, over the metadata of the client.
+	Metadata  map[string]string
+	ExpiresAt time.Time
+}
+
+type uploadGrantKey struct{}
+
+// WithUploadGrant restricts the caller of the context to the grant of its upload token.
+func WithUploadGrant(ctx context.Context, grant *UploadGrant) context.Context {
+	return context.WithValue(ctx, uploadGrantKey{}, grant)
+}
+
+// UploadGrantFromContext returns the grant of the caller, or nil if the caller didn't
+// use an upload token.
+func UploadGrantFromContext(ctx context.Context) *UploadGrant {
+	grant, _ := ctx.Value(uploadGrantKey{}).(*UploadGrant)
+	return grant
+}
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PendingDeletionTag marks a moved source object which is kept for a while.
+// Its value is the time the object is deleted at. Removing the tag cancels the deletion.
+const PendingDeletionTag = "gorynych-pending-deletion"
+
+type MoveOptions struct {
+	// ObjectID moves a single object. Otherwise every object selected by Sync is moved.
+	ObjectID string
+	Sync     SyncOptions
+	// KeepSourceDays keeps moved source objects tagged as pending deletion instead of
+	// deleting them right away.
+	KeepSourceDays int
+}
+
+// MoveItem is an object selected by a move, as reported by a dry run.
+type MoveItem struct {
+	SourceKey    string
+	TargetKey    string
+	Size         int64
+	TargetExists bool
+}
+
+// PendingDeletion is a moved source object to delete once DeleteAt has passed.
+type PendingDeletion struct {
+	ID       string
+	TaskID   string
+	Storage  Storage
+	ObjectID string
+	DeleteAt time.Time
+}
+
+func NewPendingDeletionID() string {
+	id := make([]byte, 16)
+	_, err := io.ReadFull(rand.Reader, id)
+	if err != nil {
+		fmt.Print("failed to generate id")
+	}
+	return hex.EncodeToString(id)
+}
+
+type PendingDeletionRepository interface {
+	Add(ctx context.Context, deletion *PendingDeletion) error
+	ListDue(ctx context.Context, now time.Time) ([]*PendingDeletion, error)
+	Delete(ctx context.Context, deletionID string) error
+}
@@ -4,15 +4,116 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 )
 
 type Object struct {
-	ID       ObjectID
-	Name     string
-	Size     int64
-	Metadata map[string]string
+	ID           ObjectID
+	Name         string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	ObjectAttributes
+}
+
+// MetadataSourceETag is the metadata key of a copy holding the ETag of its source. ETags
+// of objects written with a different part layout never match, so copies are compared
+// with their source through it.
+const MetadataSourceETag = "gorynych-source-etag"
+
+// ObjectChanged reports whether the target copy is missing or differs from the source.
+// Copies which do not tell the ETag of their source, as in listings, are also considered
+// current when they were written after the last change of the source.
+func ObjectChanged(source, target *Object) bool {
+	if target == nil {
+		return true
+	}
+	if SameObject(source, target) {
+		return false
+	}
+	return source.Size != target.Size || source.LastModified.After(target.LastModified)
+}
+
+// SameObject reports whether the target is the same object as the source: either the
+// same content written the same way, or a copy of the current version of the source.
+func SameObject(source, target *Object) bool {
+	if source.Size != target.Size || source.ETag == "" {
+		return false
+	}
+	return source.ETag == target.ETag || lookupMetadata(target.Metadata, MetadataSourceETag) == source.ETag
+}
+
+// WithSourceETag returns the metadata of a copy of an object with the given ETag.
+func WithSourceETag(metadata map[string]string, etag string) map[string]string {
+	copied := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		if !strings.EqualFold(k, MetadataSourceETag) {
+			copied[k] = v
+		}
+	}
+	if etag != "" {
+		copied[MetadataSourceETag] = etag
+	}
+	return copied
+}
+
+// lookupMetadata returns the value of a metadata key, which providers may return in any case.
+func lookupMetadata(metadata map[string]string, key string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// ObjectAttributes are the system metadata, user metadata and tags stored with an object.
+type ObjectAttributes struct {
+	ContentType        string
+	CacheControl       string
+	ContentEncoding    string
+	ContentDisposition string
+	ContentLanguage    string
+	Metadata           map[string]string
+	Tags               map[string]string
+}
+
+// Merge returns a copy of the attributes with non-empty values of override applied on top.
+func (a ObjectAttributes) Merge(override ObjectAttributes) ObjectAttributes {
+	return ObjectAttributes{
+		ContentType:        mergeValue(a.ContentType, override.ContentType),
+		CacheControl:       mergeValue(a.CacheControl, override.CacheControl),
+		ContentEncoding:    mergeValue(a.ContentEncoding, override.ContentEncoding),
+		ContentDisposition: mergeValue(a.ContentDisposition, override.ContentDisposition),
+		ContentLanguage:    mergeValue(a.ContentLanguage, override.ContentLanguage),
+		Metadata:           mergeMap(a.Metadata, override.Metadata),
+		Tags:               mergeMap(a.Tags, override.Tags),
+	}
+}
+
+func mergeValue(value, override string) string {
+	if override != "" {
+		return override
+	}
+	return value
+}
+
+func mergeMap(values, override map[string]string) map[string]string {
+	if len(values) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(values)+len(override))
+	for key, value := range values {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
 }
 
 type ObjectID string
@@ -26,14 +127,25 @@ func NewObjectID() string {
 	return hex.EncodeToString(id)
 }
 
+// ErrAccessDenied is returned by object repositories when the storage refuses the credentials.
+var ErrAccessDenied = errors.New("access denied")
+
+// ObjectRepository stores objects in a storage. Methods taking a server side encryption
+// write objects with it, or read objects written with SSE-C; nil means none.
 type ObjectRepository interface {
-	Create(ctx context.Context, bucket string, id string, metadata map[string]string) (string, error)
-	WritePart(ctx context.Context, bucket string, uploadID string, objectID string, position int, data *[]byte) (string, error)
+	Create(ctx context.Context, bucket string, id string, attrs ObjectAttributes, sse *ServerSideEncryption) (string, error)
+	WritePart(ctx context.Context, bucket string, uploadID string, objectID string, position int, data *[]byte, sse *ServerSideEncryption) (string, error)
 	FinishUpload(ctx context.Context, bucket, uploadID, objectID string, parts []UploadPart) error
+	AbortUpload(ctx context.Context, bucket, uploadID, objectID string) error
 	ListBuckets(ctx context.Context) ([]string, error)
 	IsBucketExist(ctx context.Context, bucket string) (bool, error)
-	GetObject(ctx context.Context, bucket string, objectID string) (*Object, error)
-	DownloadObject(ctx context.Context, bucket string, objectID string, startOffset int64, endOffset int64) (*[]byte, error)
-	StreamWritePart(ctx context.Context, bucket string, uploadID string, objectID string, position int, data io.ReadCloser) (string, error)
-	StreamDownloadObject(ctx context.Context, bucket string, objectID string, startOffset int64, endOffset int64) (io.ReadCloser, error)
+	// ProbeAccess checks whether objects of the bucket can be listed and written.
+	ProbeAccess(ctx context.Context, bucket string) (BucketAccess, error)
+	GetObject(ctx context.Context, bucket string, objectID string, sse *ServerSideEncryption) (*Object, error)
+	ListObjects(ctx context.Context, bucket string, prefix string) ([]*Object, error)
+	DeleteObject(ctx context.Context, bucket string, objectID string) error
+	SetTags(ctx context.Context, bucket string, objectID string, tags map[string]string) error
+	DownloadObject(ctx context.Context, bucket string, objectID string, startOffset int64, endOffset int64, sse *ServerSideEncryption) (*[]byte, error)
+	StreamWritePart(ctx context.Context, bucket string, uploadID string, objectID string, position int, data io.ReadCloser, size int64, sse *ServerSideEncryption) (string, error)
+	StreamDownloadObject(ctx context.Context, bucket string, objectID string, startOffset int64, endOffset int64, sse *ServerSideEncryption) (io.ReadCloser, error)
 }
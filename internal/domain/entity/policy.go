@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// ReplicationPolicy replicates every completed upload matching the policy to the targets.
+type ReplicationPolicy struct {
+	ID       string
+	TenantID string
+	Name     string
+	Enabled  bool
+	// Source matches the upload storage. An empty bucket matches any bucket of the provider.
+	Source  Storage
+	Targets []Storage
+	// Metadata lists required upload metadata. An empty value matches any value of the key.
+	Metadata map[string]string
+	MinSize  int64
+	// MaxSize of zero means no upper limit.
+	MaxSize int64
+}
+
+func NewPolicyID() string {
+	id := make([]byte, 16)
+	_, err := io.ReadFull(rand.Reader, id)
+	if err != nil {
+		fmt.Print("failed to generate id")
+	}
+	return hex.EncodeToString(id)
+}
+
+func (p *ReplicationPolicy) Matches(upload *Upload) bool {
+	if !p.Enabled {
+		return false
+	}
+
+	if p.Source.ProviderID != upload.Storage.ProviderID {
+		return false
+	}
+
+	if p.Source.Bucket != "" && p.Source.Bucket != upload.Storage.Bucket {
+		return false
+	}
+
+	if upload.Size < p.MinSize || (p.MaxSize > 0 && upload.Size > p.MaxSize) {
+		return false
+	}
+
+	for key, expected := range p.Metadata {
+		value, exists := upload.Metadata[key]
+		if !exists || (expected != "" && value != expected) {
+			return false
+		}
+	}
+	return true
+}
+
+type PolicyRepository interface {
+	Add(ctx context.Context, policy *ReplicationPolicy) error
+	GetByID(ctx context.Context, policyID string) (*ReplicationPolicy, error)
+	List(ctx context.Context) ([]*ReplicationPolicy, error)
+	Update(ctx context.Context, policy *ReplicationPolicy) error
+	Delete(ctx context.Context, policyID string) error
+}
@@ -5,28 +5,94 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 )
 
 type ReplicationTask struct {
-	ID            string
-	ObjectID      string
-	SourceStorage Storage
-	TargetStorage Storage
+	ID             string
+	TenantID       string
+	ParentID       string
+	ObjectID       string
+	SourceStorage  Storage
+	TargetStorages []Storage
+	Options        ReplicationOptions
+}
+
+type ReplicationOptions struct {
+	// MaxBandwidth limits the task throughput in bytes per second. Zero means unlimited.
+	MaxBandwidth int64
+	// MetadataDirective tells whether the source attributes are copied to the target.
+	MetadataDirective MetadataDirective
+	// Attributes are added to or override the attributes of the target object.
+	Attributes ObjectAttributes
+	// TargetKey is the key of the object in the target bucket. Takes precedence over PrefixRewrite.
+	TargetKey string
+	// PrefixRewrite replaces the leading part of the source key on the target.
+	PrefixRewrite *PrefixRewrite
+	// ConflictPolicy decides what happens when the target key already exists.
+	ConflictPolicy ConflictPolicy
+	// SourceEncryption holds the SSE-C key of the source object.
+	SourceEncryption *ServerSideEncryption
+	// TargetEncryption is the server side encryption of the copies. Nil means the
+	// default of the target provider.
+	TargetEncryption *ServerSideEncryption
+}
+
+type PrefixRewrite struct {
+	From string
+	To   string
+}
+
+type ConflictPolicy int
+
+const (
+	ConflictOverwrite ConflictPolicy = iota + 1
+	ConflictSkipIfExists
+	ConflictSkipIfSame
+	ConflictFail
+)
+
+// TargetObjectID returns the key the object is written to in the target bucket.
+func (o ReplicationOptions) TargetObjectID(objectID string) string {
+	if o.TargetKey != "" {
+		return o.TargetKey
+	}
+	if o.PrefixRewrite != nil && strings.HasPrefix(objectID, o.PrefixRewrite.From) {
+		return o.PrefixRewrite.To + strings.TrimPrefix(objectID, o.PrefixRewrite.From)
+	}
+	return objectID
+}
+
+type MetadataDirective int
+
+const (
+	MetadataCopy MetadataDirective = iota + 1
+	MetadataReplace
+)
+
+// TargetAttributes returns attributes of the replicated object. Source attributes are
+// copied unless the directive is MetadataReplace.
+func (o ReplicationOptions) TargetAttributes(source ObjectAttributes) ObjectAttributes {
+	if o.MetadataDirective == MetadataReplace {
+		return ObjectAttributes{}.Merge(o.Attributes)
+	}
+	return source.Merge(o.Attributes)
 }
 
 type ReplicationResult struct {
-	ID    string
-	Start time.Time
-	End   time.Time
-	Error error
+	ID      string
+	Start   time.Time
+	End     time.Time
+	Targets []TargetStatus
+	Error   error
 }
 
-func NewReplicationTask(objectID string, sStorage Storage, tStorage Storage) *ReplicationTask {
+func NewReplicationTask(objectID string, sStorage Storage, tStorages []Storage) *ReplicationTask {
 	return &ReplicationTask{
-		ObjectID:      objectID,
-		SourceStorage: sStorage,
-		TargetStorage: tStorage,
+		ObjectID:       objectID,
+		SourceStorage:  sStorage,
+		TargetStorages: tStorages,
 	}
 }
 
@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+)
+
+type Schedule struct {
+	ID            string
+	TenantID      string
+	Cron          string
+	Mode          ScheduleMode
+	SourceStorage Storage
+	TargetStorage Storage
+	// ObjectID is the replicated object in ScheduleReplication mode.
+	ObjectID string
+	// Options are given to the started tasks. Replication runs only use Options.Replication,
+	// and only mirror runs propagate deletions.
+	Options    SyncOptions
+	LastRun    time.Time
+	NextRun    time.Time
+	LastTaskID string
+	History    []ScheduleRun
+}
+
+// ScheduleRun is the outcome of a single run. Status follows the status of the
+// started task; runs skipped because the previous one is still in progress have
+// TaskSkipped status and no task.
+type ScheduleRun struct {
+	Time   time.Time
+	TaskID string
+	Status TaskStatus
+	Error  string
+}
+
+type ScheduleMode int
+
+const (
+	ScheduleReplication ScheduleMode = iota + 1
+	ScheduleSync
+	ScheduleMirror
+)
+
+func NewScheduleID() string {
+	id := make([]byte, 16)
+	_, err := io.ReadFull(rand.Reader, id)
+	if err != nil {
+		fmt.Print("failed to generate id")
+	}
+	return hex.EncodeToString(id)
+}
+
+type ScheduleRepository interface {
+	Add(ctx context.Context, schedule *Schedule) error
+	GetByID(ctx context.Context, scheduleID string) (*Schedule, error)
+	List(ctx context.Context) ([]*Schedule, error)
+	Update(ctx context.Context, schedule *Schedule) error
+	// ClaimRun moves the next run of the schedule from due to next. It reports false
+	// when the next run is no longer due, as another instance claimed the run.
+	ClaimRun(ctx context.Context, scheduleID string, due, next time.Time) (bool, error)
+	Delete(ctx context.Context, scheduleID string) error
+}
@@ -0,0 +1,78 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+)
+
+type SSEMode int
+
+const (
+	// SSES3 encrypts objects with keys managed by the storage.
+	SSES3 SSEMode = iota + 1
+	// SSEKMS encrypts objects with a key of the key management service of the storage.
+	SSEKMS
+	// SSEC encrypts objects with a key given by the client on every request.
+	SSEC
+)
+
+var sseModes = map[SSEMode]string{
+	SSES3:  "SSE-S3",
+	SSEKMS: "SSE-KMS",
+	SSEC:   "SSE-C",
+}
+
+func (m SSEMode) String() string {
+	return sseModes[m]
+}
+
+// ParseSSEMode returns the mode named SSE-S3, SSE-KMS or SSE-C.
+func ParseSSEMode(name string) (SSEMode, bool) {
+	for mode, modeName := range sseModes {
+		if strings.EqualFold(name, modeName) {
+			return mode, true
+		}
+	}
+	return 0, false
+}
+
+// ServerSideEncryption asks the storage to encrypt objects at rest. A nil value leaves
+// the default encryption of the bucket.
+type ServerSideEncryption struct {
+	Mode SSEMode
+	// KMSKeyID is the key of SSE-KMS. Empty means the default key of the storage.
+	KMSKeyID string
+	// CustomerKey is the 256 bit key of SSE-C. It is never stored, so every read and
+	// write of the object must give it again.
+	CustomerKey []byte
+}
+
+var ErrInvalidSSE = errors.New("server side encryption needs a known mode, a KMS key only with SSE-KMS and a 256 bit customer key only with SSE-C")
+
+func (e *ServerSideEncryption) Validate() error {
+	switch e.Mode {
+	case SSES3:
+		if e.KMSKeyID != "" || len(e.CustomerKey) != 0 {
+			return ErrInvalidSSE
+		}
+	case SSEKMS:
+		if len(e.CustomerKey) != 0 {
+			return ErrInvalidSSE
+		}
+	case SSEC:
+		if e.KMSKeyID != "" || len(e.CustomerKey) != 32 {
+			return ErrInvalidSSE
+		}
+	default:
+		return ErrInvalidSSE
+	}
+	return nil
+}
+
+// WithoutKey returns the encryption without the customer key, as it may be stored.
+func (e *ServerSideEncryption) WithoutKey() *ServerSideEncryption {
+	if e == nil {
+		return nil
+	}
+	return &ServerSideEncryption{Mode: e.Mode, KMSKeyID: e.KMSKeyID}
+}
@@ -0,0 +1,62 @@
+package entity
+
+import (
+	"path"
+	"strings"
+)
+
+type SyncOptions struct {
+	// Prefix limits the synchronization to source keys starting with it.
+	Prefix string
+	// Include and Exclude are glob patterns matched against the key relative to Prefix.
+	Include []string
+	Exclude []string
+	// Replication is applied to every replicated object.
+	Replication ReplicationOptions
+	// Delete removes target objects whose source was removed.
+	Delete bool
+}
+
+// Match reports whether the key passes the include and exclude filters.
+func (o SyncOptions) Match(key string) bool {
+	name := strings.TrimPrefix(key, o.Prefix)
+	for _, pattern := range o.Exclude {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+
+	if len(o.Include) == 0 {
+		return true
+	}
+	for _, pattern := range o.Include {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// TargetPrefix returns the prefix to list on the target to find replicated keys.
+func (o SyncOptions) TargetPrefix() string {
+	rewrite := o.Replication.PrefixRewrite
+	if rewrite != nil && !strings.HasPrefix(o.Prefix, rewrite.From) {
+		return ""
+	}
+	return o.Replication.TargetObjectID(o.Prefix)
+}
+
+// SourceKey maps a target key back to the source key it is replicated from.
+// It reports false when the key is not a copy of a selected source object.
+func (o SyncOptions) SourceKey(targetKey string) (string, bool) {
+	key := targetKey
+	rewrite := o.Replication.PrefixRewrite
+	if rewrite != nil && strings.HasPrefix(targetKey, rewrite.To) {
+		key = rewrite.From + strings.TrimPrefix(targetKey, rewrite.To)
+	}
+
+	if !strings.HasPrefix(key, o.Prefix) || o.Replication.TargetObjectID(key) != targetKey {
+		return "", false
+	}
+	return key, o.Match(key)
+}
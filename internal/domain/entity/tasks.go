@@ -10,11 +10,54 @@ import (
 )
 
 type Task struct {
-	ID     string
-	Start  time.Time
-	End    time.Time
-	Type   TaskType
-	Status TaskStatus
+	ID       string
+	TenantID string
+	ParentID string
+	Start    time.Time
+	End      time.Time
+	Type     TaskType
+	Status   TaskStatus
+	Progress TaskProgress
+	Targets  []TargetStatus
+}
+
+// TargetStatus is the outcome of a replication for one of its targets.
+type TargetStatus struct {
+	Storage  Storage
+	ObjectID string
+	Status   TaskStatus
+	Error    string
+}
+
+// ReplicationStatus returns the status of a replication from the status of its targets:
+// failed if any target failed, skipped if all were skipped and completed otherwise.
+func ReplicationStatus(targets []TargetStatus) TaskStatus {
+	skipped := 0
+	for _, target := range targets {
+		switch target.Status {
+		case TaskFailed:
+			return TaskFailed
+		case TaskSkipped:
+			skipped++
+		}
+	}
+	if len(targets) > 0 && skipped == len(targets) {
+		return TaskSkipped
+	}
+	return TaskCompleted
+}
+
+// TaskProgress aggregates the outcome of the objects processed by a bulk task.
+type TaskProgress struct {
+	Total   int
+	Copied  int
+	Skipped int
+	Deleted int
+	Failed  int
+}
+
+func (p TaskProgress) Processed() int {
+	return p.Copied + p.Skipped + p.Deleted + p.Failed
 }
 
 type TaskStatus int
@@ -23,12 +66,16 @@ const (
 	TaskCreated TaskStatus = iota + 1
 	TaskCompleted
 	TaskFailed
+	TaskSkipped
 )
 
 type TaskType int
 
 const (
 	Replication TaskType = iota + 1
+	Sync
+	Mirror
+	Move
 )
 
 func NewTaskID() string {
@@ -44,4 +91,9 @@ type TaskRepository interface {
 	Add(ctx context.Context, task *Task) error
 	GetByID(ctx context.Context, taskID string) (*Task, error)
 	Update(ctx context.Context, task *Task) error
+	ListByParent(ctx context.Context, parentID string) ([]*Task, error)
+	// Touch marks the tasks as still in progress.
+	Touch(ctx context.Context, taskIDs []string) error
+	// FailStale fails the tasks in progress which were not touched since before.
+	FailStale(ctx context.Context, before time.Time) (int64, error)
 }
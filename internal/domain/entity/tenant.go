@@ -0,0 +1,37 @@
+package entity
+
+import "context"
+
+type tenantKey struct{}
+
+// WithTenant scopes the repositories used with the context to the tenant. An empty
+// tenant removes the scope, for work which spans tenants.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant the context is scoped to. Contexts of background
+// jobs and of deployments without tenants are not scoped.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	return tenantID, tenantID != ""
+}
+
+// InTenant reports whether a record of the tenant is visible with the context.
+func InTenant(ctx context.Context, tenantID string) bool {
+	scope, scoped := TenantFromContext(ctx)
+	return !scoped || scope == tenantID
+}
+
+type subjectKey struct{}
+
+// WithSubject records the authenticated caller, like the subject of an API key token.
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// SubjectFromContext returns the caller of the context, if it is known.
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	subject, _ := ctx.Value(subjectKey{}).(string)
+	return subject, subject != ""
+}
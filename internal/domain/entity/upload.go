@@ -6,16 +6,54 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"time"
 )
 
 type Upload struct {
 	ID       string
+	TenantID string
+	// Owner is the subject of the caller which created the upload.
+	Owner    string
 	ObjectID string
 	Size     int64
 	Offset   int64
 	Storage  Storage
+	// StorageUploadID is the multipart upload on Storage when it is not ID, after a
+	// replica took over from a failed storage.
+	StorageUploadID string
+	Parts           []UploadPart
+	Status          UploadStatus
+	Metadata        map[string]string
+	// Replicas are the mirrored copies written together with Storage.
+	Replicas []UploadReplica
+	// Quorum is the number of copies, Storage included, which must acknowledge
+	// every chunk. Zero means every copy.
+	Quorum int
+	// Encryption is set when the gateway encrypts the object.
+	Encryption *UploadEncryption
+	// ServerSideEncryption is the one requested on creation, without the SSE-C key
+	// which every chunk must give again.
+	ServerSideEncryption *ServerSideEncryption
+	// GrantID is the id of the upload token which created the upload, if any.
+	GrantID string
+}
+
+// UploadEncryption is the data key of an encrypted upload, wrapped by a master key.
+type UploadEncryption struct {
+	KeyID   string
+	DataKey []byte
+	// Pending is the data received after the last whole segment, sealed on its own with
+	// the data key. It is encrypted again together with the next chunk.
+	Pending []byte
+}
+
+// UploadReplica is a mirrored copy of an upload with its own multipart upload.
+type UploadReplica struct {
+	Storage  Storage
+	UploadID string
 	Parts    []UploadPart
-	Status   UploadStatus
+	// Failed is set once the replica missed a chunk. It is not written to anymore.
+	Failed bool
 }
 
 type UploadPart struct {
@@ -28,6 +66,10 @@ type Storage struct {
 	Bucket     string
 }
 
+func (s Storage) String() string {
+	return s.ProviderID + "/" + s.Bucket
+}
+
 func NewStorageID() string {
 	id := make([]byte, 16)
 	_, err := io.ReadFull(rand.Reader, id)
@@ -46,7 +88,7 @@ const (
 	Failed
 )
 
-func NewUpload(id string, objectID string, size int64, offset int64, status UploadStatus, parts []UploadPart, storage Storage) *Upload {
+func NewUpload(id string, objectID string, size int64, offset int64, status UploadStatus, parts []UploadPart, storage Storage, metadata map[string]string) *Upload {
 	return &Upload{
 		ID:       id,
 		ObjectID: objectID,
@@ -55,6 +97,7 @@ func NewUpload(id string, objectID string, size int64, offset int64, status Uplo
 		Parts:    parts,
 		Storage:  storage,
 		Status:   status,
+		Metadata: metadata,
 	}
 }
 
@@ -62,12 +105,65 @@ func (u *Upload) SetOffset(offset int64) {
 	u.Offset = offset
 }
 
+// MultipartID returns the id of the multipart upload on Storage.
+func (u *Upload) MultipartID() string {
+	if u.StorageUploadID != "" {
+		return u.StorageUploadID
+	}
+	return u.ID
+}
+
+// TakeOver makes the live replica at index i the storage of the upload. The previous
+// storage becomes a failed replica in its place.
+func (u *Upload) TakeOver(i int) {
+	replica := u.Replicas[i]
+	u.Replicas[i] = UploadReplica{Storage: u.Storage, UploadID: u.MultipartID(), Failed: true}
+	u.Storage, u.StorageUploadID, u.Parts = replica.Storage, replica.UploadID, replica.Parts
+}
+
 func (u *Upload) AddPartial(partialID string, position int) {
 	u.Parts = append(u.Parts, UploadPart{ID: partialID, Position: position})
 }
 
+// Storages returns the storage of the upload followed by the storages of its live replicas.
+func (u *Upload) Storages() []Storage {
+	storages := []Storage{u.Storage}
+	for _, replica := range u.Replicas {
+		if !replica.Failed {
+			storages = append(storages, replica.Storage)
+		}
+	}
+	return storages
+}
+
+// RequiredCopies returns the number of copies which must acknowledge a chunk.
+func (u *Upload) RequiredCopies() int {
+	if u.Quorum > 0 {
+		return u.Quorum
+	}
+	return len(u.Replicas) + 1
+}
+
 type UploadRepository interface {
 	Add(ctx context.Context, upload *Upload) error
 	GetByID(ctx context.Context, uploadID string) (*Upload, error)
+	// GetByGrantID returns the upload created with the upload token, or nil.
+	GetByGrantID(ctx context.Context, grantID string) (*Upload, error)
 	Update(ctx context.Context, upload *Upload) error
+	// Usage returns the bytes taken by active and completed uploads in every storage.
+	Usage(ctx context.Context) (map[Storage]int64, error)
+	// CountActiveByProvider counts active uploads with a copy in a storage of the provider.
+	CountActiveByProvider(ctx context.Context, providerID string) (int64, error)
+	// Stats sums the uploads of the owner, or of everyone if the owner is empty.
+	Stats(ctx context.Context, owner string) (UploadStats, error)
+	// ListStale returns the active uploads which were not written to since before.
+	ListStale(ctx context.Context, before time.Time) ([]*Upload, error)
+}
+
+// UploadStats is the usage counted against quotas.
+type UploadStats struct {
+	// Bytes and Objects count active and completed uploads.
+	Bytes   int64
+	Objects int64
+	Active  int64
 }
@@ -2,25 +2,50 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/inview-team/gorynych/internal/domain/entity"
 	log "github.com/sirupsen/logrus"
 )
 
 type AccountService struct {
-	aRepo entity.AccountRepository
+	aRepo       entity.AccountRepository
+	pRepo       entity.ProviderRepository
+	uRepo       entity.UploadRepository
+	taskService *TaskService
+	audit       *AuditService
 }
 
-func NewAccountService(aRepo entity.AccountRepository) *AccountService {
+func NewAccountService(aRepo entity.AccountRepository, pRepo entity.ProviderRepository, uRepo entity.UploadRepository, taskService *TaskService, audit *AuditService) *AccountService {
 	return &AccountService{
-		aRepo: aRepo,
+		aRepo:       aRepo,
+		pRepo:       pRepo,
+		uRepo:       uRepo,
+		taskService: taskService,
+		audit:       audit,
 	}
 }
 
-func (s *AccountService) AddAccount(ctx context.Context, provider string, region string, accessKey string, secret string) (string, error) {
+// AddAccount verifies the credentials against the provider and stores the account with
+// the buckets it can access. Accounts which may not list buckets must name their buckets.
+func (s *AccountService) AddAccount(ctx context.Context, provider string, region string, accessKey string, secret string, buckets []string) (id string, err error) {
+	defer func() {
+		s.audit.Record(ctx, entity.AuditAccountAdd, "account/"+id, err, map[string]string{"provider": provider})
+	}()
+
 	log.Infof("add new account")
 	account := entity.NewServiceAccount(entity.NewAccountID(), provider, region, accessKey, secret)
-	err := s.aRepo.Add(ctx, account)
+	account.TenantID, _ = entity.TenantFromContext(ctx)
+	err = s.verify(ctx, account, buckets)
+	if err != nil {
+		log.Errorf("failed to verify account: %v", err.Error())
+		return "", err
+	}
+
+	err = s.aRepo.Add(ctx, account)
 	if err != nil {
 		log.Errorf("failed to add account: %v", err.Error())
 		return "", err
@@ -58,3 +83,199 @@ func (s *AccountService) GetAccountByID(ctx context.Context, accountID string) (
 
 	return account, nil
 }
+
+func (s *AccountService) ListAccounts(ctx context.Context) ([]*entity.ServiceAccount, error) {
+	accounts, err := s.aRepo.List(ctx)
+	if err != nil {
+		log.Errorf("failed to list accounts: %v", err.Error())
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// AccountUpdate holds the account fields to change. Empty fields are left as they are.
+type AccountUpdate struct {
+	Region    string
+	AccessKey string
+	Secret    string
+}
+
+// UpdateAccount changes the region or rotates the keys of the account.
+func (s *AccountService) UpdateAccount(ctx context.Context, accountID string, update AccountUpdate) (err error) {
+	defer func() {
+		s.audit.Record(ctx, entity.AuditAccountUpdate, "account/"+accountID, err, map[string]string{
+			"region":      update.Region,
+			"rotate_keys": strconv.FormatBool(update.AccessKey != ""),
+		})
+	}()
+
+	account, err := s.GetAccountByID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	// Keys are rotated together, a new key with the old secret can't be valid
+	if (update.AccessKey == "") != (update.Secret == "") {
+		return ErrInvalidAccount
+	}
+
+	if update.Region == "" && update.AccessKey == "" {
+		return nil
+	}
+
+	if update.Region != "" {
+		account.Region = update.Region
+	}
+	if update.AccessKey != "" {
+		account.AccessKey = update.AccessKey
+		account.Secret = update.Secret
+	}
+
+	err = s.verify(ctx, account, nil)
+	if err != nil {
+		log.Errorf("failed to verify account: %v", err.Error())
+		return err
+	}
+
+	log.Infof("update account %s", accountID)
+	return s.aRepo.Update(ctx, account)
+}
+
+func (s *AccountService) SetAccountDisabled(ctx context.Context, accountID string, disabled bool) (err error) {
+	defer func() {
+		action := entity.AuditAccountEnable
+		if disabled {
+			action = entity.AuditAccountDisable
+		}
+		s.audit.Record(ctx, action, "account/"+accountID, err, nil)
+	}()
+
+	account, err := s.GetAccountByID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("set account %s disabled: %v", accountID, disabled)
+	account.Disabled = disabled
+	return s.aRepo.Update(ctx, account)
+}
+
+// DeleteAccount removes the account. It is refused while uploads or tasks of the tenant
+// use a storage of the account provider, since they may depend on the account to finish.
+func (s *AccountService) DeleteAccount(ctx context.Context, accountID string) (err error) {
+	defer func() {
+		s.audit.Record(ctx, entity.AuditAccountDelete, "account/"+accountID, err, nil)
+	}()
+
+	account, err := s.GetAccountByID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	if s.taskService.UsesProvider(ctx, account.ProviderID) {
+		return ErrAccountInUse
+	}
+
+	uploads, err := s.uRepo.CountActiveByProvider(ctx, account.ProviderID)
+	if err != nil {
+		return err
+	}
+	if uploads > 0 {
+		return ErrAccountInUse
+	}
+
+	log.Infof("delete account %s", accountID)
+	return s.aRepo.Delete(ctx, accountID)
+}
+
+// VerifyAccount checks the credentials of the account again and records the buckets
+// it can access now.
+func (s *AccountService) VerifyAccount(ctx context.Context, accountID string) (account *entity.ServiceAccount, err error) {
+	defer func() {
+		s.audit.Record(ctx, entity.AuditAccountVerify, "account/"+accountID, err, nil)
+	}()
+
+	account, err = s.GetAccountByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.verify(ctx, account, nil)
+	if err != nil {
+		log.Errorf("failed to verify account %s: %v", accountID, err.Error())
+		return nil, err
+	}
+
+	err = s.aRepo.Update(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// verify probes the buckets of the account and records the result on it. The given
+// buckets are checked with HeadBucket; without them the buckets are listed, falling back
+// to the buckets of the previous verification if listing is denied.
+func (s *AccountService) verify(ctx context.Context, account *entity.ServiceAccount, buckets []string) error {
+	provider, err := s.pRepo.GetByID(ctx, account.ProviderID)
+	if err != nil {
+		return err
+	}
+	if provider == nil {
+		return ErrProviderNotFound
+	}
+
+	oRepo, err := newObjectRepository(ctx, provider, account)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+
+	names, listed := buckets, false
+	if len(names) == 0 {
+		names, err = oRepo.ListBuckets(ctx)
+		listed = err == nil
+		if err != nil {
+			if !errors.Is(err, entity.ErrAccessDenied) || len(account.Buckets) == 0 {
+				return credentialsError(err)
+			}
+			names = account.BucketNames()
+		}
+	}
+
+	var accesses []entity.BucketAccess
+	accessible := false
+	for _, bucket := range names {
+		if !listed {
+			exists, err := oRepo.IsBucketExist(ctx, bucket)
+			if err != nil {
+				return credentialsError(err)
+			}
+			if !exists {
+				return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+			}
+		}
+
+		access, err := oRepo.ProbeAccess(ctx, bucket)
+		if err != nil {
+			return credentialsError(err)
+		}
+		accessible = accessible || access.Read || access.Write
+		accesses = append(accesses, access)
+	}
+
+	if !accessible {
+		return ErrNoAccessibleBuckets
+	}
+
+	account.Buckets = accesses
+	account.VerifiedAt = time.Now()
+	return nil
+}
+
+// credentialsError tells refused credentials apart from an unreachable provider.
+func credentialsError(err error) error {
+	if errors.Is(err, entity.ErrAccessDenied) {
+		return fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+}
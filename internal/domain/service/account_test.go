@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// newTestAccountService wires an account service to the fake storages, with the
+// account of the tenant acme on provider p1.
+func newTestAccountService(t *testing.T, storages *fakeStorages, uploads *memUploads, audit *memAudit) (*AccountService, *memAccounts) {
+	t.Helper()
+	accounts := newMemAccounts(&entity.ServiceAccount{ID: "acme-account", TenantID: "acme", ProviderID: "p1", AccessKey: "key", Secret: "secret"})
+	tasks := NewTaskService(accounts, storages.providers(), newMemTasks(), nil, NewBandwidthLimiter(BandwidthConfig{}), NewAuditService(audit), 0)
+	return NewAccountService(accounts, storages.providers(), uploads, tasks, NewAuditService(audit)), accounts
+}
+
+func TestUpdateAccount(t *testing.T) {
+	storage := entity.Storage{ProviderID: "p1", Bucket: "a"}
+	acme := entity.WithTenant(context.Background(), "acme")
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		update        AccountUpdate
+		want          error
+		wantAccessKey string
+		wantRegion    string
+	}{
+		{name: "region", ctx: acme, update: AccountUpdate{Region: "eu"}, wantAccessKey: "key", wantRegion: "eu"},
+		{name: "rotated keys", ctx: acme, update: AccountUpdate{AccessKey: "new-key", Secret: "new-secret"}, wantAccessKey: "new-key"},
+		{name: "nothing to change", ctx: acme, wantAccessKey: "key"},
+		{name: "key without secret", ctx: acme, update: AccountUpdate{AccessKey: "new-key"}, want: ErrInvalidAccount, wantAccessKey: "key"},
+		{name: "refused keys", ctx: acme, update: AccountUpdate{AccessKey: deniedKey, Secret: "secret"}, want: ErrInvalidCredentials, wantAccessKey: "key"},
+		{name: "account of another tenant", ctx: entity.WithTenant(context.Background(), "globex"), update: AccountUpdate{Region: "eu"}, want: ErrAccountNotFound, wantAccessKey: "key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, storage)
+			audit := &memAudit{}
+			s, accounts := newTestAccountService(t, storages, newMemUploads(), audit)
+
+			err := s.UpdateAccount(tt.ctx, "acme-account", tt.update)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("UpdateAccount() error = %v, want %v", err, tt.want)
+			}
+			account, _ := accounts.GetByID(context.Background(), "acme-account")
+			if account.AccessKey != tt.wantAccessKey || account.Region != tt.wantRegion {
+				t.Fatalf("account = %+v, want access key %q and region %q", account, tt.wantAccessKey, tt.wantRegion)
+			}
+			if actions := audit.actions(); len(actions) != 1 || actions[0] != entity.AuditAccountUpdate {
+				t.Fatalf("audit actions = %v, want an update", actions)
+			}
+		})
+	}
+}
+
+func TestSetAccountDisabled(t *testing.T) {
+	storages := newFakeStorages(t, entity.Storage{ProviderID: "p1", Bucket: "a"})
+	audit := &memAudit{}
+	s, accounts := newTestAccountService(t, storages, newMemUploads(), audit)
+	acme := entity.WithTenant(context.Background(), "acme")
+
+	for _, disabled := range []bool{true, false} {
+		if err := s.SetAccountDisabled(acme, "acme-account", disabled); err != nil {
+			t.Fatalf("SetAccountDisabled(%v) error = %v", disabled, err)
+		}
+		if account, _ := accounts.GetByID(acme, "acme-account"); account.Disabled != disabled {
+			t.Fatalf("account disabled = %v, want %v", account.Disabled, disabled)
+		}
+	}
+	if err := s.SetAccountDisabled(entity.WithTenant(context.Background(), "globex"), "acme-account", true); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("SetAccountDisabled() of another tenant error = %v, want %v", err, ErrAccountNotFound)
+	}
+	want := []string{entity.AuditAccountDisable, entity.AuditAccountEnable, entity.AuditAccountDisable}
+	if actions := audit.actions(); len(actions) != len(want) || actions[0] != want[0] || actions[1] != want[1] || actions[2] != want[2] {
+		t.Fatalf("audit actions = %v, want %v", actions, want)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	storage := entity.Storage{ProviderID: "p1", Bucket: "a"}
+	acme := entity.WithTenant(context.Background(), "acme")
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		uploads  []entity.Upload
+		bulk     *bulkTask
+		want     error
+		wantKept bool
+	}{
+		{name: "unused account", ctx: acme},
+		{
+			name:    "finished uploads on the provider",
+			ctx:     acme,
+			uploads: []entity.Upload{{ObjectID: "done", TenantID: "acme", Storage: storage, Status: entity.Complete}},
+		},
+		{
+			name:     "active upload on the provider",
+			ctx:      acme,
+			uploads:  []entity.Upload{{ObjectID: "active", TenantID: "acme", Storage: storage, Status: entity.Active}},
+			want:     ErrAccountInUse,
+			wantKept: true,
+		},
+		{
+			name:    "active upload of another tenant",
+			ctx:     acme,
+			uploads: []entity.Upload{{ObjectID: "active", TenantID: "globex", Storage: storage, Status: entity.Active}},
+		},
+		{
+			name:     "running task on the provider",
+			ctx:      acme,
+			bulk:     &bulkTask{task: &entity.Task{ID: "sync", TenantID: "acme"}, storages: []entity.Storage{storage, {ProviderID: "p2", Bucket: "b"}}},
+			want:     ErrAccountInUse,
+			wantKept: true,
+		},
+		{name: "account of another tenant", ctx: entity.WithTenant(context.Background(), "globex"), want: ErrAccountNotFound, wantKept: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, storage)
+			uploads := newMemUploads()
+			for _, upload := range tt.uploads {
+				uploads.Add(context.Background(), &upload)
+			}
+			s, accounts := newTestAccountService(t, storages, uploads, &memAudit{})
+			if tt.bulk != nil {
+				s.taskService.bulks[tt.bulk.task.ID] = tt.bulk
+			}
+
+			err := s.DeleteAccount(tt.ctx, "acme-account")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("DeleteAccount() error = %v, want %v", err, tt.want)
+			}
+			if kept, _ := accounts.GetByID(context.Background(), "acme-account"); (kept != nil) != tt.wantKept {
+				t.Fatalf("account kept = %v, want %v", kept != nil, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestAddAccount(t *testing.T) {
+	a := entity.Storage{ProviderID: "p1", Bucket: "a"}
+	readOnly := entity.Storage{ProviderID: "p1", Bucket: "ro"}
+	acme := entity.WithTenant(context.Background(), "acme")
+
+	tests := []struct {
+		name       string
+		provider   string
+		accessKey  string
+		buckets    []string
+		want       error
+		wantAccess []entity.BucketAccess
+	}{
+		{
+			name:       "listed buckets",
+			provider:   "p1",
+			accessKey:  "key",
+			wantAccess: []entity.BucketAccess{{Bucket: "a", Read: true, Write: true}, {Bucket: "ro", Read: true}},
+		},
+		{
+			name:       "named buckets",
+			provider:   "p1",
+			accessKey:  "key",
+			buckets:    []string{"ro"},
+			wantAccess: []entity.BucketAccess{{Bucket: "ro", Read: true}},
+		},
+		{
+			name:       "named buckets without listing",
+			provider:   "p1",
+			accessKey:  noListKey,
+			buckets:    []string{"a"},
+			wantAccess: []entity.BucketAccess{{Bucket: "a", Read: true, Write: true}},
+		},
+		{name: "no buckets without listing", provider: "p1", accessKey: noListKey, want: ErrInvalidCredentials},
+		{name: "unknown named bucket", provider: "p1", accessKey: "key", buckets: []string{"missing"}, want: ErrBucketNotFound},
+		{name: "refused credentials", provider: "p1", accessKey: deniedKey, want: ErrInvalidCredentials},
+		{name: "unreachable provider", provider: "p1", accessKey: unreachableKey, want: ErrProviderUnavailable},
+		{name: "unknown provider", provider: "p9", accessKey: "key", want: ErrProviderNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, a, readOnly)
+			storages.setReadOnly(readOnly)
+			s, accounts := newTestAccountService(t, storages, newMemUploads(), &memAudit{})
+
+			id, err := s.AddAccount(acme, tt.provider, "", tt.accessKey, "secret", tt.buckets)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("AddAccount() error = %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				if list, _ := accounts.List(context.Background()); len(list) != 1 {
+					t.Fatalf("accounts = %d, want only the existing one", len(list))
+				}
+				return
+			}
+
+			account, _ := accounts.GetByID(acme, id)
+			if account == nil || account.TenantID != "acme" || account.VerifiedAt.IsZero() {
+				t.Fatalf("added account = %+v, want a verified account of the tenant", account)
+			}
+			if len(account.Buckets) != len(tt.wantAccess) {
+				t.Fatalf("account buckets = %+v, want %+v", account.Buckets, tt.wantAccess)
+			}
+			for i, access := range account.Buckets {
+				if access != tt.wantAccess[i] {
+					t.Fatalf("account buckets = %+v, want %+v", account.Buckets, tt.wantAccess)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyAccount(t *testing.T) {
+	a := entity.Storage{ProviderID: "p1", Bucket: "a"}
+	acme := entity.WithTenant(context.Background(), "acme")
+
+	tests := []struct {
+		name      string
+		accessKey string
+		previous  []entity.BucketAccess
+		want      error
+	}{
+		{name: "listed buckets", accessKey: "key"},
+		{name: "previous buckets without listing", accessKey: noListKey, previous: []entity.BucketAccess{{Bucket: "a"}}},
+		{name: "no previous buckets without listing", accessKey: noListKey, want: ErrInvalidCredentials},
+		{name: "refused credentials", accessKey: deniedKey, previous: []entity.BucketAccess{{Bucket: "a"}}, want: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, a)
+			s, accounts := newTestAccountService(t, storages, newMemUploads(), &memAudit{})
+			account, _ := accounts.GetByID(acme, "acme-account")
+			account.AccessKey, account.Buckets = tt.accessKey, tt.previous
+			accounts.Update(acme, account)
+
+			verified, err := s.VerifyAccount(acme, "acme-account")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("VerifyAccount() error = %v, want %v", err, tt.want)
+			}
+			stored, _ := accounts.GetByID(acme, "acme-account")
+			if err != nil {
+				if !stored.VerifiedAt.IsZero() {
+					t.Fatalf("account verified at %v after a failed verification", stored.VerifiedAt)
+				}
+				return
+			}
+			want := entity.BucketAccess{Bucket: "a", Read: true, Write: true}
+			if len(verified.Buckets) != 1 || verified.Buckets[0] != want || stored.VerifiedAt.IsZero() {
+				t.Fatalf("verified account = %+v, want write access to a", stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	log "github.com/sirupsen/logrus"
+)
+
+type AuditConfig struct {
+	// File, if set, receives every event as a JSON line besides the database.
+	File string `yaml:"file,omitempty"`
+}
+
+var (
+	DefaultAuditConfig = AuditConfig{}
+)
+
+// AuditService records who did what. Failing to record an event is logged but never
+// fails the audited call.
+type AuditService struct {
+	repo  entity.AuditRepository
+	sinks []entity.AuditSink
+}
+
+func NewAuditService(repo entity.AuditRepository, sinks ...entity.AuditSink) *AuditService {
+	return &AuditService{
+		repo:  repo,
+		sinks: append([]entity.AuditSink{repo}, sinks...),
+	}
+}
+
+// Record adds an event for the call made with the context. The outcome follows err.
+func (s *AuditService) Record(ctx context.Context, action string, target string, err error, details map[string]string) {
+	actor, ok := entity.SubjectFromContext(ctx)
+	if !ok {
+		actor = entity.AuditSystemActor
+	}
+	tenantID, _ := entity.TenantFromContext(ctx)
+	request := entity.RequestInfoFromContext(ctx)
+
+	event := &entity.AuditEvent{
+		ID:        entity.NewAuditEventID(),
+		TenantID:  tenantID,
+		Time:      time.Now().UTC(),
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		RequestID: request.ID,
+		SourceIP:  request.SourceIP,
+		Outcome:   entity.AuditSuccess,
+		Details:   details,
+	}
+	if err != nil {
+		event.Outcome = entity.AuditFailure
+		event.Error = err.Error()
+	}
+
+	// The call may be over, the event is recorded anyway
+	ctx = context.WithoutCancel(ctx)
+	for _, sink := range s.sinks {
+		if err := sink.Add(ctx, event); err != nil {
+			log.Errorf("failed to record audit event %s %s: %v", action, target, err)
+		}
+	}
+}
+
+func (s *AuditService) ListEvents(ctx context.Context, filter entity.AuditFilter) ([]*entity.AuditEvent, error) {
+	events, err := s.repo.List(ctx, filter)
+	if err != nil {
+		log.Errorf("failed to list audit events: %v", err)
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// failingSink refuses every event, and records whether its context was still alive.
+type failingSink struct {
+	cancelled bool
+}
+
+func (s *failingSink) Add(ctx context.Context, _ *entity.AuditEvent) error {
+	s.cancelled = ctx.Err() != nil
+	return errStorageDown
+}
+
+func TestAuditRecord(t *testing.T) {
+	request := func(ctx context.Context) context.Context {
+		return entity.WithRequestInfo(ctx, entity.RequestInfo{ID: "req-1", SourceIP: "10.0.0.1"})
+	}
+	caller := request(entity.WithTenant(entity.WithSubject(context.Background(), "alice"), "acme"))
+	cancelled, cancel := context.WithCancel(caller)
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want entity.AuditEvent
+	}{
+		{
+			name: "success of a caller",
+			ctx:  caller,
+			want: entity.AuditEvent{TenantID: "acme", Actor: "alice", RequestID: "req-1", SourceIP: "10.0.0.1", Outcome: entity.AuditSuccess},
+		},
+		{
+			name: "failure of a caller",
+			ctx:  caller,
+			err:  ErrAccountNotFound,
+			want: entity.AuditEvent{TenantID: "acme", Actor: "alice", RequestID: "req-1", SourceIP: "10.0.0.1", Outcome: entity.AuditFailure, Error: ErrAccountNotFound.Error()},
+		},
+		{
+			name: "background work",
+			ctx:  context.Background(),
+			want: entity.AuditEvent{Actor: entity.AuditSystemActor, Outcome: entity.AuditSuccess},
+		},
+		{
+			name: "call which is over",
+			ctx:  cancelled,
+			want: entity.AuditEvent{TenantID: "acme", Actor: "alice", RequestID: "req-1", SourceIP: "10.0.0.1", Outcome: entity.AuditSuccess},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, failing, file := &memAudit{}, &failingSink{}, &memAudit{}
+			s := NewAuditService(repo, failing, file)
+
+			details := map[string]string{"provider": "p1"}
+			s.Record(tt.ctx, entity.AuditAccountAdd, "account/1", tt.err, details)
+
+			if failing.cancelled {
+				t.Fatal("sink got a cancelled context")
+			}
+			for name, sink := range map[string]*memAudit{"repository": repo, "sink after a failing one": file} {
+				events, _ := sink.List(context.Background(), entity.AuditFilter{})
+				if len(events) != 1 {
+					t.Fatalf("%s got %d events, want 1", name, len(events))
+				}
+				got := *events[0]
+				if got.ID == "" || got.Time.IsZero() {
+					t.Fatalf("event has no id or time: %+v", got)
+				}
+				want := tt.want
+				want.ID, want.Time = got.ID, got.Time
+				want.Action, want.Target, want.Details = entity.AuditAccountAdd, "account/1", details
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("%s got %+v, want %+v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAuditedAccountCalls(t *testing.T) {
+	tests := []struct {
+		name        string
+		accountID   string
+		want        error
+		wantOutcome entity.AuditOutcome
+	}{
+		{name: "deleted account", accountID: "acme-account", wantOutcome: entity.AuditSuccess},
+		{name: "unknown account", accountID: "missing", want: ErrAccountNotFound, wantOutcome: entity.AuditFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, entity.Storage{ProviderID: "p1", Bucket: "b1"})
+			audit := &memAudit{}
+			s, _ := newTestAccountService(t, storages, newMemUploads(), audit)
+			ctx := entity.WithSubject(entity.WithTenant(context.Background(), "acme"), "alice")
+
+			err := s.DeleteAccount(ctx, tt.accountID)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("DeleteAccount() error = %v, want %v", err, tt.want)
+			}
+			events, _ := audit.List(context.Background(), entity.AuditFilter{})
+			last := events[len(events)-1]
+			if last.Action != entity.AuditAccountDelete || last.Target != "account/"+tt.accountID || last.Actor != "alice" || last.Outcome != tt.wantOutcome {
+				t.Fatalf("last event = %+v, want %s of account/%s by alice with outcome %d", last, entity.AuditAccountDelete, tt.accountID, tt.wantOutcome)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// Size of the slices a throttled reader asks tokens for. Small enough to keep
+// the traffic smooth, big enough to not spin on the bucket mutex.
+const throttleChunk = 32 * 1024
+
+// BandwidthConfig sets the initial limits in bytes per second. Zero means unlimited.
+type BandwidthConfig struct {
+	Global    int64            `yaml:"global,omitempty"`
+	Providers map[string]int64 `yaml:"providers,omitempty"`
+}
+
+var (
+	DefaultBandwidthConfig = BandwidthConfig{}
+)
+
+// TokenBucket limits throughput to rate bytes per second with a burst of one second.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(rate int64) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+func (b *TokenBucket) Rate() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rate
+}
+
+func (b *TokenBucket) SetRate(rate int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	b.rate = rate
+	if b.tokens > float64(rate) {
+		b.tokens = float64(rate)
+	}
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * float64(b.rate)
+	if b.tokens > float64(b.rate) {
+		b.tokens = float64(b.rate)
+	}
+	b.last = now
+}
+
+// WaitN blocks until n bytes may be transferred or the context is done.
+func (b *TokenBucket) WaitN(ctx context.Context, n int) error {
+	for n > 0 {
+		b.mu.Lock()
+		if b.rate <= 0 {
+			b.mu.Unlock()
+			return nil
+		}
+		b.refill(time.Now())
+
+		take := n
+		if int64(take) > b.rate {
+			take = int(b.rate)
+		}
+		if b.tokens >= float64(take) {
+			b.tokens -= float64(take)
+			n -= take
+			b.mu.Unlock()
+			continue
+		}
+		wait := time.Duration((float64(take) - b.tokens) / float64(b.rate) * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
+
+type BandwidthLimits struct {
+	Global    int64
+	Providers map[string]int64
+	Tasks     map[string]int64
+}
+
+// BandwidthLimiter shares token buckets between uploads and replication workers.
+// Traffic is charged to the global bucket, to the bucket of every provider involved
+// and, for replication, to the bucket of the task.
+type BandwidthLimiter struct {
+	mu        sync.RWMutex
+	global    *TokenBucket
+	providers map[string]*TokenBucket
+	tasks     map[string]*TokenBucket
+	// taskTenants keeps the tenant of every limited task, which only its tenant sees.
+	taskTenants map[string]string
+}
+
+func NewBandwidthLimiter(cfg BandwidthConfig) *BandwidthLimiter {
+	l := &BandwidthLimiter{
+		global:      NewTokenBucket(cfg.Global),
+		providers:   make(map[string]*TokenBucket),
+		tasks:       make(map[string]*TokenBucket),
+		taskTenants: make(map[string]string),
+	}
+	for providerID, rate := range cfg.Providers {
+		l.providers[providerID] = NewTokenBucket(rate)
+	}
+	return l
+}
+
+func (l *BandwidthLimiter) SetGlobalLimit(rate int64) {
+	l.global.SetRate(rate)
+}
+
+func (l *BandwidthLimiter) SetProviderLimit(providerID string, rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	setBucketRate(l.providers, providerID, rate)
+}
+
+func (l *BandwidthLimiter) SetTaskLimit(taskID, tenantID string, rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	setBucketRate(l.tasks, taskID, rate)
+	if _, exists := l.tasks[taskID]; exists {
+		l.taskTenants[taskID] = tenantID
+	} else {
+		delete(l.taskTenants, taskID)
+	}
+}
+
+func (l *BandwidthLimiter) RemoveTask(taskID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.tasks, taskID)
+	delete(l.taskTenants, taskID)
+}
+
+func setBucketRate(buckets map[string]*TokenBucket, id string, rate int64) {
+	if rate <= 0 {
+		delete(buckets, id)
+		return
+	}
+	if bucket, exists := buckets[id]; exists {
+		bucket.SetRate(rate)
+		return
+	}
+	buckets[id] = NewTokenBucket(rate)
+}
+
+// Limits returns the global and provider limits, and the limits of the tasks visible
+// with the context.
+func (l *BandwidthLimiter) Limits(ctx context.Context) BandwidthLimits {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	limits := BandwidthLimits{
+		Global:    l.global.Rate(),
+		Providers: make(map[string]int64),
+		Tasks:     make(map[string]int64),
+	}
+	for id, bucket := range l.providers {
+		limits.Providers[id] = bucket.Rate()
+	}
+	for id, bucket := range l.tasks {
+		if entity.InTenant(ctx, l.taskTenants[id]) {
+			limits.Tasks[id] = bucket.Rate()
+		}
+	}
+	return limits
+}
+
+func (l *BandwidthLimiter) buckets(taskID string, providerIDs ...string) []*TokenBucket {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	buckets := []*TokenBucket{l.global}
+	for _, providerID := range providerIDs {
+		if bucket, exists := l.providers[providerID]; exists {
+			buckets = append(buckets, bucket)
+		}
+	}
+	if bucket, exists := l.tasks[taskID]; exists {
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// Wait blocks until n bytes may be moved on behalf of the task and providers.
+func (l *BandwidthLimiter) Wait(ctx context.Context, n int, taskID string, providerIDs ...string) error {
+	for _, bucket := range l.buckets(taskID, providerIDs...) {
+		if err := bucket.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reader throttles reads from r. Buckets are looked up on every read, so limits
+// changed at runtime apply to transfers already in progress.
+func (l *BandwidthLimiter) Reader(ctx context.Context, r io.ReadCloser, taskID string, providerIDs ...string) io.ReadCloser {
+	return &throttledReader{ctx: ctx, reader: r, limiter: l, taskID: taskID, providerIDs: providerIDs}
+}
+
+type throttledReader struct {
+	ctx         context.Context
+	reader      io.ReadCloser
+	limiter     *BandwidthLimiter
+	taskID      string
+	providerIDs []string
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if werr := r.limiter.Wait(r.ctx, n, r.taskID, r.providerIDs...); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (r *throttledReader) Close() error {
+	return r.reader.Close()
+}
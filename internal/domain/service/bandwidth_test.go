@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestBandwidthLimitsOfTenant(t *testing.T) {
+	l := NewBandwidthLimiter(BandwidthConfig{Global: 1000, Providers: map[string]int64{"p1": 500}})
+	l.SetTaskLimit("acme-task", "acme", 100)
+	l.SetTaskLimit("globex-task", "globex", 200)
+	l.SetTaskLimit("operator-task", "", 300)
+	l.SetTaskLimit("unlimited-task", "acme", 0)
+
+	tests := []struct {
+		name   string
+		tenant string
+		want   map[string]int64
+	}{
+		{name: "tenant", tenant: "acme", want: map[string]int64{"acme-task": 100}},
+		{name: "other tenant", tenant: "globex", want: map[string]int64{"globex-task": 200}},
+		{name: "unknown tenant", tenant: "initech", want: map[string]int64{}},
+		{name: "without tenant", want: map[string]int64{"acme-task": 100, "globex-task": 200, "operator-task": 300}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := l.Limits(entity.WithTenant(context.Background(), tt.tenant))
+			if limits.Global != 1000 || limits.Providers["p1"] != 500 {
+				t.Fatalf("Limits() = %+v, want the global and provider limits", limits)
+			}
+			if len(limits.Tasks) != len(tt.want) {
+				t.Fatalf("Limits() tasks = %v, want %v", limits.Tasks, tt.want)
+			}
+			for id, rate := range tt.want {
+				if limits.Tasks[id] != rate {
+					t.Fatalf("Limits() tasks = %v, want %v", limits.Tasks, tt.want)
+				}
+			}
+		})
+	}
+
+	l.RemoveTask("acme-task")
+	if limits := l.Limits(entity.WithTenant(context.Background(), "acme")); len(limits.Tasks) != 0 {
+		t.Fatalf("Limits() after RemoveTask = %v", limits.Tasks)
+	}
+}
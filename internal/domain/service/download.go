@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	log "github.com/sirupsen/logrus"
+)
+
+// findObject returns the object of a completed upload from the first of its storages
+// holding it.
+func (s *UploadService) findObject(ctx context.Context, objectID string, sse *entity.ServerSideEncryption) (entity.ObjectRepository, entity.Storage, *entity.Object, error) {
+	upload, err := s.uploadRepo.GetByID(ctx, objectID)
+	if err != nil {
+		log.Errorf("failed to find upload of object %s: %v", objectID, err)
+		return nil, entity.Storage{}, nil, err
+	}
+	if upload == nil || upload.Status != entity.Complete {
+		return nil, entity.Storage{}, nil, ErrObjectNotFound
+	}
+	if err := checkCustomerKey(upload, sse); err != nil {
+		return nil, entity.Storage{}, nil, err
+	}
+
+	for _, storage := range upload.Storages() {
+		repo, err := s.getAccountByBucket(ctx, storage)
+		if err != nil {
+			log.Warnf("failed to open storage %s of object %s: %v", storage, objectID, err)
+			continue
+		}
+		object, err := repo.GetObject(ctx, storage.Bucket, objectID, sse)
+		if err != nil {
+			log.Warnf("failed to get object %s from storage %s: %v", objectID, storage, err)
+			continue
+		}
+		if object != nil {
+			return repo, storage, object, nil
+		}
+	}
+	return nil, entity.Storage{}, nil, ErrObjectNotFound
+}
+
+// GetObject returns the object of a completed upload. Encrypted objects have the size
+// of their plaintext. Objects written with SSE-C need their key.
+func (s *UploadService) GetObject(ctx context.Context, objectID string, sse *entity.ServerSideEncryption) (*entity.Object, error) {
+	_, _, object, err := s.findObject(ctx, objectID, sse)
+	if err != nil {
+		return nil, err
+	}
+	return plaintextObject(object)
+}
+
+// Download reads the object of a completed upload from start to end, bounds included.
+// Objects encrypted by the gateway are decrypted; objects written with SSE-C need their key.
+func (s *UploadService) Download(ctx context.Context, objectID string, start, end int64, sse *entity.ServerSideEncryption) (io.ReadCloser, error) {
+	repo, storage, object, err := s.findObject(ctx, objectID, sse)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := plaintextObject(object)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 || end < start || end >= plaintext.Size {
+		return nil, fmt.Errorf("%w: %d-%d of %d bytes", ErrInvalidRange, start, end, plaintext.Size)
+	}
+
+	reader, err := readObject(ctx, repo, s.keyring, storage.Bucket, object, start, end, sse)
+	if err != nil {
+		log.Errorf("failed to download object %s: %v", objectID, err)
+		return nil, err
+	}
+	if reader == nil {
+		return nil, ErrObjectNotFound
+	}
+	return s.limiter.Reader(ctx, reader, "", storage.ProviderID), nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestDownload(t *testing.T) {
+	storage := entity.Storage{ProviderID: "p1", Bucket: "b1"}
+	tenant := entity.WithTenant(context.Background(), "acme")
+	customerKey := &entity.ServerSideEncryption{Mode: entity.SSEC, CustomerKey: bytes.Repeat([]byte{1}, 32)}
+
+	tests := []struct {
+		name        string
+		sse         *entity.ServerSideEncryption
+		ctx         context.Context
+		downloadSSE *entity.ServerSideEncryption
+		start, end  int64
+		want        string
+		wantErr     error
+	}{
+		{name: "whole object", ctx: tenant, end: 9, want: "helloworld"},
+		{name: "range", ctx: tenant, start: 5, end: 7, want: "wor"},
+		{name: "range past the end", ctx: tenant, start: 5, end: 10, wantErr: ErrInvalidRange},
+		{name: "range ending before its start", ctx: tenant, start: 5, end: 4, wantErr: ErrInvalidRange},
+		{name: "other tenant", ctx: entity.WithTenant(context.Background(), "globex"), end: 9, wantErr: ErrObjectNotFound},
+		{name: "SSE-C without the customer key", sse: customerKey, ctx: tenant, end: 9, wantErr: ErrCustomerKeyNeeded},
+		{name: "SSE-C with the customer key", sse: customerKey, ctx: tenant, downloadSSE: customerKey, end: 9, want: "helloworld"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, storage)
+			s := newTestUploadService(t, storages, newMemUploads(), &memAudit{}, QuotasConfig{}, UploadConfig{})
+
+			objectID, err := s.CreateUpload(tenant, 10, nil, &storage, tt.sse)
+			if err != nil {
+				t.Fatalf("CreateUpload() error = %v", err)
+			}
+			data := []byte("helloworld")
+			if _, err := s.WritePart(tenant, objectID, 0, &data, tt.sse); err != nil {
+				t.Fatalf("WritePart() error = %v", err)
+			}
+
+			reader, err := s.Download(tt.ctx, objectID, tt.start, tt.end, tt.downloadSSE)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Download() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer reader.Close()
+			got, err := io.ReadAll(reader)
+			if err != nil || string(got) != tt.want {
+				t.Fatalf("Download() read %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"github.com/inview-team/gorynych/internal/infrastructure/encryption"
+	"github.com/inview-team/gorynych/internal/infrastructure/secrets"
+)
+
+// Upload-Metadata key turning the gateway encryption of an upload on or off
+const encryptKey = "encrypt"
+
+// encryptSettings reports whether a new upload is encrypted, from its metadata or else
+// from the configuration.
+func (s *UploadService) encryptSettings(metadata map[string]string) (bool, error) {
+	encrypt := s.cfg.Encrypt
+	if value, exists := metadata[encryptKey]; exists {
+		var err error
+		encrypt, err = strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("%w: %s must be true or false", ErrInvalidEncryption, encryptKey)
+		}
+	}
+
+	if encrypt && !s.keyring.Enabled() {
+		return false, fmt.Errorf("%w: no master key configured", ErrInvalidEncryption)
+	}
+	return encrypt, nil
+}
+
+// sealChunk encrypts the chunk written at the offset of the upload. Only whole segments
+// are sealed until the last chunk; the rest of the chunk is returned sealed on its own to
+// wait for the next one. The sealed data may be empty.
+func (s *UploadService) sealChunk(upload *entity.Upload, data []byte) ([]byte, []byte, error) {
+	key, err := encryption.OpenObjectKey(s.keyring, upload.Encryption.KeyID, upload.Encryption.DataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open data key: %w", err)
+	}
+
+	pending, err := key.OpenPending(upload.Offset, upload.Encryption.Pending)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext := make([]byte, 0, len(pending)+len(data))
+	plaintext = append(append(plaintext, pending...), data...)
+	index := (upload.Offset - int64(len(pending))) / encryption.SegmentSize
+
+	final := upload.Offset+int64(len(data)) == upload.Size
+	whole := len(plaintext)
+	if !final {
+		whole -= whole % encryption.SegmentSize
+	}
+
+	sealed, err := key.Seal(index, plaintext[:whole], final)
+	if err != nil {
+		return nil, nil, err
+	}
+	rest, err := key.SealPending(upload.Offset+int64(len(data)), plaintext[whole:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return sealed, rest, nil
+}
+
+// readObject reads the range from start to end, bounds included, of the object and
+// decrypts it if the gateway encrypted the object.
+func readObject(ctx context.Context, repo entity.ObjectRepository, keyring *secrets.Keyring, bucket string, object *entity.Object, start, end int64, sse *entity.ServerSideEncryption) (io.ReadCloser, error) {
+	key, err := encryption.KeyFromMetadata(keyring, object.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return repo.StreamDownloadObject(ctx, bucket, object.Name, start, end, sse)
+	}
+
+	sealedStart, sealedEnd := encryption.SealedRange(object.Size, start, end)
+	reader, err := repo.StreamDownloadObject(ctx, bucket, object.Name, sealedStart, sealedEnd, sse)
+	if err != nil || reader == nil {
+		return nil, err
+	}
+	decrypted, err := encryption.NewReader(key, reader, object.Size, start, end)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return decrypted, nil
+}
+
+// plaintextObject returns the object with the size of its plaintext.
+func plaintextObject(object *entity.Object) (*entity.Object, error) {
+	if !encryption.Encrypted(object.Metadata) {
+		return object, nil
+	}
+
+	size, err := encryption.PlaintextSize(object.Size)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := *object
+	plaintext.Size = size
+	return &plaintext, nil
+}
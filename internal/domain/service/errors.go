@@ -8,20 +8,59 @@ var (
 	ErrStorageNotFound    = errors.New("storage not found")
 	ErrBucketNotFound     = errors.New("bucket not found")
 	ErrUploadNotFound     = errors.New("upload not found")
+	ErrUploadFinished     = errors.New("upload is already finished")
 	ErrUploadBig          = errors.New("upload is too big")
+	ErrQuotaExceeded      = errors.New("upload quota exceeded")
 	ErrWrongOffset        = errors.New("wrong offset")
+	ErrInvalidMirror      = errors.New("mirror needs at least one copy and a quorum between 1 and the number of copies")
+	ErrNotEnoughStorages  = errors.New("not enough storages for the requested copies")
+	ErrQuorumNotReached   = errors.New("not enough copies acknowledged the chunk")
+	ErrUnknownPlacement   = errors.New("unknown placement strategy")
+	ErrUnknownStorage     = errors.New("requested storage is not available")
+	ErrStorageNotWritable = errors.New("requested storage is not writable")
+	ErrInvalidEncryption  = errors.New("invalid encryption settings")
+	ErrInvalidRange       = errors.New("range is not satisfiable")
+	ErrCustomerKeyNeeded  = errors.New("object is encrypted with SSE-C, its customer key is needed")
+	ErrUploadNotGranted   = errors.New("upload token does not allow this upload")
+	ErrUploadTokenUsed    = errors.New("upload token was already used")
+	ErrProviderNotFound   = errors.New("provider not found")
+	ErrProviderExists     = errors.New("provider with this id already exists")
+	ErrProviderInUse      = errors.New("provider still has accounts")
+	ErrInvalidProvider    = errors.New("provider needs a name, an http(s) endpoint, a known signature version and SSE-S3 or SSE-KMS as encryption if any")
 )
 
 var (
 	ErrObjectNotFound = errors.New("object not found")
+	ErrObjectExists   = errors.New("object already exists")
 )
 
 // Service accounts errors
 var (
 	ErrNoAvailableAccounts = errors.New("no available accounts")
 	ErrAccountNotFound     = errors.New("account not found")
+	ErrAccountInUse        = errors.New("account provider has active uploads or tasks, disable the account and retry later")
+	ErrInvalidAccount      = errors.New("access key and secret must be changed together")
+	ErrInvalidCredentials  = errors.New("provider rejected the account credentials")
+	ErrProviderUnavailable = errors.New("provider could not be reached")
+	ErrNoAccessibleBuckets = errors.New("account can neither read nor write any bucket")
 )
 
 var (
 	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskFinished = errors.New("task is already finished")
+	ErrNoTargets    = errors.New("replication needs at least one target storage")
+
+	ErrInvalidMove        = errors.New("move would overwrite its own source")
+	ErrVerificationFailed = errors.New("copy on the target does not match the source")
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidCron      = errors.New("invalid cron expression")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+)
+
+var (
+	ErrPolicyNotFound = errors.New("policy not found")
+	ErrInvalidPolicy  = errors.New("policy needs a source provider, at least one target storage and a valid size range")
 )
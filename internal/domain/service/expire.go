@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	log "github.com/sirupsen/logrus"
+)
+
+// Interval between checks of stale uploads
+const uploadExpiryInterval = 10 * time.Minute
+
+// Start expires the uploads abandoned by their clients, until the context is done.
+func (s *UploadService) Start(ctx context.Context) {
+	if s.cfg.ExpireAfter <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(uploadExpiryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.ExpireUploads(ctx, now)
+			}
+		}
+	}()
+}
+
+// ExpireUploads aborts the uploads which were not written to for ExpireAfter, so they
+// no longer take a concurrent upload of the quota of their owner.
+func (s *UploadService) ExpireUploads(ctx context.Context, now time.Time) {
+	uploads, err := s.uploadRepo.ListStale(ctx, now.Add(-s.cfg.ExpireAfter))
+	if err != nil {
+		log.Errorf("failed to list stale uploads: %v", err)
+		return
+	}
+
+	for _, stale := range uploads {
+		ctx := entity.WithTenant(ctx, stale.TenantID)
+		s.mu.Lock()
+		upload := stale
+		if cached, exists := s.uploads[stale.ObjectID]; exists {
+			// A chunk written since the listing keeps the upload
+			if cached.Offset != stale.Offset || cached.Status != entity.Active {
+				s.mu.Unlock()
+				continue
+			}
+			upload = cached
+		}
+		delete(s.uploads, upload.ObjectID)
+		upload.Status = entity.Expired
+		s.mu.Unlock()
+
+		log.Infof("upload %s expired at offset %d of %d", upload.ObjectID, upload.Offset, upload.Size)
+		s.abortCopies(ctx, upload)
+		if err := s.uploadRepo.Update(ctx, upload); err != nil {
+			log.Errorf("failed to save expired upload %s: %v", upload.ObjectID, err)
+		}
+		s.audit.Record(ctx, entity.AuditUploadExpire, "upload/"+upload.ObjectID, nil, nil)
+	}
+}
+
+// abortCopies aborts the multipart upload of every live copy of the upload.
+func (s *UploadService) abortCopies(ctx context.Context, upload *entity.Upload) {
+	for _, c := range mirrorCopies(upload) {
+		repo, err := s.getAccountByBucket(ctx, c.storage)
+		if err != nil {
+			log.Warnf("failed to abort upload of %s in bucket %s: %v", upload.ObjectID, c.storage.Bucket, err)
+			continue
+		}
+		if err := repo.AbortUpload(ctx, c.storage.Bucket, c.uploadID, upload.ObjectID); err != nil {
+			log.Warnf("failed to abort upload of %s in bucket %s: %v", upload.ObjectID, c.storage.Bucket, err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestExpireUploads(t *testing.T) {
+	storage := entity.Storage{ProviderID: "p1", Bucket: "b1"}
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		// stale backdates the last write of the upload past ExpireAfter
+		stale bool
+		// uncached drops the upload from the cache, as if another instance created it
+		uncached bool
+		// writeAfterList writes a chunk between the listing and the expiry
+		writeAfterList bool
+		wantExpired    bool
+	}{
+		{name: "stale upload", stale: true, wantExpired: true},
+		{name: "stale upload of another instance", stale: true, uncached: true, wantExpired: true},
+		{name: "fresh upload"},
+		{name: "written to since the listing", stale: true, writeAfterList: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, storage)
+			uploads := newMemUploads()
+			audit := &memAudit{}
+			s := newTestUploadService(t, storages, uploads, audit, QuotasConfig{}, UploadConfig{ExpireAfter: time.Hour})
+
+			ctx := entity.WithTenant(context.Background(), "acme")
+			objectID, err := s.CreateUpload(ctx, 10, nil, &storage, nil)
+			if err != nil {
+				t.Fatalf("CreateUpload() error = %v", err)
+			}
+			chunk := []byte("hello")
+			if _, err := s.WritePart(ctx, objectID, 0, &chunk, nil); err != nil {
+				t.Fatalf("WritePart() error = %v", err)
+			}
+			if tt.stale {
+				uploads.touch(objectID, now.Add(-2*time.Hour))
+			}
+			if tt.uncached {
+				s.mu.Lock()
+				delete(s.uploads, objectID)
+				s.mu.Unlock()
+			}
+			if tt.writeAfterList {
+				uploads.listed = func() {
+					chunk := []byte("wor")
+					if _, err := s.WritePart(ctx, objectID, 5, &chunk, nil); err != nil {
+						t.Errorf("WritePart() after listing error = %v", err)
+					}
+				}
+			}
+
+			s.ExpireUploads(context.Background(), now)
+
+			upload, _ := uploads.get(objectID)
+			if expired := upload.Status == entity.Expired; expired != tt.wantExpired {
+				t.Fatalf("upload status = %v, want expired %v", upload.Status, tt.wantExpired)
+			}
+			if aborted := slices.Contains(storages.aborted(storage), upload.ID); aborted != tt.wantExpired {
+				t.Fatalf("aborted uploads = %v, want aborted %v", storages.aborted(storage), tt.wantExpired)
+			}
+			if audited := slices.Contains(audit.actions(), entity.AuditUploadExpire); audited != tt.wantExpired {
+				t.Fatalf("audit actions = %v, want expiry %v", audit.actions(), tt.wantExpired)
+			}
+
+			chunk = []byte("!")
+			_, err = s.WritePart(ctx, objectID, upload.Offset, &chunk, nil)
+			if gotNotFound := errors.Is(err, ErrUploadNotFound); gotNotFound != tt.wantExpired {
+				t.Fatalf("WritePart() after expiry error = %v, want not found %v", err, tt.wantExpired)
+			}
+		})
+	}
+}
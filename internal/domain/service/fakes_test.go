@@ -0,0 +1,783 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+var (
+	errStorageDown      = errors.New("storage is down")
+	errWrongCustomerKey = errors.New("customer key does not match")
+)
+
+// fakeStorages stands in for the providers, accounts and buckets of tests. Every
+// provider has a single account seeing all of its buckets, writable unless set read only.
+type fakeStorages struct {
+	mu      sync.Mutex
+	buckets map[entity.Storage]*fakeBucket
+}
+
+// fakeBucket keeps the objects and multipart uploads of a bucket, with the server side
+// encryption each upload was created with. Writes fail while down is set, and completing
+// multipart uploads while finishFails is set. Accounts may not write to readOnly buckets.
+type fakeBucket struct {
+	down        bool
+	finishFails bool
+	readOnly    bool
+	objects     map[string][]byte
+	uploads     map[string]map[int][]byte
+	encryption  map[string]*entity.ServerSideEncryption
+	aborted     []string
+	uploadID    int
+}
+
+// newFakeStorages creates the buckets and makes the services open them instead of S3.
+func newFakeStorages(t *testing.T, storages ...entity.Storage) *fakeStorages {
+	t.Helper()
+	f := &fakeStorages{buckets: make(map[entity.Storage]*fakeBucket)}
+	for _, storage := range storages {
+		f.buckets[storage] = &fakeBucket{
+			objects:    make(map[string][]byte),
+			uploads:    make(map[string]map[int][]byte),
+			encryption: make(map[string]*entity.ServerSideEncryption),
+		}
+	}
+
+	open := newObjectRepository
+	newObjectRepository = func(_ context.Context, provider *entity.Provider, account *entity.ServiceAccount) (entity.ObjectRepository, error) {
+		return &fakeObjects{storages: f, providerID: provider.ID, accessKey: account.AccessKey}, nil
+	}
+	t.Cleanup(func() { newObjectRepository = open })
+	return f
+}
+
+func (f *fakeStorages) bucket(storage entity.Storage) *fakeBucket {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.buckets[storage]
+}
+
+// setDown makes writes to the storage fail or succeed again.
+func (f *fakeStorages) setDown(storage entity.Storage, down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buckets[storage].down = down
+}
+
+// setFinishFails makes completing multipart uploads of the storage fail, while parts
+// are still written.
+func (f *fakeStorages) setFinishFails(storage entity.Storage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buckets[storage].finishFails = true
+}
+
+// object returns the content of a completed object, and whether it exists.
+func (f *fakeStorages) object(storage entity.Storage, key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, exists := f.buckets[storage].objects[key]
+	return data, exists
+}
+
+// encryption returns the server side encryption the multipart upload was created with.
+func (f *fakeStorages) encryption(storage entity.Storage, uploadID string) *entity.ServerSideEncryption {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.buckets[storage].encryption[uploadID]
+}
+
+// aborted returns the aborted multipart uploads of the storage.
+func (f *fakeStorages) aborted(storage entity.Storage) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.buckets[storage].aborted...)
+}
+
+// setReadOnly makes the account of the storage provider verified, with read access only
+// to the bucket.
+func (f *fakeStorages) setReadOnly(storage entity.Storage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buckets[storage].readOnly = true
+}
+
+// account returns the account of the provider. Providers with read only buckets have a
+// verified account. Must be called with the lock held.
+func (f *fakeStorages) account(providerID string) *entity.ServiceAccount {
+	account := &entity.ServiceAccount{ID: providerID + "-account", ProviderID: providerID}
+	var access []entity.BucketAccess
+	verified := false
+	for storage, bucket := range f.buckets {
+		if storage.ProviderID == providerID {
+			access = append(access, entity.BucketAccess{Bucket: storage.Bucket, Read: true, Write: !bucket.readOnly})
+			verified = verified || bucket.readOnly
+		}
+	}
+	if verified {
+		account.Buckets, account.VerifiedAt = access, time.Now()
+	}
+	return account
+}
+
+func (f *fakeStorages) providers() entity.ProviderRepository {
+	return &fakeProviders{storages: f}
+}
+
+func (f *fakeStorages) accounts() entity.AccountRepository {
+	return &fakeAccounts{storages: f}
+}
+
+type fakeProviders struct {
+	entity.ProviderRepository
+	storages *fakeStorages
+}
+
+func (r *fakeProviders) GetByID(_ context.Context, providerID string) (*entity.Provider, error) {
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	for storage := range r.storages.buckets {
+		if storage.ProviderID == providerID {
+			return &entity.Provider{ID: providerID}, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeAccounts struct {
+	entity.AccountRepository
+	storages *fakeStorages
+}
+
+func (r *fakeAccounts) ListByProvider(_ context.Context, providerID string) ([]*entity.ServiceAccount, error) {
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	return []*entity.ServiceAccount{r.storages.account(providerID)}, nil
+}
+
+func (r *fakeAccounts) List(ctx context.Context) ([]*entity.ServiceAccount, error) {
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	providers := make(map[string]bool)
+	for storage := range r.storages.buckets {
+		providers[storage.ProviderID] = true
+	}
+
+	var accounts []*entity.ServiceAccount
+	for providerID := range providers {
+		accounts = append(accounts, r.storages.account(providerID))
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts, nil
+}
+
+// Access keys of accounts the fake storages treat apart
+const (
+	// deniedKey is refused by the storages.
+	deniedKey = "denied"
+	// noListKey may not list buckets, but may use them.
+	noListKey = "no-list"
+	// unreachableKey belongs to storages which can't be reached.
+	unreachableKey = "unreachable"
+)
+
+// fakeObjects is the object repository of a provider of fakeStorages, opened with the
+// access key of an account.
+type fakeObjects struct {
+	entity.ObjectRepository
+	storages   *fakeStorages
+	providerID string
+	accessKey  string
+}
+
+// refused returns the error of the storage for the access key, if any.
+func (r *fakeObjects) refused(listing bool) error {
+	switch {
+	case r.accessKey == deniedKey, r.accessKey == noListKey && listing:
+		return entity.ErrAccessDenied
+	case r.accessKey == unreachableKey:
+		return errStorageDown
+	}
+	return nil
+}
+
+// bucket returns the bucket, failing when it is down. Must be called with the lock held.
+func (r *fakeObjects) bucket(name string, write bool) (*fakeBucket, error) {
+	bucket, exists := r.storages.buckets[entity.Storage{ProviderID: r.providerID, Bucket: name}]
+	if !exists {
+		return nil, fmt.Errorf("bucket %s not found", name)
+	}
+	if write && bucket.down {
+		return nil, errStorageDown
+	}
+	return bucket, nil
+}
+
+func (r *fakeObjects) IsBucketExist(_ context.Context, name string) (bool, error) {
+	if err := r.refused(false); err != nil {
+		return false, err
+	}
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	_, exists := r.storages.buckets[entity.Storage{ProviderID: r.providerID, Bucket: name}]
+	return exists, nil
+}
+
+func (r *fakeObjects) ListBuckets(_ context.Context) ([]string, error) {
+	if err := r.refused(true); err != nil {
+		return nil, err
+	}
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	var names []string
+	for storage := range r.storages.buckets {
+		if storage.ProviderID == r.providerID {
+			names = append(names, storage.Bucket)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (r *fakeObjects) ProbeAccess(_ context.Context, name string) (entity.BucketAccess, error) {
+	if err := r.refused(false); err != nil {
+		return entity.BucketAccess{}, err
+	}
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	bucket, err := r.bucket(name, false)
+	if err != nil {
+		return entity.BucketAccess{}, err
+	}
+	return entity.BucketAccess{Bucket: name, Read: true, Write: !bucket.readOnly}, nil
+}
+
+func (r *fakeObjects) Create(_ context.Context, name string, _ string, _ entity.ObjectAttributes, sse *entity.ServerSideEncryption) (string, error) {
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	bucket, err := r.bucket(name, true)
+	if err != nil {
+		return "", err
+	}
+	bucket.uploadID++
+	uploadID := fmt.Sprintf("%s-%s-%d", r.providerID, name, bucket.uploadID)
+	bucket.uploads[uploadID] = make(map[int][]byte)
+	bucket.encryption[uploadID] = sse
+	return uploadID, nil
+}
+
+func (r *fakeObjects) WritePart(_ context.Context, name, uploadID, _ string, position int, data *[]byte, sse *entity.ServerSideEncryption) (string, error) {
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	bucket, err := r.bucket(name, true)
+	if err != nil {
+		return "", err
+	}
+	parts, exists := bucket.uploads[uploadID]
+	if !exists {
+		return "", fmt.Errorf("upload %s not found", uploadID)
+	}
+	// Like S3, parts of SSE-C uploads need the key the upload was created with
+	if created := bucket.encryption[uploadID]; created != nil && created.Mode == entity.SSEC {
+		if sse == nil || !bytes.Equal(sse.CustomerKey, created.CustomerKey) {
+			return "", errWrongCustomerKey
+		}
+	}
+	parts[position] = append([]byte(nil), *data...)
+	return fmt.Sprintf("%s-%d", uploadID, position), nil
+}
+
+func (r *fakeObjects) FinishUpload(_ context.Context, name, uploadID, objectID string, parts []entity.UploadPart) error {
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	bucket, err := r.bucket(name, true)
+	if err != nil {
+		return err
+	}
+	if bucket.finishFails {
+		return errStorageDown
+	}
+	written, exists := bucket.uploads[uploadID]
+	if !exists {
+		return fmt.Errorf("upload %s not found", uploadID)
+	}
+	var object []byte
+	for _, part := range parts {
+		object = append(object, written[part.Position]...)
+	}
+	delete(bucket.uploads, uploadID)
+	bucket.objects[objectID] = object
+	return nil
+}
+
+func (r *fakeObjects) AbortUpload(_ context.Context, name, uploadID, _ string) error {
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	bucket, err := r.bucket(name, false)
+	if err != nil {
+		return err
+	}
+	delete(bucket.uploads, uploadID)
+	bucket.aborted = append(bucket.aborted, uploadID)
+	return nil
+}
+
+func (r *fakeObjects) GetObject(_ context.Context, name, objectID string, _ *entity.ServerSideEncryption) (*entity.Object, error) {
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	bucket, err := r.bucket(name, false)
+	if err != nil {
+		return nil, err
+	}
+	data, exists := bucket.objects[objectID]
+	if !exists {
+		return nil, nil
+	}
+	return &entity.Object{Name: objectID, Size: int64(len(data))}, nil
+}
+
+func (r *fakeObjects) StreamDownloadObject(_ context.Context, name, objectID string, start, end int64, _ *entity.ServerSideEncryption) (io.ReadCloser, error) {
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	bucket, err := r.bucket(name, false)
+	if err != nil {
+		return nil, err
+	}
+	data, exists := bucket.objects[objectID]
+	if !exists {
+		return nil, nil
+	}
+	return io.NopCloser(bytes.NewReader(data[start : end+1])), nil
+}
+
+func (r *fakeObjects) ListObjects(_ context.Context, name, prefix string) ([]*entity.Object, error) {
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	bucket, err := r.bucket(name, false)
+	if err != nil {
+		return nil, err
+	}
+	var objects []*entity.Object
+	for key, data := range bucket.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, &entity.Object{Name: key, Size: int64(len(data))})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+func (r *fakeObjects) DeleteObject(_ context.Context, name, objectID string) error {
+	r.storages.mu.Lock()
+	defer r.storages.mu.Unlock()
+	bucket, err := r.bucket(name, false)
+	if err != nil {
+		return err
+	}
+	delete(bucket.objects, objectID)
+	return nil
+}
+
+// memUploads keeps uploads in memory, copied like a database would, and scoped to the
+// tenant of the context. listed, when set, runs after every ListStale.
+type memUploads struct {
+	mu      sync.Mutex
+	uploads map[string]entity.Upload
+	updated map[string]time.Time
+	listed  func()
+}
+
+func newMemUploads() *memUploads {
+	return &memUploads{uploads: make(map[string]entity.Upload), updated: make(map[string]time.Time)}
+}
+
+func (r *memUploads) Add(_ context.Context, upload *entity.Upload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if upload.GrantID != "" {
+		for _, existing := range r.uploads {
+			if existing.GrantID == upload.GrantID {
+				return entity.ErrGrantUsed
+			}
+		}
+	}
+	r.uploads[upload.ObjectID] = *upload
+	r.updated[upload.ObjectID] = time.Now()
+	return nil
+}
+
+func (r *memUploads) GetByID(ctx context.Context, objectID string) (*entity.Upload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, exists := r.uploads[objectID]
+	if !exists || !entity.InTenant(ctx, upload.TenantID) {
+		return nil, nil
+	}
+	return &upload, nil
+}
+
+func (r *memUploads) GetByGrantID(ctx context.Context, grantID string) (*entity.Upload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, upload := range r.uploads {
+		if upload.GrantID == grantID && entity.InTenant(ctx, upload.TenantID) {
+			return &upload, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memUploads) Update(ctx context.Context, upload *entity.Upload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, exists := r.uploads[upload.ObjectID]; exists && entity.InTenant(ctx, existing.TenantID) {
+		r.uploads[upload.ObjectID] = *upload
+		r.updated[upload.ObjectID] = time.Now()
+	}
+	return nil
+}
+
+func (r *memUploads) Usage(ctx context.Context) (map[entity.Storage]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	usage := make(map[entity.Storage]int64)
+	for _, upload := range r.uploads {
+		if entity.InTenant(ctx, upload.TenantID) && (upload.Status == entity.Active || upload.Status == entity.Complete) {
+			for _, storage := range upload.Storages() {
+				usage[storage] += upload.Size
+			}
+		}
+	}
+	return usage, nil
+}
+
+func (r *memUploads) CountActiveByProvider(ctx context.Context, providerID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, upload := range r.uploads {
+		if !entity.InTenant(ctx, upload.TenantID) || upload.Status != entity.Active {
+			continue
+		}
+		for _, storage := range upload.Storages() {
+			if storage.ProviderID == providerID {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+func (r *memUploads) Stats(ctx context.Context, owner string) (entity.UploadStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var stats entity.UploadStats
+	for _, upload := range r.uploads {
+		if !entity.InTenant(ctx, upload.TenantID) || owner != "" && upload.Owner != owner {
+			continue
+		}
+		if upload.Status == entity.Active || upload.Status == entity.Complete {
+			stats.Bytes += upload.Size
+			stats.Objects++
+		}
+		if upload.Status == entity.Active {
+			stats.Active++
+		}
+	}
+	return stats, nil
+}
+
+func (r *memUploads) ListStale(ctx context.Context, before time.Time) ([]*entity.Upload, error) {
+	r.mu.Lock()
+	listed := r.listed
+	var uploads []*entity.Upload
+	for id, upload := range r.uploads {
+		if entity.InTenant(ctx, upload.TenantID) && upload.Status == entity.Active && r.updated[id].Before(before) {
+			upload := upload
+			uploads = append(uploads, &upload)
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].ObjectID < uploads[j].ObjectID })
+	r.mu.Unlock()
+
+	if listed != nil {
+		listed()
+	}
+	return uploads, nil
+}
+
+// get returns the stored upload, whatever its tenant.
+func (r *memUploads) get(objectID string) (entity.Upload, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, exists := r.uploads[objectID]
+	return upload, exists
+}
+
+// touch sets the time the upload was last written to.
+func (r *memUploads) touch(objectID string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updated[objectID] = at
+}
+
+// memTasks keeps tasks in memory, copied like a database would, and scoped to the tenant
+// of the context.
+type memTasks struct {
+	entity.TaskRepository
+
+	mu    sync.Mutex
+	tasks map[string]entity.Task
+}
+
+func newMemTasks() *memTasks {
+	return &memTasks{tasks: make(map[string]entity.Task)}
+}
+
+func (r *memTasks) Add(_ context.Context, task *entity.Task) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tasks[task.ID] = *task
+	return nil
+}
+
+func (r *memTasks) GetByID(ctx context.Context, taskID string) (*entity.Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	task, exists := r.tasks[taskID]
+	if !exists || !entity.InTenant(ctx, task.TenantID) {
+		return nil, nil
+	}
+	return &task, nil
+}
+
+func (r *memTasks) Update(ctx context.Context, task *entity.Task) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, exists := r.tasks[task.ID]; exists && entity.InTenant(ctx, existing.TenantID) {
+		r.tasks[task.ID] = *task
+	}
+	return nil
+}
+
+// wait returns the task once it is no longer created, failing the test after a second.
+func (r *memTasks) wait(t *testing.T, taskID string) entity.Task {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		r.mu.Lock()
+		task := r.tasks[taskID]
+		r.mu.Unlock()
+		if task.Status != entity.TaskCreated || time.Now().After(deadline) {
+			if task.Status == entity.TaskCreated {
+				t.Fatalf("task %s still running", taskID)
+			}
+			return task
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// memProviders keeps providers in memory.
+type memProviders struct {
+	mu        sync.Mutex
+	providers map[string]entity.Provider
+}
+
+func newMemProviders(providers ...*entity.Provider) *memProviders {
+	r := &memProviders{providers: make(map[string]entity.Provider)}
+	for _, provider := range providers {
+		r.providers[provider.ID] = *provider
+	}
+	return r
+}
+
+func (r *memProviders) Add(_ context.Context, provider *entity.Provider) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[provider.ID] = *provider
+	return nil
+}
+
+func (r *memProviders) GetByID(_ context.Context, providerID string) (*entity.Provider, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	provider, exists := r.providers[providerID]
+	if !exists {
+		return nil, nil
+	}
+	return &provider, nil
+}
+
+func (r *memProviders) List(_ context.Context) ([]*entity.Provider, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var providers []*entity.Provider
+	for _, provider := range r.providers {
+		provider := provider
+		providers = append(providers, &provider)
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].ID < providers[j].ID })
+	return providers, nil
+}
+
+func (r *memProviders) Update(_ context.Context, provider *entity.Provider) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[provider.ID] = *provider
+	return nil
+}
+
+func (r *memProviders) Delete(_ context.Context, providerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.providers, providerID)
+	return nil
+}
+
+// memAccounts keeps accounts in memory, copied like a database would, and scoped to the
+// tenant of the context.
+type memAccounts struct {
+	mu       sync.Mutex
+	accounts map[string]entity.ServiceAccount
+}
+
+func newMemAccounts(accounts ...*entity.ServiceAccount) *memAccounts {
+	r := &memAccounts{accounts: make(map[string]entity.ServiceAccount)}
+	for _, account := range accounts {
+		r.accounts[account.ID] = *account
+	}
+	return r
+}
+
+func (r *memAccounts) Add(_ context.Context, account *entity.ServiceAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accounts[account.ID] = *account
+	return nil
+}
+
+func (r *memAccounts) GetByID(ctx context.Context, accountID string) (*entity.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, exists := r.accounts[accountID]
+	if !exists || !entity.InTenant(ctx, account.TenantID) {
+		return nil, nil
+	}
+	return &account, nil
+}
+
+func (r *memAccounts) ListByProvider(ctx context.Context, providerID string) ([]*entity.ServiceAccount, error) {
+	accounts, _ := r.List(ctx)
+	var matching []*entity.ServiceAccount
+	for _, account := range accounts {
+		if account.ProviderID == providerID {
+			matching = append(matching, account)
+		}
+	}
+	return matching, nil
+}
+
+func (r *memAccounts) List(ctx context.Context) ([]*entity.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var accounts []*entity.ServiceAccount
+	for _, account := range r.accounts {
+		if entity.InTenant(ctx, account.TenantID) {
+			account := account
+			accounts = append(accounts, &account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts, nil
+}
+
+func (r *memAccounts) Update(ctx context.Context, account *entity.ServiceAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, exists := r.accounts[account.ID]; exists && entity.InTenant(ctx, existing.TenantID) {
+		r.accounts[account.ID] = *account
+	}
+	return nil
+}
+
+func (r *memAccounts) Delete(ctx context.Context, accountID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, exists := r.accounts[accountID]; exists && entity.InTenant(ctx, existing.TenantID) {
+		delete(r.accounts, accountID)
+	}
+	return nil
+}
+
+// memAudit keeps the recorded audit events.
+type memAudit struct {
+	mu     sync.Mutex
+	events []*entity.AuditEvent
+}
+
+func (r *memAudit) Add(_ context.Context, event *entity.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *memAudit) List(_ context.Context, _ entity.AuditFilter) ([]*entity.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*entity.AuditEvent(nil), r.events...), nil
+}
+
+// actions returns the actions of the recorded events, in order.
+func (r *memAudit) actions() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var actions []string
+	for _, event := range r.events {
+		actions = append(actions, event.Action)
+	}
+	return actions
+}
+
+// noPolicies is a policy repository without policies.
+type noPolicies struct {
+	entity.PolicyRepository
+}
+
+func (noPolicies) List(context.Context) ([]*entity.ReplicationPolicy, error) {
+	return nil, nil
+}
+
+// newTestUploadService wires an upload service to the fake storages and repositories,
+// with the given quotas and the default placement.
+func newTestUploadService(t *testing.T, storages *fakeStorages, uploads *memUploads, audit *memAudit, quotas QuotasConfig, cfg UploadConfig) *UploadService {
+	t.Helper()
+	placement, err := NewPlacement(PlacementConfig{}, uploads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewUploadService(
+		uploads,
+		storages.accounts(),
+		storages.providers(),
+		NewBandwidthLimiter(BandwidthConfig{}),
+		NewPolicyService(noPolicies{}, nil),
+		placement,
+		NewQuotas(quotas, uploads),
+		NewAuditService(audit),
+		nil,
+		cfg,
+	)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// applyGrant restricts a new upload to the grant of the upload token of the caller. It
+// returns the metadata and the target storage of the upload.
+func (s *UploadService) applyGrant(ctx context.Context, grant *entity.UploadGrant, size int64, metadata map[string]string, target *entity.Storage) (map[string]string, *entity.Storage, error) {
+	if size > grant.MaxSize {
+		return nil, nil, fmt.Errorf("%w: %d bytes are more than the %d allowed", ErrUploadNotGranted, size, grant.MaxSize)
+	}
+
+	if grant.Storage != nil {
+		if target != nil && *target != *grant.Storage {
+			return nil, nil, fmt.Errorf("%w: storage %s is not %s", ErrUploadNotGranted, target, grant.Storage)
+		}
+		target = grant.Storage
+	}
+
+	// A token creates a single upload
+	existing, err := s.uploadRepo.GetByGrantID(ctx, grant.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check upload token: %w", err)
+	}
+	if existing != nil {
+		return nil, nil, ErrUploadTokenUsed
+	}
+
+	merged := make(map[string]string, len(metadata)+len(grant.Metadata))
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for k, v := range grant.Metadata {
+		merged[k] = v
+	}
+	return merged, target, nil
+}
+
+// granted reports whether the caller may see the upload: callers with an upload token
+// only see the upload created with it.
+func granted(ctx context.Context, upload *entity.Upload) bool {
+	grant := entity.UploadGrantFromContext(ctx)
+	return grant == nil || grant.ID == upload.GrantID
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// grantUploads keeps uploads by grant id. Other methods of the repository are not used.
+type grantUploads struct {
+	entity.UploadRepository
+	byGrant map[string]*entity.Upload
+}
+
+func (r *grantUploads) GetByGrantID(_ context.Context, grantID string) (*entity.Upload, error) {
+	return r.byGrant[grantID], nil
+}
+
+func (r *grantUploads) Add(_ context.Context, upload *entity.Upload) error {
+	r.byGrant[upload.GrantID] = upload
+	return nil
+}
+
+func TestApplyGrant(t *testing.T) {
+	pinned := entity.Storage{ProviderID: "p1", Bucket: "b1"}
+	other := entity.Storage{ProviderID: "p2", Bucket: "b2"}
+
+	tests := []struct {
+		name         string
+		grant        entity.UploadGrant
+		size         int64
+		metadata     map[string]string
+		target       *entity.Storage
+		used         bool
+		wantErr      error
+		wantTarget   *entity.Storage
+		wantMetadata map[string]string
+	}{
+		{
+			name:  "within the grant",
+			grant: entity.UploadGrant{ID: "g1", MaxSize: 100},
+			size:  100,
+		},
+		{
+			name:    "larger than granted",
+			grant:   entity.UploadGrant{ID: "g1", MaxSize: 100},
+			size:    101,
+			wantErr: ErrUploadNotGranted,
+		},
+		{
+			name:       "pinned storage",
+			grant:      entity.UploadGrant{ID: "g1", MaxSize: 100, Storage: &pinned},
+			size:       10,
+			wantTarget: &pinned,
+		},
+		{
+			name:       "pinned storage requested",
+			grant:      entity.UploadGrant{ID: "g1", MaxSize: 100, Storage: &pinned},
+			size:       10,
+			target:     &entity.Storage{ProviderID: "p1", Bucket: "b1"},
+			wantTarget: &pinned,
+		},
+		{
+			name:    "other storage than pinned",
+			grant:   entity.UploadGrant{ID: "g1", MaxSize: 100, Storage: &pinned},
+			size:    10,
+			target:  &other,
+			wantErr: ErrUploadNotGranted,
+		},
+		{
+			name:       "any storage",
+			grant:      entity.UploadGrant{ID: "g1", MaxSize: 100},
+			size:       10,
+			target:     &other,
+			wantTarget: &other,
+		},
+		{
+			name:         "metadata of the grant over the client's",
+			grant:        entity.UploadGrant{ID: "g1", MaxSize: 100, Metadata: map[string]string{"owner": "backend"}},
+			size:         10,
+			metadata:     map[string]string{"owner": "browser", "filename": "a.txt"},
+			wantMetadata: map[string]string{"owner": "backend", "filename": "a.txt"},
+		},
+		{
+			name:    "second use",
+			grant:   entity.UploadGrant{ID: "g1", MaxSize: 100},
+			size:    10,
+			used:    true,
+			wantErr: ErrUploadTokenUsed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &grantUploads{byGrant: make(map[string]*entity.Upload)}
+			if tt.used {
+				repo.byGrant[tt.grant.ID] = &entity.Upload{GrantID: tt.grant.ID}
+			}
+			s := &UploadService{uploadRepo: repo}
+
+			metadata, target, err := s.applyGrant(context.Background(), &tt.grant, tt.size, tt.metadata, tt.target)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("applyGrant() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyGrant() error = %v", err)
+			}
+			if (target == nil) != (tt.wantTarget == nil) || target != nil && *target != *tt.wantTarget {
+				t.Fatalf("applyGrant() target = %v, want %v", target, tt.wantTarget)
+			}
+			if len(metadata) != len(tt.wantMetadata) {
+				t.Fatalf("applyGrant() metadata = %v, want %v", metadata, tt.wantMetadata)
+			}
+			for k, v := range tt.wantMetadata {
+				if metadata[k] != v {
+					t.Fatalf("applyGrant() metadata = %v, want %v", metadata, tt.wantMetadata)
+				}
+			}
+		})
+	}
+}
+
+func TestGrantSingleUse(t *testing.T) {
+	repo := &grantUploads{byGrant: make(map[string]*entity.Upload)}
+	s := &UploadService{uploadRepo: repo}
+	grant := &entity.UploadGrant{ID: "g1", MaxSize: 100, ExpiresAt: time.Now().Add(time.Hour)}
+	ctx := context.Background()
+
+	if _, _, err := s.applyGrant(ctx, grant, 10, nil, nil); err != nil {
+		t.Fatalf("first use error = %v", err)
+	}
+	if err := repo.Add(ctx, &entity.Upload{ObjectID: "o1", GrantID: grant.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.applyGrant(ctx, grant, 10, nil, nil); !errors.Is(err, ErrUploadTokenUsed) {
+		t.Fatalf("second use error = %v, want %v", err, ErrUploadTokenUsed)
+	}
+}
+
+func TestGranted(t *testing.T) {
+	upload := &entity.Upload{ObjectID: "o1", GrantID: "g1"}
+
+	tests := []struct {
+		name  string
+		grant *entity.UploadGrant
+		want  bool
+	}{
+		{name: "bearer token", want: true},
+		{name: "token of the upload", grant: &entity.UploadGrant{ID: "g1"}, want: true},
+		{name: "token of another upload", grant: &entity.UploadGrant{ID: "g2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.grant != nil {
+				ctx = entity.WithUploadGrant(ctx, tt.grant)
+			}
+			if got := granted(ctx, upload); got != tt.want {
+				t.Fatalf("granted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	log "github.com/sirupsen/logrus"
+)
+
+// mirrorCopy is a live copy of a mirrored upload: its storage or one of its replicas.
+type mirrorCopy struct {
+	storage  entity.Storage
+	uploadID string
+	parts    []entity.UploadPart
+	// replica is the index of the replica, or -1 for the storage of the upload.
+	replica int
+}
+
+func mirrorCopies(upload *entity.Upload) []mirrorCopy {
+	copies := []mirrorCopy{{storage: upload.Storage, uploadID: upload.MultipartID(), parts: upload.Parts, replica: -1}}
+	for i, replica := range upload.Replicas {
+		if !replica.Failed {
+			copies = append(copies, mirrorCopy{storage: replica.Storage, uploadID: replica.UploadID, parts: replica.Parts, replica: i})
+		}
+	}
+	return copies
+}
+
+// writeMirrored writes the chunk to every live copy in parallel, the storage of the
+// upload included. The chunk is accepted when enough copies to reach the quorum
+// acknowledged it; copies which missed it are dropped, and a replica takes over from a
+// dropped storage. Otherwise nothing is recorded and the client may retry the same
+// chunk, which overwrites the part.
+func (s *UploadService) writeMirrored(ctx context.Context, upload *entity.Upload, position int, data *[]byte, sse *entity.ServerSideEncryption) error {
+	copies := mirrorCopies(upload)
+	tags := make([]string, len(copies))
+	errs := make([]error, len(copies))
+
+	var wg sync.WaitGroup
+	for i, c := range copies {
+		wg.Add(1)
+		go func(i int, c mirrorCopy) {
+			defer wg.Done()
+			repo, err := s.getAccountByBucket(ctx, c.storage)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			tags[i], errs[i] = repo.WritePart(ctx, c.storage.Bucket, c.uploadID, upload.ObjectID, position, data, sse)
+		}(i, c)
+	}
+	wg.Wait()
+
+	acknowledged := 0
+	for _, err := range errs {
+		if err == nil {
+			acknowledged++
+		}
+	}
+	if acknowledged < upload.RequiredCopies() {
+		return ErrQuorumNotReached
+	}
+
+	for i, c := range copies {
+		if errs[i] != nil {
+			continue
+		}
+		part := entity.UploadPart{ID: tags[i], Position: position}
+		if c.replica < 0 {
+			upload.Parts = append(upload.Parts, part)
+		} else {
+			upload.Replicas[c.replica].Parts = append(upload.Replicas[c.replica].Parts, part)
+		}
+	}
+	s.dropCopies(ctx, upload, copies, errs)
+	return nil
+}
+
+// finishMirrored completes the multipart upload of every live copy. When fewer copies
+// than the quorum complete, the completed objects are deleted again, so either the
+// quorum holds the object or no copy does.
+func (s *UploadService) finishMirrored(ctx context.Context, upload *entity.Upload) error {
+	copies := mirrorCopies(upload)
+	repos := make([]entity.ObjectRepository, len(copies))
+	errs := make([]error, len(copies))
+
+	var wg sync.WaitGroup
+	for i, c := range copies {
+		wg.Add(1)
+		go func(i int, c mirrorCopy) {
+			defer wg.Done()
+			repos[i], errs[i] = s.getAccountByBucket(ctx, c.storage)
+			if errs[i] != nil {
+				return
+			}
+			errs[i] = repos[i].FinishUpload(ctx, c.storage.Bucket, c.uploadID, upload.ObjectID, c.parts)
+		}(i, c)
+	}
+	wg.Wait()
+
+	finished := 0
+	for _, err := range errs {
+		if err == nil {
+			finished++
+		}
+	}
+
+	if finished < upload.RequiredCopies() {
+		for i, c := range copies {
+			if errs[i] != nil {
+				continue
+			}
+			if err := repos[i].DeleteObject(ctx, c.storage.Bucket, upload.ObjectID); err != nil {
+				log.Warnf("failed to delete copy of %s in bucket %s: %v", upload.ObjectID, c.storage.Bucket, err)
+			}
+			// The completed copy is gone, it must not be aborted
+			errs[i] = ErrQuorumNotReached
+			copies[i].uploadID = ""
+		}
+		s.dropCopies(ctx, upload, copies, errs)
+		return ErrQuorumNotReached
+	}
+
+	s.dropCopies(ctx, upload, copies, errs)
+	return nil
+}
+
+// dropCopies drops the copies which failed. The storage of the upload is dropped last,
+// so the replica taking over from it is a live one when there is any.
+func (s *UploadService) dropCopies(ctx context.Context, upload *entity.Upload, copies []mirrorCopy, errs []error) {
+	storageFailed := false
+	for i, c := range copies {
+		if errs[i] == nil {
+			continue
+		}
+		if c.replica < 0 {
+			storageFailed = true
+			continue
+		}
+		replica := &upload.Replicas[c.replica]
+		log.Errorf("drop replica of %s in bucket %s: %v", upload.ObjectID, replica.Storage.Bucket, errs[i])
+		if c.uploadID == "" {
+			replica.Failed, replica.Parts = true, nil
+			continue
+		}
+		s.dropReplica(ctx, upload.ObjectID, replica)
+	}
+	if !storageFailed {
+		return
+	}
+
+	log.Errorf("drop storage of %s in bucket %s: %v", upload.ObjectID, upload.Storage.Bucket, errs[0])
+	for i, replica := range upload.Replicas {
+		if replica.Failed {
+			continue
+		}
+		upload.TakeOver(i)
+		log.Infof("replica of %s in bucket %s takes over", upload.ObjectID, upload.Storage.Bucket)
+		if copies[0].uploadID != "" {
+			s.dropReplica(ctx, upload.ObjectID, &upload.Replicas[i])
+		}
+		return
+	}
+
+	// No replica is left to take over, the upload fails with its storage
+	if copies[0].uploadID == "" {
+		return
+	}
+	repo, err := s.getAccountByBucket(ctx, upload.Storage)
+	if err != nil {
+		return
+	}
+	if err := repo.AbortUpload(ctx, upload.Storage.Bucket, copies[0].uploadID, upload.ObjectID); err != nil {
+		log.Warnf("failed to abort upload of %s in bucket %s: %v", upload.ObjectID, upload.Storage.Bucket, err)
+	}
+}
+
+// dropReplica marks the replica as failed and aborts its multipart upload.
+func (s *UploadService) dropReplica(ctx context.Context, objectID string, replica *entity.UploadReplica) {
+	replica.Failed = true
+	replica.Parts = nil
+	repo, err := s.getAccountByBucket(ctx, replica.Storage)
+	if err != nil {
+		return
+	}
+	if err := repo.AbortUpload(ctx, replica.Storage.Bucket, replica.UploadID, objectID); err != nil {
+		log.Warnf("failed to abort replica of %s in bucket %s: %v", objectID, replica.Storage.Bucket, err)
+	}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestMirroredUpload(t *testing.T) {
+	a := entity.Storage{ProviderID: "p1", Bucket: "a"}
+	b := entity.Storage{ProviderID: "p1", Bucket: "b"}
+	c := entity.Storage{ProviderID: "p2", Bucket: "c"}
+	quorum := map[string]string{mirrorCopiesKey: "3", mirrorQuorumKey: "2"}
+
+	tests := []struct {
+		name       string
+		metadata   map[string]string
+		down       []entity.Storage
+		failFinish []entity.Storage
+		want       error
+		// retry writes the chunk again once the storages are back
+		retry       bool
+		wantObjects []entity.Storage
+		wantStorage entity.Storage
+		wantStatus  entity.UploadStatus
+	}{
+		{
+			name:        "every copy written",
+			metadata:    quorum,
+			wantObjects: []entity.Storage{a, b, c},
+			wantStorage: a,
+			wantStatus:  entity.Complete,
+		},
+		{
+			name:        "replica down, quorum kept",
+			metadata:    quorum,
+			down:        []entity.Storage{c},
+			wantObjects: []entity.Storage{a, b},
+			wantStorage: a,
+			wantStatus:  entity.Complete,
+		},
+		{
+			name:        "storage down, replica takes over",
+			metadata:    quorum,
+			down:        []entity.Storage{a},
+			wantObjects: []entity.Storage{b, c},
+			wantStorage: b,
+			wantStatus:  entity.Complete,
+		},
+		{
+			name:       "quorum lost",
+			metadata:   quorum,
+			down:       []entity.Storage{b, c},
+			want:       ErrQuorumNotReached,
+			wantStatus: entity.Active,
+		},
+		{
+			name:        "quorum lost, then retried",
+			metadata:    quorum,
+			down:        []entity.Storage{b, c},
+			want:        ErrQuorumNotReached,
+			retry:       true,
+			wantObjects: []entity.Storage{a, b, c},
+			wantStorage: a,
+			wantStatus:  entity.Complete,
+		},
+		{
+			name:       "every copy required",
+			metadata:   map[string]string{mirrorCopiesKey: "3"},
+			down:       []entity.Storage{c},
+			want:       ErrQuorumNotReached,
+			wantStatus: entity.Active,
+		},
+		{
+			name:       "quorum lost on completion",
+			metadata:   quorum,
+			failFinish: []entity.Storage{b, c},
+			want:       ErrQuorumNotReached,
+			wantStatus: entity.Failed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, a, b, c)
+			uploads := newMemUploads()
+			s := newTestUploadService(t, storages, uploads, &memAudit{}, QuotasConfig{}, UploadConfig{})
+			ctx := entity.WithTenant(context.Background(), "acme")
+
+			objectID, err := s.CreateUpload(ctx, 10, tt.metadata, &a, nil)
+			if err != nil {
+				t.Fatalf("CreateUpload() error = %v", err)
+			}
+			for _, storage := range tt.down {
+				storages.setDown(storage, true)
+			}
+			for _, storage := range tt.failFinish {
+				storages.setFinishFails(storage)
+			}
+
+			data := []byte("helloworld")
+			_, err = s.WritePart(ctx, objectID, 0, &data, nil)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("WritePart() error = %v, want %v", err, tt.want)
+			}
+			if tt.retry {
+				for _, storage := range tt.down {
+					storages.setDown(storage, false)
+				}
+				if _, err := s.WritePart(ctx, objectID, 0, &data, nil); err != nil {
+					t.Fatalf("WritePart() retried error = %v", err)
+				}
+			}
+
+			upload, _ := uploads.get(objectID)
+			if upload.Status != tt.wantStatus {
+				t.Fatalf("upload status = %v, want %v", upload.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == entity.Complete && upload.Storage != tt.wantStorage {
+				t.Fatalf("upload storage = %v, want %v", upload.Storage, tt.wantStorage)
+			}
+			for _, storage := range []entity.Storage{a, b, c} {
+				_, exists := storages.object(storage, objectID)
+				want := false
+				for _, w := range tt.wantObjects {
+					want = want || w == storage
+				}
+				if exists != want {
+					t.Fatalf("object in %v = %v, want %v", storage, exists, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateMirroredUpload(t *testing.T) {
+	a := entity.Storage{ProviderID: "p1", Bucket: "a"}
+	b := entity.Storage{ProviderID: "p1", Bucket: "b"}
+
+	tests := []struct {
+		name         string
+		metadata     map[string]string
+		want         error
+		wantReplicas int
+		wantQuorum   int
+	}{
+		{name: "single copy"},
+		{name: "two copies", metadata: map[string]string{mirrorCopiesKey: "2"}, wantReplicas: 1, wantQuorum: 2},
+		{name: "quorum of one", metadata: map[string]string{mirrorCopiesKey: "2", mirrorQuorumKey: "1"}, wantReplicas: 1, wantQuorum: 1},
+		{name: "quorum over the copies", metadata: map[string]string{mirrorCopiesKey: "2", mirrorQuorumKey: "3"}, want: ErrInvalidMirror},
+		{name: "no copies", metadata: map[string]string{mirrorCopiesKey: "0"}, want: ErrInvalidMirror},
+		{name: "copies not a number", metadata: map[string]string{mirrorCopiesKey: "two"}, want: ErrInvalidMirror},
+		{name: "more copies than storages", metadata: map[string]string{mirrorCopiesKey: "3"}, want: ErrNotEnoughStorages},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, a, b)
+			uploads := newMemUploads()
+			s := newTestUploadService(t, storages, uploads, &memAudit{}, QuotasConfig{}, UploadConfig{})
+
+			objectID, err := s.CreateUpload(context.Background(), 10, tt.metadata, &a, nil)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("CreateUpload() error = %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				if created := len(storages.bucket(a).uploads); created != 0 {
+					t.Fatalf("multipart uploads left in the target = %d", created)
+				}
+				return
+			}
+			upload, _ := uploads.get(objectID)
+			if len(upload.Replicas) != tt.wantReplicas || upload.Quorum != tt.wantQuorum {
+				t.Fatalf("upload replicas = %v, quorum %d, want %d replicas and quorum %d", upload.Replicas, upload.Quorum, tt.wantReplicas, tt.wantQuorum)
+			}
+			if tt.wantReplicas > 0 && upload.Replicas[0].Storage != b {
+				t.Fatalf("replica storage = %v, want %v", upload.Replicas[0].Storage, b)
+			}
+		})
+	}
+}
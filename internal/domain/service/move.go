@@ -0,0 +1,415 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	log "github.com/sirupsen/logrus"
+)
+
+// moveJob is the state of a running move. Its child tasks replicate the objects;
+// the source of each one is deleted once its copy is verified.
+type moveJob struct {
+	source entity.Storage
+	target entity.Storage
+	opts   entity.MoveOptions
+	// sources maps child task IDs to the source key they replicate.
+	sources map[string]string
+}
+
+// PlanMove lists the objects a move would replicate and delete, without touching them.
+func (s *TaskService) PlanMove(ctx context.Context, sourceStorage, targetStorage entity.Storage, opts entity.MoveOptions) ([]entity.MoveItem, error) {
+	if err := validateMove(sourceStorage, targetStorage, opts); err != nil {
+		return nil, err
+	}
+
+	sourceRepo, err := openStorage(ctx, s.providerRepo, s.accountRepo, sourceStorage)
+	if err != nil {
+		return nil, err
+	}
+	targetRepo, err := openStorage(ctx, s.providerRepo, s.accountRepo, targetStorage)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := moveObjects(ctx, sourceRepo, sourceStorage.Bucket, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMoveTargets(sourceStorage, targetStorage, opts, objects); err != nil {
+		return nil, err
+	}
+
+	items := make([]entity.MoveItem, 0, len(objects))
+	for _, object := range objects {
+		targetKey := opts.Sync.Replication.TargetObjectID(object.Name)
+		existing, err := targetRepo.GetObject(ctx, targetStorage.Bucket, targetKey, opts.Sync.Replication.TargetEncryption)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, entity.MoveItem{
+			SourceKey:    object.Name,
+			TargetKey:    targetKey,
+			Size:         object.Size,
+			TargetExists: existing != nil,
+		})
+	}
+	return items, nil
+}
+
+// Move replicates the selected objects to the target and deletes every source object
+// once its copy on the target has the same size and checksum.
+func (s *TaskService) Move(ctx context.Context, sourceStorage, targetStorage entity.Storage, opts entity.MoveOptions) (id string, err error) {
+	defer func() {
+		s.audit.Record(ctx, entity.AuditTaskMove, "task/"+id, err, taskDetails(sourceStorage, targetStorage))
+	}()
+
+	if err := validateMove(sourceStorage, targetStorage, opts); err != nil {
+		return "", err
+	}
+
+	task := &entity.Task{ID: entity.NewTaskID(), Start: time.Now(), Type: entity.Move, Status: entity.TaskCreated}
+	task.TenantID, _ = entity.TenantFromContext(ctx)
+	err = s.taskRepo.Add(ctx, task)
+	if err != nil {
+		log.Errorf("failed to create move task: %v", err)
+		return "", err
+	}
+
+	job := &moveJob{source: sourceStorage, target: targetStorage, opts: opts, sources: make(map[string]string)}
+	s.mu.Lock()
+	s.bulks[task.ID] = &bulkTask{task: task, move: job, storages: []entity.Storage{sourceStorage, targetStorage}}
+	s.mu.Unlock()
+	s.limiter.SetTaskLimit(task.ID, task.TenantID, opts.Sync.Replication.MaxBandwidth)
+
+	go s.runMove(context.WithoutCancel(ctx), task.ID, job)
+	return task.ID, nil
+}
+
+func validateMove(sourceStorage, targetStorage entity.Storage, opts entity.MoveOptions) error {
+	replication := opts.Sync.Replication
+	if sourceStorage == targetStorage {
+		switch {
+		case replication.TargetKey == "" && replication.PrefixRewrite == nil:
+			return ErrInvalidMove
+		case replication.PrefixRewrite != nil && replication.PrefixRewrite.From == replication.PrefixRewrite.To:
+			return fmt.Errorf("%w: prefix rewrite keeps the keys", ErrInvalidMove)
+		case opts.ObjectID != "" && replication.TargetObjectID(opts.ObjectID) == opts.ObjectID:
+			return fmt.Errorf("%w: %s", ErrInvalidMove, opts.ObjectID)
+		}
+	}
+	// Kept sources are deleted later, without the customer key which isn't stored
+	if opts.KeepSourceDays > 0 && replication.SourceEncryption != nil && replication.SourceEncryption.Mode == entity.SSEC {
+		return fmt.Errorf("%w: sources encrypted with SSE-C can't be kept", ErrInvalidMove)
+	}
+	return nil
+}
+
+// movesOntoItself reports whether the move would write the object over itself: the
+// verification would then compare it with itself and its only copy would be released.
+func movesOntoItself(sourceStorage, targetStorage entity.Storage, opts entity.MoveOptions, key string) bool {
+	return sourceStorage == targetStorage && opts.Sync.Replication.TargetObjectID(key) == key
+}
+
+// checkMoveTargets rejects moves writing any of the objects over itself, like keys
+// which the prefix rewrite doesn't match.
+func checkMoveTargets(sourceStorage, targetStorage entity.Storage, opts entity.MoveOptions, objects []*entity.Object) error {
+	for _, object := range objects {
+		if movesOntoItself(sourceStorage, targetStorage, opts, object.Name) {
+			return fmt.Errorf("%w: %s", ErrInvalidMove, object.Name)
+		}
+	}
+	return nil
+}
+
+// moveObjects returns the source objects selected by the move options.
+func moveObjects(ctx context.Context, repo entity.ObjectRepository, bucket string, opts entity.MoveOptions) ([]*entity.Object, error) {
+	if opts.ObjectID != "" {
+		object, err := repo.GetObject(ctx, bucket, opts.ObjectID, opts.Sync.Replication.SourceEncryption)
+		if err != nil {
+			return nil, err
+		}
+		if object == nil {
+			return nil, ErrObjectNotFound
+		}
+		return []*entity.Object{object}, nil
+	}
+
+	objects, err := repo.ListObjects(ctx, bucket, opts.Sync.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := objects[:0]
+	for _, object := range objects {
+		if opts.Sync.Match(object.Name) {
+			selected = append(selected, object)
+		}
+	}
+	return selected, nil
+}
+
+func (s *TaskService) runMove(ctx context.Context, taskID string, job *moveJob) {
+	log.Infof("task %s: move from bucket %s to bucket %s", taskID, job.source.Bucket, job.target.Bucket)
+	sourceRepo, err := openStorage(ctx, s.providerRepo, s.accountRepo, job.source)
+	if err != nil {
+		s.abortBulk(ctx, taskID, err)
+		return
+	}
+
+	objects, err := moveObjects(ctx, sourceRepo, job.source.Bucket, job.opts)
+	if err != nil {
+		s.abortBulk(ctx, taskID, err)
+		return
+	}
+	if err := checkMoveTargets(job.source, job.target, job.opts, objects); err != nil {
+		s.abortBulk(ctx, taskID, err)
+		return
+	}
+
+	for _, object := range objects {
+		childID := entity.NewTaskID()
+		s.mu.Lock()
+		job.sources[childID] = object.Name
+		s.mu.Unlock()
+
+		s.updateBulk(ctx, taskID, func(p *entity.TaskProgress) { p.Total++ })
+		err := s.enqueue(ctx, entity.ReplicationTask{
+			ID:             childID,
+			ParentID:       taskID,
+			ObjectID:       object.Name,
+			SourceStorage:  job.source,
+			TargetStorages: []entity.Storage{job.target},
+			Options:        job.opts.Sync.Replication,
+		})
+		if err != nil {
+			s.updateBulk(ctx, taskID, func(p *entity.TaskProgress) { p.Failed++ })
+		}
+	}
+
+	s.mu.Lock()
+	var snapshot *bulkSnapshot
+	bulk, exists := s.bulks[taskID]
+	if exists {
+		bulk.listed = true
+		snapshot = s.snapshotBulk(bulk, true)
+	}
+	s.mu.Unlock()
+	s.saveBulk(ctx, bulk, snapshot)
+}
+
+// moveChildFinished accounts a replicated object of a move and, unless the replication
+// failed, starts the verification and removal of its source. The removal is counted in
+// the total right away so the move is not completed before it.
+func (s *TaskService) moveChildFinished(ctx context.Context, job *moveJob, child *entity.Task) {
+	s.mu.Lock()
+	sourceKey := job.sources[child.ID]
+	delete(job.sources, child.ID)
+	s.mu.Unlock()
+
+	s.updateBulk(ctx, child.ParentID, func(p *entity.TaskProgress) {
+		switch child.Status {
+		case entity.TaskCompleted:
+			p.Copied++
+			p.Total++
+		case entity.TaskSkipped:
+			p.Skipped++
+			p.Total++
+		default:
+			p.Failed++
+		}
+	})
+	if child.Status == entity.TaskFailed {
+		return
+	}
+
+	targetKey := job.opts.Sync.Replication.TargetObjectID(sourceKey)
+	if len(child.Targets) > 0 {
+		targetKey = child.Targets[0].ObjectID
+	}
+	go s.finishMove(ctx, child.ParentID, job, sourceKey, targetKey)
+}
+
+func (s *TaskService) finishMove(ctx context.Context, taskID string, job *moveJob, sourceKey, targetKey string) {
+	var source *entity.Object
+	var err error
+	if job.source == job.target && sourceKey == targetKey {
+		// The copy is the source itself, releasing it would lose the object
+		err = fmt.Errorf("%w: %s", ErrInvalidMove, sourceKey)
+	} else {
+		source, err = s.verifyMove(ctx, taskID, job, sourceKey, targetKey)
+	}
+	if err == nil {
+		err = s.releaseSource(ctx, taskID, job, source)
+	}
+
+	s.updateBulk(ctx, taskID, func(p *entity.TaskProgress) {
+		if err != nil {
+			log.Errorf("task %s: failed to move %s: %v", taskID, sourceKey, err)
+			p.Failed++
+			return
+		}
+		p.Deleted++
+	})
+}
+
+// verifyMove checks the copy has the size and checksum of the source. ETags are compared
+// when both are plain MD5 digests; multipart ETags depend on the part size, so the
+// objects are hashed otherwise.
+func (s *TaskService) verifyMove(ctx context.Context, taskID string, job *moveJob, sourceKey, targetKey string) (*entity.Object, error) {
+	sourceRepo, err := openStorage(ctx, s.providerRepo, s.accountRepo, job.source)
+	if err != nil {
+		return nil, err
+	}
+	targetRepo, err := openStorage(ctx, s.providerRepo, s.accountRepo, job.target)
+	if err != nil {
+		return nil, err
+	}
+
+	replication := job.opts.Sync.Replication
+	source, err := sourceRepo.GetObject(ctx, job.source.Bucket, sourceKey, replication.SourceEncryption)
+	if err != nil {
+		return nil, err
+	}
+	target, err := targetRepo.GetObject(ctx, job.target.Bucket, targetKey, replication.TargetEncryption)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, ErrObjectNotFound
+	}
+	if target == nil || target.Size != source.Size {
+		return nil, ErrVerificationFailed
+	}
+
+	if isPlainETag(source.ETag) && isPlainETag(target.ETag) {
+		if source.ETag != target.ETag {
+			return nil, ErrVerificationFailed
+		}
+		return source, nil
+	}
+
+	sourceSum, err := s.checksum(ctx, sourceRepo, taskID, job.source, sourceKey, source.Size, replication.SourceEncryption)
+	if err != nil {
+		return nil, err
+	}
+	targetSum, err := s.checksum(ctx, targetRepo, taskID, job.target, targetKey, target.Size, replication.TargetEncryption)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(sourceSum, targetSum) {
+		return nil, ErrVerificationFailed
+	}
+	return source, nil
+}
+
+func isPlainETag(etag string) bool {
+	return etag != "" && !strings.Contains(etag, "-")
+}
+
+func (s *TaskService) checksum(ctx context.Context, repo entity.ObjectRepository, taskID string, storage entity.Storage, objectID string, size int64, sse *entity.ServerSideEncryption) ([]byte, error) {
+	hash := sha256.New()
+	if size == 0 {
+		return hash.Sum(nil), nil
+	}
+
+	reader, err := repo.StreamDownloadObject(ctx, storage.Bucket, objectID, 0, size-1, sse)
+	if err != nil {
+		return nil, err
+	}
+	if reader == nil {
+		return nil, ErrObjectNotFound
+	}
+	defer reader.Close()
+
+	_, err = io.Copy(hash, s.limiter.Reader(ctx, reader, taskID, storage.ProviderID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", objectID, err)
+	}
+	return hash.Sum(nil), nil
+}
+
+// releaseSource deletes the moved source object, or tags it as pending deletion when
+// the move keeps sources for a while.
+func (s *TaskService) releaseSource(ctx context.Context, taskID string, job *moveJob, source *entity.Object) error {
+	repo, err := openStorage(ctx, s.providerRepo, s.accountRepo, job.source)
+	if err != nil {
+		return err
+	}
+
+	if job.opts.KeepSourceDays <= 0 {
+		log.Infof("task %s: delete moved source %s", taskID, source.Name)
+		return repo.DeleteObject(ctx, job.source.Bucket, source.Name)
+	}
+
+	deleteAt := time.Now().AddDate(0, 0, job.opts.KeepSourceDays)
+	tags := make(map[string]string, len(source.Tags)+1)
+	for key, value := range source.Tags {
+		tags[key] = value
+	}
+	tags[entity.PendingDeletionTag] = deleteAt.UTC().Format(time.RFC3339)
+	err = repo.SetTags(ctx, job.source.Bucket, source.Name, tags)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("task %s: keep moved source %s until %s", taskID, source.Name, deleteAt)
+	return s.pendingRepo.Add(ctx, &entity.PendingDeletion{
+		ID:       entity.NewPendingDeletionID(),
+		TaskID:   taskID,
+		Storage:  job.source,
+		ObjectID: source.Name,
+		DeleteAt: deleteAt,
+	})
+}
+
+// PurgePendingDeletions deletes the kept sources of moves whose delay has passed.
+// Objects whose pending deletion tag was removed are kept.
+func (s *TaskService) PurgePendingDeletions(ctx context.Context, now time.Time) {
+	deletions, err := s.pendingRepo.ListDue(ctx, now)
+	if err != nil {
+		log.Errorf("failed to list pending deletions: %v", err)
+		return
+	}
+
+	for _, deletion := range deletions {
+		err := s.purge(ctx, deletion)
+		if err != nil {
+			log.Errorf("failed to delete moved source %s from bucket %s: %v", deletion.ObjectID, deletion.Storage.Bucket, err)
+			continue
+		}
+
+		err = s.pendingRepo.Delete(ctx, deletion.ID)
+		if err != nil {
+			log.Errorf("failed to remove pending deletion %s: %v", deletion.ID, err)
+		}
+	}
+}
+
+func (s *TaskService) purge(ctx context.Context, deletion *entity.PendingDeletion) error {
+	repo, err := openStorage(ctx, s.providerRepo, s.accountRepo, deletion.Storage)
+	if err != nil {
+		return err
+	}
+
+	object, err := repo.GetObject(ctx, deletion.Storage.Bucket, deletion.ObjectID, nil)
+	if err != nil {
+		return err
+	}
+	if object == nil {
+		return nil
+	}
+	if _, pending := object.Tags[entity.PendingDeletionTag]; !pending {
+		log.Infof("keep %s in bucket %s: pending deletion was cancelled", deletion.ObjectID, deletion.Storage.Bucket)
+		return nil
+	}
+
+	log.Infof("task %s: delete moved source %s", deletion.TaskID, deletion.ObjectID)
+	return repo.DeleteObject(ctx, deletion.Storage.Bucket, deletion.ObjectID)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestValidateMove(t *testing.T) {
+	source := entity.Storage{ProviderID: "p1", Bucket: "b1"}
+	other := entity.Storage{ProviderID: "p1", Bucket: "b2"}
+
+	tests := []struct {
+		name    string
+		target  entity.Storage
+		opts    entity.MoveOptions
+		wantErr bool
+	}{
+		{name: "other storage", target: other},
+		{name: "same storage without rewrite", target: source, wantErr: true},
+		{
+			name:   "same storage with prefix rewrite",
+			target: source,
+			opts:   entity.MoveOptions{Sync: entity.SyncOptions{Replication: entity.ReplicationOptions{PrefixRewrite: &entity.PrefixRewrite{From: "a/", To: "b/"}}}},
+		},
+		{
+			name:    "same storage with prefix rewrite keeping the keys",
+			target:  source,
+			opts:    entity.MoveOptions{Sync: entity.SyncOptions{Replication: entity.ReplicationOptions{PrefixRewrite: &entity.PrefixRewrite{From: "a/", To: "a/"}}}},
+			wantErr: true,
+		},
+		{
+			name:   "same storage with target key",
+			target: source,
+			opts:   entity.MoveOptions{ObjectID: "a.txt", Sync: entity.SyncOptions{Replication: entity.ReplicationOptions{TargetKey: "b.txt"}}},
+		},
+		{
+			name:    "same storage with target key of the object",
+			target:  source,
+			opts:    entity.MoveOptions{ObjectID: "a.txt", Sync: entity.SyncOptions{Replication: entity.ReplicationOptions{TargetKey: "a.txt"}}},
+			wantErr: true,
+		},
+		{
+			name:   "other storage with target key of the object",
+			target: other,
+			opts:   entity.MoveOptions{ObjectID: "a.txt", Sync: entity.SyncOptions{Replication: entity.ReplicationOptions{TargetKey: "a.txt"}}},
+		},
+		{
+			name:    "kept sources encrypted with SSE-C",
+			target:  other,
+			opts:    entity.MoveOptions{KeepSourceDays: 1, Sync: entity.SyncOptions{Replication: entity.ReplicationOptions{SourceEncryption: &entity.ServerSideEncryption{Mode: entity.SSEC}}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMove(source, tt.target, tt.opts)
+			if tt.wantErr != errors.Is(err, ErrInvalidMove) {
+				t.Fatalf("validateMove() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckMoveTargets(t *testing.T) {
+	source := entity.Storage{ProviderID: "p1", Bucket: "b1"}
+	other := entity.Storage{ProviderID: "p2", Bucket: "b1"}
+	objects := func(keys ...string) []*entity.Object {
+		var objects []*entity.Object
+		for _, key := range keys {
+			objects = append(objects, &entity.Object{Name: key})
+		}
+		return objects
+	}
+	rewrite := func(from, to string) entity.MoveOptions {
+		return entity.MoveOptions{Sync: entity.SyncOptions{Replication: entity.ReplicationOptions{PrefixRewrite: &entity.PrefixRewrite{From: from, To: to}}}}
+	}
+
+	tests := []struct {
+		name    string
+		target  entity.Storage
+		opts    entity.MoveOptions
+		objects []*entity.Object
+		wantErr bool
+	}{
+		{name: "every key rewritten", target: source, opts: rewrite("in/", "out/"), objects: objects("in/a", "in/b")},
+		{name: "key not matching the rewrite", target: source, opts: rewrite("in/", "out/"), objects: objects("in/a", "other/b"), wantErr: true},
+		{name: "rewrite keeping the keys", target: source, opts: rewrite("in/", "in/"), objects: objects("in/a"), wantErr: true},
+		{name: "key not matching the rewrite in another storage", target: other, opts: rewrite("in/", "out/"), objects: objects("other/b")},
+		{
+			name:    "target key of a listed object",
+			target:  source,
+			opts:    entity.MoveOptions{Sync: entity.SyncOptions{Replication: entity.ReplicationOptions{TargetKey: "b"}}},
+			objects: objects("a", "b"),
+			wantErr: true,
+		},
+		{name: "no objects", target: source, opts: rewrite("in/", "in/")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMoveTargets(source, tt.target, tt.opts, tt.objects)
+			if tt.wantErr != errors.Is(err, ErrInvalidMove) {
+				t.Fatalf("checkMoveTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// Upload-Metadata key selecting the placement strategy of an upload
+const placementKey = "placement"
+
+// Placement strategy types
+const (
+	PlacementRoundRobin = "round_robin"
+	PlacementWeighted   = "weighted"
+	PlacementLeastUsed  = "least_used"
+	PlacementPreference = "preference"
+	PlacementRules      = "rules"
+)
+
+// PlacementConfig declares named placement strategies. Storages are referred to as
+// "provider_id" or "provider_id/bucket"; the latter wins when both are set.
+type PlacementConfig struct {
+	// Default is the strategy of uploads which do not ask for one.
+	Default    string                             `yaml:"default,omitempty"`
+	Strategies map[string]PlacementStrategyConfig `yaml:"strategies,omitempty"`
+}
+
+type PlacementStrategyConfig struct {
+	Type string `yaml:"type"`
+	// Weights of the weighted strategy. Storages without a weight are never chosen.
+	Weights map[string]int `yaml:"weights,omitempty"`
+	// Capacities of the least_used strategy in bytes. When set, storages are ranked by
+	// the used fraction, and storages without a capacity or without room are left out.
+	Capacities map[string]int64 `yaml:"capacities,omitempty"`
+	// Providers of the preference strategy, most preferred first. Other providers are never chosen.
+	Providers []string `yaml:"providers,omitempty"`
+	// Rules of the rules strategy. The first matching rule selects the strategy.
+	Rules []PlacementRuleConfig `yaml:"rules,omitempty"`
+	// Fallback is the strategy used by the rules strategy when no rule matches.
+	Fallback string `yaml:"fallback,omitempty"`
+}
+
+type PlacementRuleConfig struct {
+	// Metadata lists glob patterns the upload metadata values must match.
+	Metadata map[string]string `yaml:"metadata,omitempty"`
+	MinSize  int64             `yaml:"min_size,omitempty"`
+	// MaxSize of zero means no upper limit.
+	MaxSize  int64  `yaml:"max_size,omitempty"`
+	Strategy string `yaml:"strategy"`
+}
+
+var (
+	DefaultPlacementConfig = PlacementConfig{
+		Default: PlacementRoundRobin,
+	}
+)
+
+// PlacementRequest describes the upload to place.
+type PlacementRequest struct {
+	Size     int64
+	Metadata map[string]string
+	// Copies is the number of ranked storages the upload is written to. Zero means one.
+	Copies int
+}
+
+// PlacementStrategy ranks the candidate storages of an upload. The upload goes to the
+// first storage, mirrored uploads to the first ones. Storages left out are not used.
+type PlacementStrategy interface {
+	Rank(ctx context.Context, req PlacementRequest, candidates []entity.Storage) ([]entity.Storage, error)
+}
+
+// Placement holds the configured strategies.
+type Placement struct {
+	strategies  map[string]PlacementStrategy
+	defaultName string
+}
+
+func NewPlacement(cfg PlacementConfig, uRepo entity.UploadRepository) (*Placement, error) {
+	p := &Placement{
+		// A round robin strategy is always available unless the name is configured
+		strategies:  map[string]PlacementStrategy{PlacementRoundRobin: &roundRobinPlacement{}},
+		defaultName: cfg.Default,
+	}
+	if p.defaultName == "" {
+		p.defaultName = PlacementRoundRobin
+	}
+
+	// Rules refer to other strategies, so they are built last
+	usage := &storageUsage{uploadRepo: uRepo}
+	rules := make(map[string]PlacementStrategyConfig)
+	for name, sc := range cfg.Strategies {
+		switch sc.Type {
+		case PlacementRoundRobin:
+			p.strategies[name] = &roundRobinPlacement{}
+		case PlacementWeighted:
+			p.strategies[name] = &weightedPlacement{weights: sc.Weights}
+		case PlacementLeastUsed:
+			p.strategies[name] = &leastUsedPlacement{usage: usage, capacities: sc.Capacities}
+		case PlacementPreference:
+			p.strategies[name] = &preferencePlacement{providers: sc.Providers}
+		case PlacementRules:
+			rules[name] = sc
+		default:
+			return nil, fmt.Errorf("placement strategy %s: unknown type %q", name, sc.Type)
+		}
+	}
+
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	built := make(map[string]bool)
+	for _, name := range names {
+		if err := p.buildRules(name, rules, built, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, exists := p.strategies[p.defaultName]; !exists {
+		return nil, fmt.Errorf("unknown default placement strategy %q", p.defaultName)
+	}
+	return p, nil
+}
+
+// buildRules builds a rules strategy after the rules strategies it refers to, so rules
+// may refer to each other in any order. Rules referring back to themselves are rejected.
+func (p *Placement) buildRules(name string, rules map[string]PlacementStrategyConfig, built map[string]bool, building []string) error {
+	if built[name] {
+		return nil
+	}
+	for _, other := range building {
+		if other == name {
+			return fmt.Errorf("placement strategy %s: rules refer back to it: %s", name, strings.Join(append(building, name), " -> "))
+		}
+	}
+	building = append(building, name)
+
+	lookup := func(ref string) (PlacementStrategy, error) {
+		if _, isRules := rules[ref]; isRules {
+			if err := p.buildRules(ref, rules, built, building); err != nil {
+				return nil, err
+			}
+		}
+		strategy, exists := p.strategies[ref]
+		if !exists {
+			return nil, fmt.Errorf("placement strategy %s: refers to unknown strategy %q", name, ref)
+		}
+		return strategy, nil
+	}
+
+	sc := rules[name]
+	strategy := &rulesPlacement{}
+	for _, rule := range sc.Rules {
+		target, err := lookup(rule.Strategy)
+		if err != nil {
+			return err
+		}
+		strategy.rules = append(strategy.rules, placementRule{PlacementRuleConfig: rule, strategy: target})
+	}
+	if sc.Fallback != "" {
+		fallback, err := lookup(sc.Fallback)
+		if err != nil {
+			return err
+		}
+		strategy.fallback = fallback
+	}
+	p.strategies[name] = strategy
+	built[name] = true
+	return nil
+}
+
+// Strategy returns the strategy selected by the upload metadata, or the default one.
+func (p *Placement) Strategy(metadata map[string]string) (PlacementStrategy, error) {
+	name := p.defaultName
+	if value, exists := metadata[placementKey]; exists {
+		name = value
+	}
+
+	strategy, exists := p.strategies[name]
+	if !exists {
+		return nil, ErrUnknownPlacement
+	}
+	return strategy, nil
+}
+
+// storageSetting looks a setting up by "provider/bucket" first, then by provider.
+func storageSetting[T any](settings map[string]T, storage entity.Storage) (T, bool) {
+	if value, exists := settings[storage.ProviderID+"/"+storage.Bucket]; exists {
+		return value, true
+	}
+	value, exists := settings[storage.ProviderID]
+	return value, exists
+}
+
+// roundRobinPlacement rotates the candidates so consecutive uploads start at the next storage.
+type roundRobinPlacement struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (s *roundRobinPlacement) Rank(ctx context.Context, req PlacementRequest, candidates []entity.Storage) ([]entity.Storage, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	start := s.next % len(candidates)
+	s.next++
+	s.mu.Unlock()
+
+	ranked := make([]entity.Storage, 0, len(candidates))
+	ranked = append(ranked, candidates[start:]...)
+	return append(ranked, candidates[:start]...), nil
+}
+
+// weightedPlacement draws storages at random in proportion to their weights.
+type weightedPlacement struct {
+	weights map[string]int
+}
+
+func (s *weightedPlacement) Rank(ctx context.Context, req PlacementRequest, candidates []entity.Storage) ([]entity.Storage, error) {
+	var pool []entity.Storage
+	var weights []int
+	total := 0
+	for _, storage := range candidates {
+		weight, _ := storageSetting(s.weights, storage)
+		if weight <= 0 {
+			continue
+		}
+		pool = append(pool, storage)
+		weights = append(weights, weight)
+		total += weight
+	}
+
+	ranked := make([]entity.Storage, 0, len(pool))
+	for len(pool) > 0 {
+		draw := rand.Intn(total)
+		i := 0
+		for ; draw >= weights[i]; i++ {
+			draw -= weights[i]
+		}
+		ranked = append(ranked, pool[i])
+		total -= weights[i]
+		pool = append(pool[:i], pool[i+1:]...)
+		weights = append(weights[:i], weights[i+1:]...)
+	}
+	return ranked, nil
+}
+
+// usageRefresh is how long the usage of storages is cached. Uploads placed meanwhile are
+// added to the cached usage.
+const usageRefresh = time.Minute
+
+// storageUsage caches the bytes of uploads held by every storage, whatever their tenant,
+// so placing an upload does not scan all uploads.
+type storageUsage struct {
+	uploadRepo entity.UploadRepository
+
+	mu        sync.Mutex
+	usage     map[entity.Storage]int64
+	refreshed time.Time
+}
+
+// get returns the usage of the storages. The returned map must not be modified.
+func (u *storageUsage) get(ctx context.Context) (map[entity.Storage]int64, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.usage != nil && time.Since(u.refreshed) < usageRefresh {
+		return u.usage, nil
+	}
+
+	// Storages are shared by tenants, so the usage of all of them counts
+	usage, err := u.uploadRepo.Usage(entity.WithTenant(ctx, ""))
+	if err != nil {
+		return nil, err
+	}
+	u.usage, u.refreshed = usage, time.Now()
+	return u.usage, nil
+}
+
+// add accounts an upload placed on the storage until the next refresh.
+func (u *storageUsage) add(storage entity.Storage, size int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.usage == nil {
+		return
+	}
+	usage := make(map[entity.Storage]int64, len(u.usage)+1)
+	for k, v := range u.usage {
+		usage[k] = v
+	}
+	usage[storage] += size
+	u.usage = usage
+}
+
+// leastUsedPlacement prefers the storages holding the fewest bytes of uploads.
+type leastUsedPlacement struct {
+	usage      *storageUsage
+	capacities map[string]int64
+}
+
+func (s *leastUsedPlacement) Rank(ctx context.Context, req PlacementRequest, candidates []entity.Storage) ([]entity.Storage, error) {
+	usage, err := s.usage.get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	type load struct {
+		storage entity.Storage
+		value   float64
+	}
+	loads := make([]load, 0, len(candidates))
+	for _, storage := range candidates {
+		used := usage[storage]
+		if len(s.capacities) == 0 {
+			loads = append(loads, load{storage: storage, value: float64(used)})
+			continue
+		}
+
+		capacity, exists := storageSetting(s.capacities, storage)
+		if !exists || capacity <= 0 || used+req.Size > capacity {
+			continue
+		}
+		loads = append(loads, load{storage: storage, value: float64(used) / float64(capacity)})
+	}
+
+	sort.SliceStable(loads, func(i, j int) bool {
+		return loads[i].value < loads[j].value
+	})
+
+	ranked := make([]entity.Storage, 0, len(loads))
+	for _, l := range loads {
+		ranked = append(ranked, l.storage)
+	}
+	// Every copy of the upload takes room on its storage
+	if copies := max(req.Copies, 1); len(ranked) >= copies {
+		for _, storage := range ranked[:copies] {
+			s.usage.add(storage, req.Size)
+		}
+	}
+	return ranked, nil
+}
+
+// preferencePlacement orders storages by the rank of their provider in the list.
+type preferencePlacement struct {
+	providers []string
+}
+
+func (s *preferencePlacement) Rank(ctx context.Context, req PlacementRequest, candidates []entity.Storage) ([]entity.Storage, error) {
+	var ranked []entity.Storage
+	for _, providerID := range s.providers {
+		for _, storage := range candidates {
+			if storage.ProviderID == providerID {
+				ranked = append(ranked, storage)
+			}
+		}
+	}
+	return ranked, nil
+}
+
+type placementRule struct {
+	PlacementRuleConfig
+	strategy PlacementStrategy
+}
+
+func (r *placementRule) matches(req PlacementRequest) bool {
+	if req.Size < r.MinSize || (r.MaxSize > 0 && req.Size > r.MaxSize) {
+		return false
+	}
+
+	for key, pattern := range r.Metadata {
+		value, exists := req.Metadata[key]
+		if !exists {
+			return false
+		}
+		if matched, _ := path.Match(pattern, value); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// rulesPlacement delegates to the strategy of the first rule matching the upload.
+type rulesPlacement struct {
+	rules    []placementRule
+	fallback PlacementStrategy
+}
+
+func (s *rulesPlacement) Rank(ctx context.Context, req PlacementRequest, candidates []entity.Storage) ([]entity.Storage, error) {
+	for _, rule := range s.rules {
+		if rule.matches(req) {
+			return rule.strategy.Rank(ctx, req, candidates)
+		}
+	}
+
+	if s.fallback == nil {
+		return candidates, nil
+	}
+	return s.fallback.Rank(ctx, req, candidates)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestLeastUsedPlacement(t *testing.T) {
+	a := entity.Storage{ProviderID: "p1", Bucket: "a"}
+	b := entity.Storage{ProviderID: "p1", Bucket: "b"}
+	c := entity.Storage{ProviderID: "p2", Bucket: "c"}
+	candidates := []entity.Storage{a, b, c}
+
+	tests := []struct {
+		name       string
+		capacities map[string]int64
+		req        PlacementRequest
+		// want is the ranking of two uploads placed one after the other
+		want [2][]entity.Storage
+	}{
+		{
+			name: "single copy",
+			req:  PlacementRequest{Size: 15},
+			want: [2][]entity.Storage{{a, b, c}, {b, a, c}},
+		},
+		{
+			name: "every copy counted",
+			req:  PlacementRequest{Size: 15, Copies: 2},
+			want: [2][]entity.Storage{{a, b, c}, {a, c, b}},
+		},
+		{
+			name:       "storages without room left out",
+			capacities: map[string]int64{"p1": 30, "p2/c": 40},
+			req:        PlacementRequest{Size: 20},
+			want:       [2][]entity.Storage{{a, b, c}, {b, c}},
+		},
+		{
+			name: "not enough storages for the copies",
+			req:  PlacementRequest{Size: 15, Copies: 4},
+			want: [2][]entity.Storage{{a, b, c}, {a, b, c}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploads := newMemUploads()
+			for _, upload := range []*entity.Upload{
+				{ObjectID: "in-b", TenantID: "acme", Storage: b, Size: 10, Status: entity.Complete},
+				{ObjectID: "in-c", TenantID: "globex", Storage: c, Size: 20, Status: entity.Active},
+			} {
+				uploads.Add(context.Background(), upload)
+			}
+			strategy := &leastUsedPlacement{usage: &storageUsage{uploadRepo: uploads}, capacities: tt.capacities}
+
+			for i, want := range tt.want {
+				ranked, err := strategy.Rank(context.Background(), tt.req, candidates)
+				if err != nil {
+					t.Fatalf("Rank() error = %v", err)
+				}
+				if !slices.Equal(ranked, want) {
+					t.Fatalf("Rank() of upload %d = %v, want %v", i+1, ranked, want)
+				}
+			}
+		})
+	}
+}
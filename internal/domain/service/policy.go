@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	log "github.com/sirupsen/logrus"
+)
+
+type PolicyService struct {
+	policyRepo  entity.PolicyRepository
+	taskService *TaskService
+}
+
+func NewPolicyService(pRepo entity.PolicyRepository, taskService *TaskService) *PolicyService {
+	return &PolicyService{
+		policyRepo:  pRepo,
+		taskService: taskService,
+	}
+}
+
+func (s *PolicyService) AddPolicy(ctx context.Context, policy entity.ReplicationPolicy) (string, error) {
+	log.Infof("add new replication policy")
+	if err := validatePolicy(&policy); err != nil {
+		return "", err
+	}
+
+	policy.ID = entity.NewPolicyID()
+	policy.TenantID, _ = entity.TenantFromContext(ctx)
+	err := s.policyRepo.Add(ctx, &policy)
+	if err != nil {
+		log.Errorf("failed to add policy: %v", err.Error())
+		return "", err
+	}
+	return policy.ID, nil
+}
+
+func (s *PolicyService) GetPolicy(ctx context.Context, policyID string) (*entity.ReplicationPolicy, error) {
+	policy, err := s.policyRepo.GetByID(ctx, policyID)
+	if err != nil {
+		log.Errorf("failed to find policy: %v", err.Error())
+		return nil, err
+	}
+
+	if policy == nil {
+		return nil, ErrPolicyNotFound
+	}
+	return policy, nil
+}
+
+func (s *PolicyService) ListPolicies(ctx context.Context) ([]*entity.ReplicationPolicy, error) {
+	policies, err := s.policyRepo.List(ctx)
+	if err != nil {
+		log.Errorf("failed to list policies: %v", err.Error())
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (s *PolicyService) UpdatePolicy(ctx context.Context, policy entity.ReplicationPolicy) error {
+	existing, err := s.GetPolicy(ctx, policy.ID)
+	if err != nil {
+		return err
+	}
+	policy.TenantID = existing.TenantID
+
+	if err := validatePolicy(&policy); err != nil {
+		return err
+	}
+
+	log.Infof("update policy %s", policy.ID)
+	err = s.policyRepo.Update(ctx, &policy)
+	if err != nil {
+		log.Errorf("failed to update policy: %v", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (s *PolicyService) DeletePolicy(ctx context.Context, policyID string) error {
+	if _, err := s.GetPolicy(ctx, policyID); err != nil {
+		return err
+	}
+
+	log.Infof("delete policy %s", policyID)
+	return s.policyRepo.Delete(ctx, policyID)
+}
+
+// Apply enqueues replication of the completed upload to the targets of every matching policy.
+// Each policy fans out to all of its targets from a single read of the upload.
+func (s *PolicyService) Apply(ctx context.Context, upload *entity.Upload) {
+	// The customer key isn't kept past the request, so the object can't be read later
+	if sse := upload.ServerSideEncryption; sse != nil && sse.Mode == entity.SSEC {
+		log.Infof("skip policies for object %s encrypted with SSE-C", upload.ObjectID)
+		return
+	}
+
+	// Only the policies of the tenant of the upload apply, whoever completed it
+	ctx = entity.WithTenant(ctx, upload.TenantID)
+	policies, err := s.policyRepo.List(ctx)
+	if err != nil {
+		log.Errorf("failed to apply policies to object %s: %v", upload.ObjectID, err)
+		return
+	}
+
+	for _, policy := range policies {
+		if policy.TenantID != upload.TenantID || !policy.Matches(upload) {
+			continue
+		}
+
+		stored := make(map[entity.Storage]bool)
+		for _, storage := range upload.Storages() {
+			stored[storage] = true
+		}
+
+		var targets []entity.Storage
+		for _, target := range policy.Targets {
+			if !stored[target] {
+				targets = append(targets, target)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		taskID, err := s.taskService.Replication(ctx, upload.ObjectID, upload.Storage, targets, entity.ReplicationOptions{})
+		if err != nil {
+			log.Errorf("policy %s: failed to replicate object %s: %v", policy.ID, upload.ObjectID, err)
+			continue
+		}
+		log.Infof("policy %s: replicate object %s to %d targets in task %s", policy.ID, upload.ObjectID, len(targets), taskID)
+	}
+}
+
+func validatePolicy(policy *entity.ReplicationPolicy) error {
+	if policy.Source.ProviderID == "" || len(policy.Targets) == 0 {
+		return ErrInvalidPolicy
+	}
+
+	if policy.MinSize < 0 || policy.MaxSize < 0 || (policy.MaxSize > 0 && policy.MaxSize < policy.MinSize) {
+		return ErrInvalidPolicy
+	}
+
+	for _, target := range policy.Targets {
+		if target.ProviderID == "" || target.Bucket == "" {
+			return ErrInvalidPolicy
+		}
+	}
+	return nil
+}
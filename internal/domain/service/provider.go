@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	log "github.com/sirupsen/logrus"
+)
+
+// ProviderConfig seeds a provider at startup. Providers already stored are left as they are.
+type ProviderConfig struct {
+	ID               string `yaml:"id"`
+	Name             string `yaml:"name"`
+	Endpoint         string `yaml:"endpoint"`
+	Region           string `yaml:"region,omitempty"`
+	PathStyle        bool   `yaml:"path_style,omitempty"`
+	SkipTLSVerify    bool   `yaml:"skip_tls_verify,omitempty"`
+	SignatureVersion string `yaml:"signature_version,omitempty"`
+	// Encryption is SSE-S3 or SSE-KMS, applied to objects written without one.
+	Encryption string `yaml:"encryption,omitempty"`
+	KMSKeyID   string `yaml:"kms_key_id,omitempty"`
+}
+
+var (
+	DefaultProviderConfigs = []ProviderConfig{
+		{
+			ID:       "1",
+			Name:     "Yandex Cloud",
+			Endpoint: "https://storage.yandexcloud.net",
+		},
+		{
+			ID:       "2",
+			Name:     "Timeweb",
+			Endpoint: "https://s3.twcstorage.ru",
+		},
+	}
+)
+
+func (c ProviderConfig) Provider() *entity.Provider {
+	provider := entity.NewProvider(c.ID, c.Name, c.Endpoint)
+	provider.Region = c.Region
+	provider.PathStyle = c.PathStyle
+	provider.SkipTLSVerify = c.SkipTLSVerify
+	if c.SignatureVersion != "" {
+		provider.SignatureVersion = entity.SignatureVersion(c.SignatureVersion)
+	}
+	if c.Encryption != "" {
+		mode, _ := entity.ParseSSEMode(c.Encryption)
+		provider.Encryption = &entity.ServerSideEncryption{Mode: mode, KMSKeyID: c.KMSKeyID}
+	}
+	return provider
+}
+
+type ProviderService struct {
+	providerRepo entity.ProviderRepository
+	accountRepo  entity.AccountRepository
+}
+
+func NewProviderService(pRepo entity.ProviderRepository, aRepo entity.AccountRepository) *ProviderService {
+	return &ProviderService{
+		providerRepo: pRepo,
+		accountRepo:  aRepo,
+	}
+}
+
+// Seed adds the configured providers which are not stored yet.
+func (s *ProviderService) Seed(ctx context.Context, providers []ProviderConfig) error {
+	for _, c := range providers {
+		existing, err := s.providerRepo.GetByID(ctx, c.ID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+
+		provider := c.Provider()
+		if provider.ID == "" || validateProvider(provider) != nil {
+			log.Errorf("skip invalid provider %q from configuration", c.Name)
+			continue
+		}
+
+		log.Infof("seed provider %s (%s)", provider.ID, provider.Name)
+		err = s.providerRepo.Add(ctx, provider)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ProviderService) AddProvider(ctx context.Context, provider *entity.Provider) (string, error) {
+	log.Infof("add new provider")
+	if err := validateProvider(provider); err != nil {
+		return "", err
+	}
+
+	if provider.ID == "" {
+		provider.ID = entity.NewProviderID()
+	} else {
+		existing, err := s.providerRepo.GetByID(ctx, provider.ID)
+		if err != nil {
+			return "", err
+		}
+		if existing != nil {
+			return "", ErrProviderExists
+		}
+	}
+
+	err := s.providerRepo.Add(ctx, provider)
+	if err != nil {
+		log.Errorf("failed to add provider: %v", err.Error())
+		return "", err
+	}
+	return provider.ID, nil
+}
+
+func (s *ProviderService) GetProvider(ctx context.Context, providerID string) (*entity.Provider, error) {
+	provider, err := s.providerRepo.GetByID(ctx, providerID)
+	if err != nil {
+		log.Errorf("failed to get provider %s: %v", providerID, err)
+		return nil, err
+	}
+
+	if provider == nil {
+		return nil, ErrProviderNotFound
+	}
+	return provider, nil
+}
+
+func (s *ProviderService) ListProviders(ctx context.Context) ([]*entity.Provider, error) {
+	providers, err := s.providerRepo.List(ctx)
+	if err != nil {
+		log.Errorf("failed to list providers: %v", err)
+		return nil, err
+	}
+	return providers, nil
+}
+
+func (s *ProviderService) UpdateProvider(ctx context.Context, provider *entity.Provider) error {
+	if _, err := s.GetProvider(ctx, provider.ID); err != nil {
+		return err
+	}
+
+	if err := validateProvider(provider); err != nil {
+		return err
+	}
+
+	log.Infof("update provider %s", provider.ID)
+	return s.providerRepo.Update(ctx, provider)
+}
+
+// DeleteProvider removes a provider which no account uses anymore.
+func (s *ProviderService) DeleteProvider(ctx context.Context, providerID string) error {
+	if _, err := s.GetProvider(ctx, providerID); err != nil {
+		return err
+	}
+
+	// Providers are shared, so accounts of every tenant count
+	accounts, err := s.accountRepo.ListByProvider(entity.WithTenant(ctx, ""), providerID)
+	if err != nil {
+		return err
+	}
+	if len(accounts) > 0 {
+		return ErrProviderInUse
+	}
+
+	log.Infof("delete provider %s", providerID)
+	return s.providerRepo.Delete(ctx, providerID)
+}
+
+func validateProvider(provider *entity.Provider) error {
+	if provider.Name == "" || !provider.SignatureVersion.Valid() {
+		return ErrInvalidProvider
+	}
+
+	// Keys of SSE-C would have to be stored with the provider
+	if sse := provider.Encryption; sse != nil && (sse.Mode == entity.SSEC || sse.Validate() != nil) {
+		return ErrInvalidProvider
+	}
+
+	endpoint, err := url.Parse(provider.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return ErrInvalidProvider
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestAddProvider(t *testing.T) {
+	provider := func(id, endpoint string, change func(p *entity.Provider)) *entity.Provider {
+		p := entity.NewProvider(id, "Storage", endpoint)
+		if change != nil {
+			change(p)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name     string
+		provider *entity.Provider
+		want     error
+	}{
+		{name: "provider", provider: provider("new", "https://s3.example.com", nil)},
+		{name: "generated id", provider: provider("", "http://minio:9000", nil)},
+		{
+			name: "SSE-KMS encryption",
+			provider: provider("kms", "https://s3.example.com", func(p *entity.Provider) {
+				p.Encryption = &entity.ServerSideEncryption{Mode: entity.SSEKMS, KMSKeyID: "key"}
+			}),
+		},
+		{name: "existing id", provider: provider("existing", "https://s3.example.com", nil), want: ErrProviderExists},
+		{name: "without name", provider: provider("new", "https://s3.example.com", func(p *entity.Provider) { p.Name = "" }), want: ErrInvalidProvider},
+		{name: "endpoint without scheme", provider: provider("new", "s3.example.com", nil), want: ErrInvalidProvider},
+		{name: "endpoint of another scheme", provider: provider("new", "ftp://s3.example.com", nil), want: ErrInvalidProvider},
+		{
+			name:     "unknown signature version",
+			provider: provider("new", "https://s3.example.com", func(p *entity.Provider) { p.SignatureVersion = "v2" }),
+			want:     ErrInvalidProvider,
+		},
+		{
+			name: "SSE-C encryption",
+			provider: provider("new", "https://s3.example.com", func(p *entity.Provider) {
+				p.Encryption = &entity.ServerSideEncryption{Mode: entity.SSEC}
+			}),
+			want: ErrInvalidProvider,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers := newMemProviders(entity.NewProvider("existing", "Existing", "https://s3.example.com"))
+			s := NewProviderService(providers, newMemAccounts())
+
+			id, err := s.AddProvider(context.Background(), tt.provider)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("AddProvider() error = %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			if added, err := s.GetProvider(context.Background(), id); err != nil || added.Endpoint != tt.provider.Endpoint {
+				t.Fatalf("GetProvider() = %+v, %v", added, err)
+			}
+		})
+	}
+}
+
+func TestDeleteProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		providerID string
+		want       error
+		wantKept   bool
+	}{
+		{name: "unused provider", providerID: "unused"},
+		{name: "provider used by another tenant", providerID: "used", want: ErrProviderInUse, wantKept: true},
+		{name: "unknown provider", providerID: "unknown", want: ErrProviderNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers := newMemProviders(
+				entity.NewProvider("used", "Used", "https://used.example.com"),
+				entity.NewProvider("unused", "Unused", "https://unused.example.com"),
+			)
+			accounts := newMemAccounts(&entity.ServiceAccount{ID: "globex-account", TenantID: "globex", ProviderID: "used"})
+			s := NewProviderService(providers, accounts)
+
+			err := s.DeleteProvider(entity.WithTenant(context.Background(), "acme"), tt.providerID)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("DeleteProvider() error = %v, want %v", err, tt.want)
+			}
+			if kept, _ := providers.GetByID(context.Background(), tt.providerID); (kept != nil) != tt.wantKept {
+				t.Fatalf("provider kept = %v, want %v", kept != nil, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestSeedProviders(t *testing.T) {
+	existing := entity.NewProvider("1", "Stored", "https://stored.example.com")
+	providers := newMemProviders(existing)
+	s := NewProviderService(providers, newMemAccounts())
+
+	err := s.Seed(context.Background(), []ProviderConfig{
+		{ID: "1", Name: "Configured", Endpoint: "https://configured.example.com"},
+		{ID: "2", Name: "New", Endpoint: "https://new.example.com", Encryption: "SSE-S3"},
+		{ID: "3", Name: "Invalid", Endpoint: "not a url"},
+		{ID: "4", Name: "Unknown encryption", Endpoint: "https://aes.example.com", Encryption: "AES256"},
+		{Name: "Without id", Endpoint: "https://noid.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+
+	list, _ := providers.List(context.Background())
+	if len(list) != 2 || list[0].Name != "Stored" || list[1].Name != "New" {
+		t.Fatalf("providers after Seed() = %+v, want the stored and the new one", list)
+	}
+	if list[1].Encryption == nil || list[1].Encryption.Mode != entity.SSES3 {
+		t.Fatalf("seeded encryption = %+v, want SSE-S3", list[1].Encryption)
+	}
+}
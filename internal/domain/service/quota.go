@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// QuotaConfig limits the uploads of a tenant or an API key. Zero means no limit.
+type QuotaConfig struct {
+	MaxObjectSize        int64 `yaml:"max_object_size,omitempty"`
+	MaxBytes             int64 `yaml:"max_bytes,omitempty"`
+	MaxObjects           int64 `yaml:"max_objects,omitempty"`
+	MaxConcurrentUploads int64 `yaml:"max_concurrent_uploads,omitempty"`
+}
+
+// QuotasConfig assigns quotas to callers. The quota of the API key, found by the token
+// subject, wins over the quota of the tenant, which wins over the default one. Quotas of
+// API keys count the uploads of the key; the others count the uploads of the tenant, or
+// of the subject for callers without a tenant. Quotas hold per instance: uploads created at
+// the same time on several instances may exceed them together.
+type QuotasConfig struct {
+	Default QuotaConfig            `yaml:"default,omitempty"`
+	Tenants map[string]QuotaConfig `yaml:"tenants,omitempty"`
+	APIKeys map[string]QuotaConfig `yaml:"api_keys,omitempty"`
+}
+
+var (
+	DefaultQuotasConfig = QuotasConfig{
+		Default: QuotaConfig{
+			MaxObjectSize: 500000000,
+		},
+	}
+)
+
+type Quotas struct {
+	cfg        QuotasConfig
+	uploadRepo entity.UploadRepository
+}
+
+func NewQuotas(cfg QuotasConfig, uRepo entity.UploadRepository) *Quotas {
+	return &Quotas{
+		cfg:        cfg,
+		uploadRepo: uRepo,
+	}
+}
+
+// quota returns the quota of the caller and the owner whose uploads it counts.
+func (q *Quotas) quota(ctx context.Context) (QuotaConfig, string) {
+	if subject, ok := entity.SubjectFromContext(ctx); ok {
+		if quota, exists := q.cfg.APIKeys[subject]; exists {
+			return quota, subject
+		}
+	}
+	tenantID, ok := entity.TenantFromContext(ctx)
+	if !ok {
+		// Uploads are not scoped to a tenant, so each caller has a quota of its own
+		subject, _ := entity.SubjectFromContext(ctx)
+		return q.cfg.Default, subject
+	}
+	if quota, exists := q.cfg.Tenants[tenantID]; exists {
+		return quota, ""
+	}
+	return q.cfg.Default, ""
+}
+
+// Check makes sure the caller may start an upload of the given size.
+func (q *Quotas) Check(ctx context.Context, size int64) error {
+	quota, owner := q.quota(ctx)
+	if quota.MaxObjectSize > 0 && size > quota.MaxObjectSize {
+		return fmt.Errorf("%w: %d bytes exceed the maximum object size of %d bytes", ErrUploadBig, size, quota.MaxObjectSize)
+	}
+
+	if quota.MaxBytes == 0 && quota.MaxObjects == 0 && quota.MaxConcurrentUploads == 0 {
+		return nil
+	}
+
+	stats, err := q.uploadRepo.Stats(ctx, owner)
+	if err != nil {
+		return fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	if quota.MaxBytes > 0 && stats.Bytes+size > quota.MaxBytes {
+		return fmt.Errorf("%w: %d of %d bytes used, %d more requested", ErrQuotaExceeded, stats.Bytes, quota.MaxBytes, size)
+	}
+	if quota.MaxObjects > 0 && stats.Objects >= quota.MaxObjects {
+		return fmt.Errorf("%w: %d of %d objects stored", ErrQuotaExceeded, stats.Objects, quota.MaxObjects)
+	}
+	if quota.MaxConcurrentUploads > 0 && stats.Active >= quota.MaxConcurrentUploads {
+		return fmt.Errorf("%w: %d of %d concurrent uploads in progress", ErrQuotaExceeded, stats.Active, quota.MaxConcurrentUploads)
+	}
+	return nil
+}
+
+// MaxUploadSize returns the size of the largest upload the caller may start now, the
+// lower of the maximum object size and the bytes left, and whether there is a limit at all.
+func (q *Quotas) MaxUploadSize(ctx context.Context) (int64, bool, error) {
+	quota, owner := q.quota(ctx)
+	if quota.MaxBytes == 0 {
+		return quota.MaxObjectSize, quota.MaxObjectSize > 0, nil
+	}
+
+	stats, err := q.uploadRepo.Stats(ctx, owner)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	left := max(quota.MaxBytes-stats.Bytes, 0)
+	if quota.MaxObjectSize > 0 && quota.MaxObjectSize < left {
+		return quota.MaxObjectSize, true, nil
+	}
+	return left, true, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestQuotasCheck(t *testing.T) {
+	uploads := newMemUploads()
+	for _, upload := range []entity.Upload{
+		{ObjectID: "acme-done", TenantID: "acme", Owner: "alice", Size: 60, Status: entity.Complete},
+		{ObjectID: "acme-active", TenantID: "acme", Owner: "frontend", Size: 20, Status: entity.Active},
+		{ObjectID: "acme-expired", TenantID: "acme", Owner: "alice", Size: 500, Status: entity.Expired},
+		{ObjectID: "globex-done", TenantID: "globex", Owner: "bob", Size: 90, Status: entity.Complete},
+		{ObjectID: "operator-done", Owner: "operator", Size: 90, Status: entity.Complete},
+	} {
+		if err := uploads.Add(context.Background(), &upload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q := NewQuotas(QuotasConfig{
+		Default: QuotaConfig{MaxObjectSize: 50, MaxBytes: 100},
+		Tenants: map[string]QuotaConfig{
+			"acme":    {MaxBytes: 100, MaxObjects: 3, MaxConcurrentUploads: 1},
+			"initech": {MaxObjects: 0},
+		},
+		APIKeys: map[string]QuotaConfig{"frontend": {MaxConcurrentUploads: 1}},
+	}, uploads)
+
+	caller := func(tenantID, subject string) context.Context {
+		return entity.WithSubject(entity.WithTenant(context.Background(), tenantID), subject)
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		size int64
+		want error
+	}{
+		{name: "within the default quota", ctx: caller("globex", "bob"), size: 10},
+		{name: "object over the maximum size", ctx: caller("globex", "bob"), size: 51, want: ErrUploadBig},
+		{name: "bytes over the tenant quota", ctx: caller("acme", "alice"), size: 30, want: ErrQuotaExceeded},
+		{name: "too many concurrent uploads of the tenant", ctx: caller("acme", "alice"), size: 10, want: ErrQuotaExceeded},
+		{name: "too many concurrent uploads of the API key", ctx: caller("acme", "frontend"), size: 10, want: ErrQuotaExceeded},
+		{name: "uploads of other tenants not counted", ctx: caller("initech", "carol"), size: 50},
+		{name: "bytes of the caller without a tenant", ctx: caller("", "operator"), size: 20, want: ErrQuotaExceeded},
+		{name: "other caller without a tenant", ctx: caller("", "dave"), size: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := q.Check(tt.ctx, tt.size)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Check() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateUploadOverQuota(t *testing.T) {
+	storage := entity.Storage{ProviderID: "p1", Bucket: "b1"}
+	storages := newFakeStorages(t, storage)
+	uploads := newMemUploads()
+	audit := &memAudit{}
+	s := newTestUploadService(t, storages, uploads, audit, QuotasConfig{Default: QuotaConfig{MaxObjects: 1}}, UploadConfig{})
+	ctx := entity.WithTenant(context.Background(), "acme")
+
+	if _, err := s.CreateUpload(ctx, 10, nil, &storage, nil); err != nil {
+		t.Fatalf("CreateUpload() error = %v", err)
+	}
+	if _, err := s.CreateUpload(ctx, 10, nil, &storage, nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("CreateUpload() over quota error = %v, want %v", err, ErrQuotaExceeded)
+	}
+	if _, err := s.CreateUpload(entity.WithTenant(context.Background(), "globex"), 10, nil, &storage, nil); err != nil {
+		t.Fatalf("CreateUpload() of another tenant error = %v", err)
+	}
+	if created := len(storages.bucket(storage).uploads); created != 2 {
+		t.Fatalf("multipart uploads = %d, want 2", created)
+	}
+}
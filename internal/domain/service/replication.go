@@ -1,15 +1,17 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/inview-team/gorynych/internal/domain/entity"
-	"github.com/inview-team/gorynych/internal/infrastructure/s3"
+	"github.com/inview-team/gorynych/internal/infrastructure/encryption"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -22,33 +24,80 @@ type ReplicationService struct {
 	results      chan<- entity.ReplicationResult
 	accountRepo  entity.AccountRepository
 	providerRepo entity.ProviderRepository
+	limiter      *BandwidthLimiter
 }
 
-type PartTask struct {
-	ObjectID       string
-	SourceAccount  *entity.ServiceAccount
-	SourceProvider *entity.Provider
-	TargetAccount  *entity.ServiceAccount
-	TargetProvider *entity.Provider
-	SourceBucket   string
-	TargetBucket   string
-	UploadID       string
-	PartNumber     int
-	Start          int64
-	End            int64
+// replicaTarget is one of the storages an object is replicated to, with the state of
+// its multipart upload. A target which failed is left out of the remaining parts.
+type replicaTarget struct {
+	index    int
+	storage  entity.Storage
+	provider *entity.Provider
+	repo     entity.ObjectRepository
+	objectID string
+	uploadID string
+	sse      *entity.ServerSideEncryption
+
+	mu    sync.Mutex
+	parts []entity.UploadPart
+	err   error
+}
+
+func (t *replicaTarget) addPart(position int, tag string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.parts = append(t.parts, entity.UploadPart{ID: tag, Position: position})
+}
+
+func (t *replicaTarget) fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+func (t *replicaTarget) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// finish completes the multipart upload, or aborts it if one of the parts failed.
+func (t *replicaTarget) finish(ctx context.Context) error {
+	if err := t.Err(); err != nil {
+		if abortErr := t.repo.AbortUpload(ctx, t.storage.Bucket, t.uploadID, t.objectID); abortErr != nil {
+			log.Warnf("failed to abort upload of %s to bucket %s: %v", t.objectID, t.storage.Bucket, abortErr)
+		}
+		return err
+	}
+
+	sort.Slice(t.parts, func(i, j int) bool {
+		return t.parts[i].Position < t.parts[j].Position
+	})
+	return t.repo.FinishUpload(ctx, t.storage.Bucket, t.uploadID, t.objectID, t.parts)
 }
 
-type PartResult struct {
-	PartNumber int
-	Tag        string
+type PartTask struct {
+	LimitID      string
+	ObjectID     string
+	Source       entity.ObjectRepository
+	SourceBucket string
+	SourceSSE    *entity.ServerSideEncryption
+	ProviderIDs  []string
+	Targets      []*replicaTarget
+	PartNumber   int
+	Start        int64
+	End          int64
 }
 
-func NewReplicationService(taskQueue <-chan entity.ReplicationTask, resultChan chan<- entity.ReplicationResult, aRepo entity.AccountRepository, pRepo entity.ProviderRepository) *ReplicationService {
+func NewReplicationService(taskQueue <-chan entity.ReplicationTask, resultChan chan<- entity.ReplicationResult, aRepo entity.AccountRepository, pRepo entity.ProviderRepository, limiter *BandwidthLimiter) *ReplicationService {
 	return &ReplicationService{
 		tasks:        taskQueue,
 		results:      resultChan,
 		accountRepo:  aRepo,
 		providerRepo: pRepo,
+		limiter:      limiter,
 	}
 }
 
@@ -56,173 +105,317 @@ func (s *ReplicationService) Start(ctx context.Context) {
 	go func() {
 		for task := range s.tasks {
 			start := time.Now()
-			err := s.replicate(ctx, &task)
+			// The task only sees the accounts of its tenant
+			targets, err := s.replicate(entity.WithTenant(ctx, task.TenantID), &task)
 			end := time.Now()
-			s.results <- entity.ReplicationResult{ID: task.ID, Start: start, End: end, Error: err}
+			s.results <- entity.ReplicationResult{ID: task.ID, Start: start, End: end, Targets: targets, Error: err}
 		}
 	}()
 }
 
-func (s *ReplicationService) replicate(ctx context.Context, task *entity.ReplicationTask) error {
-	log.Infof("get task to replicate %s from source %s to target %s", task.ObjectID, task.SourceStorage.Bucket, task.TargetStorage.Bucket)
+// replicate copies the object to every target storage. The source is read once and
+// every part is written to all targets at the same time. It returns the outcome for
+// each target; the error is set when no target got a copy, either because the source
+// couldn't be read or because every target failed.
+func (s *ReplicationService) replicate(ctx context.Context, task *entity.ReplicationTask) ([]entity.TargetStatus, error) {
+	log.Infof("get task to replicate %s from source %s to %d targets", task.ObjectID, task.SourceStorage.Bucket, len(task.TargetStorages))
 	sourceAccount, sourceProvider, err := s.getAccountByBucket(ctx, task.SourceStorage)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	log.Infof("check existence of  object with id %s", task.ObjectID)
-	sourceRepo, err := s3.New(ctx, sourceProvider.Endpoint, sourceAccount.Region, sourceAccount.AccessKey, sourceAccount.Secret)
-	object, err := sourceRepo.GetObject(ctx, task.SourceStorage.Bucket, task.ObjectID)
+	sourceRepo, err := newObjectRepository(ctx, sourceProvider, sourceAccount)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// The source is read with its SSE-C key and the copies written with the target encryption
+	object, err := sourceRepo.GetObject(ctx, task.SourceStorage.Bucket, task.ObjectID, task.Options.SourceEncryption)
+	if err != nil {
+		return nil, err
 	}
 
 	if object == nil {
-		return ErrObjectNotFound
+		return nil, ErrObjectNotFound
 	}
 
-	targetAccount, targetProvider, err := s.getAccountByBucket(ctx, task.TargetStorage)
-	if err != nil {
-		return err
+	targetObjectID := task.Options.TargetObjectID(task.ObjectID)
+	attrs := task.Options.TargetAttributes(object.ObjectAttributes)
+	// Encrypted objects are copied as they are, their copies need the same key
+	attrs.Metadata = encryption.KeepMetadata(object.Metadata, attrs.Metadata)
+	// Copies remember the ETag of their source, which their own ETag does not match
+	attrs.Metadata = entity.WithSourceETag(attrs.Metadata, object.ETag)
+	statuses := make([]entity.TargetStatus, len(task.TargetStorages))
+	providerIDs := []string{sourceProvider.ID}
+	var targets []*replicaTarget
+	for i, storage := range task.TargetStorages {
+		statuses[i] = entity.TargetStatus{Storage: storage, ObjectID: targetObjectID, Status: entity.TaskCompleted}
+		target, skip, err := s.openTarget(ctx, storage, targetObjectID, object, attrs, &task.Options)
+		if err != nil {
+			log.Errorf("failed to replicate %s to bucket %s: %v", task.ObjectID, storage.Bucket, err)
+			statuses[i].Status = entity.TaskFailed
+			statuses[i].Error = err.Error()
+			continue
+		}
+		if skip {
+			statuses[i].Status = entity.TaskSkipped
+			continue
+		}
+		target.index = i
+		targets = append(targets, target)
+		providerIDs = append(providerIDs, target.provider.ID)
 	}
-	targetRepo, err := s3.New(ctx, targetProvider.Endpoint, targetAccount.Region, targetAccount.AccessKey, targetAccount.Secret)
-	if err != nil {
-		return err
+
+	if len(targets) == 0 {
+		return statuses, failedTargets(statuses)
 	}
 
 	totalSize := object.Size
 	totalParts := int(int64(totalSize)+int64(chunkSize)-1) / chunkSize
-	fmt.Print(totalParts)
+	if totalParts == 0 {
+		// Empty objects are uploaded as a single empty part
+		totalParts = 1
+	}
 
-	uploadID, err := targetRepo.Create(ctx, task.TargetStorage.Bucket, task.ObjectID, nil)
-	if err != nil {
-		log.Errorf("failed to create upload: %v", err)
-		os.Exit(1)
+	// Bulk tasks share the bandwidth limit of the parent task
+	limitID := task.ID
+	if task.ParentID != "" {
+		limitID = task.ParentID
 	}
 
 	tasks := make(chan PartTask, totalParts)
-	results := make(chan PartResult)
 
 	var wg sync.WaitGroup
 	for i := 1; i <= totalParts; i++ {
 		wg.Add(1)
-		worker := NewWorker(i, tasks, results)
+		worker := NewWorker(i, tasks, s.limiter)
 		go worker.Start(ctx, &wg)
 	}
 
 	for part := 1; part <= totalParts; part++ {
 		start := int64(part-1) * int64(chunkSize)
 		end := start + int64(chunkSize) - 1
-		if end > totalSize {
-			end = totalSize
+		if end >= totalSize {
+			end = totalSize - 1
 		}
 
 		tasks <- PartTask{
-			ObjectID:       task.ObjectID,
-			SourceAccount:  sourceAccount,
-			SourceProvider: sourceProvider,
-			SourceBucket:   task.SourceStorage.Bucket,
-			TargetAccount:  targetAccount,
-			TargetProvider: targetProvider,
-			TargetBucket:   task.TargetStorage.Bucket,
-			UploadID:       uploadID,
-			PartNumber:     part,
-			Start:          start,
-			End:            end,
+			LimitID:      limitID,
+			ObjectID:     task.ObjectID,
+			Source:       sourceRepo,
+			SourceBucket: task.SourceStorage.Bucket,
+			SourceSSE:    task.Options.SourceEncryption,
+			ProviderIDs:  providerIDs,
+			Targets:      targets,
+			PartNumber:   part,
+			Start:        start,
+			End:          end,
 		}
 	}
 	close(tasks)
+	wg.Wait()
 
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	var uploadedParts []entity.UploadPart
-	for res := range results {
-		uploadedParts = append(uploadedParts, entity.UploadPart{ID: res.Tag, Position: res.PartNumber})
+	for _, target := range targets {
+		err := target.finish(ctx)
+		if err != nil {
+			log.Errorf("failed to replicate %s to bucket %s: %v", task.ObjectID, target.storage.Bucket, err)
+			statuses[target.index].Status = entity.TaskFailed
+			statuses[target.index].Error = err.Error()
+		}
 	}
 
-	sort.Slice(uploadedParts, func(i, j int) bool {
-		return uploadedParts[i].Position < uploadedParts[j].Position
-	})
+	return statuses, failedTargets(statuses)
+}
 
-	err = targetRepo.FinishUpload(ctx, task.TargetStorage.Bucket, uploadID, task.ObjectID, uploadedParts)
-	if err != nil {
-		log.Errorf("failed to finish upload: %v", err.Error())
+// failedTargets joins the errors of the targets when all of them failed.
+func failedTargets(statuses []entity.TargetStatus) error {
+	var errs []error
+	for _, status := range statuses {
+		if status.Status != entity.TaskFailed {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("bucket %s: %s", status.Storage.Bucket, status.Error))
 	}
-
-	return nil
+	return errors.Join(errs...)
 }
 
-func (s *ReplicationService) getAccountByBucket(ctx context.Context, st entity.Storage) (*entity.ServiceAccount, *entity.Provider, error) {
-	log.Info("search bucket")
-	provider, err := s.providerRepo.GetByID(ctx, st.ProviderID)
+// openTarget resolves the target storage and starts the multipart upload to it. It
+// reports whether the target was skipped because of the conflict policy.
+func (s *ReplicationService) openTarget(ctx context.Context, storage entity.Storage, objectID string, source *entity.Object, attrs entity.ObjectAttributes, opts *entity.ReplicationOptions) (*replicaTarget, bool, error) {
+	account, provider, err := s.getAccountByBucket(ctx, storage)
+	if err != nil {
+		return nil, false, err
+	}
+	repo, err := newObjectRepository(ctx, provider, account)
 	if err != nil {
-		log.Errorf("failed to choose account: failed to find provider: %v", err.Error())
-		return nil, nil, err
+		return nil, false, err
+	}
+
+	skip, err := s.resolveConflict(ctx, repo, storage.Bucket, objectID, source, opts)
+	if err != nil || skip {
+		return nil, skip, err
 	}
-	accounts, err := s.accountRepo.ListByProvider(ctx, st.ProviderID)
 
+	uploadID, err := repo.Create(ctx, storage.Bucket, objectID, attrs, opts.TargetEncryption)
 	if err != nil {
-		log.Errorf("failed to choose account: failed to list accounts: %v", err.Error())
-		return nil, nil, err
+		return nil, false, fmt.Errorf("failed to create upload: %w", err)
 	}
 
-	if len(accounts) == 0 {
-		return nil, nil, ErrNoAvailableAccounts
+	return &replicaTarget{
+		storage:  storage,
+		provider: provider,
+		repo:     repo,
+		objectID: objectID,
+		uploadID: uploadID,
+		sse:      opts.TargetEncryption,
+	}, false, nil
+}
+
+func (s *ReplicationService) resolveConflict(ctx context.Context, targetRepo entity.ObjectRepository, bucket string, objectID string, source *entity.Object, opts *entity.ReplicationOptions) (bool, error) {
+	policy := opts.ConflictPolicy
+	if policy == entity.ConflictOverwrite || policy == 0 {
+		return false, nil
 	}
 
-	for _, account := range accounts {
-		oRepo, err := s3.New(ctx, provider.Endpoint, account.Region, account.AccessKey, account.Secret)
-		if err != nil {
-			log.Errorf("failed to init storage by account with id: %s", account.ID)
-			continue
-		}
+	existing, err := targetRepo.GetObject(ctx, bucket, objectID, opts.TargetEncryption)
+	if err != nil {
+		return false, err
+	}
 
-		exists, err := oRepo.IsBucketExist(ctx, st.Bucket)
-		if err != nil {
-			continue
-		}
+	if existing == nil {
+		return false, nil
+	}
 
-		if !exists {
-			continue
+	switch policy {
+	case entity.ConflictSkipIfExists:
+		log.Infof("skip object %s: already exists in target bucket %s", objectID, bucket)
+		return true, nil
+	case entity.ConflictSkipIfSame:
+		if entity.SameObject(source, existing) {
+			log.Infof("skip object %s: same object exists in target bucket %s", objectID, bucket)
+			return true, nil
 		}
-		return account, provider, nil
+		return false, nil
+	case entity.ConflictFail:
+		return false, ErrObjectExists
 	}
+	return false, nil
+}
 
-	return nil, nil, ErrNoAvailableBuckets
+func (s *ReplicationService) getAccountByBucket(ctx context.Context, st entity.Storage) (*entity.ServiceAccount, *entity.Provider, error) {
+	return findAccountByBucket(ctx, s.providerRepo, s.accountRepo, st)
 }
 
 type ReplicationWorker struct {
 	id      int
 	tasks   <-chan PartTask
-	results chan<- PartResult
+	limiter *BandwidthLimiter
 }
 
-func NewWorker(id int, tasks <-chan PartTask, results chan<- PartResult) *ReplicationWorker {
+func NewWorker(id int, tasks <-chan PartTask, limiter *BandwidthLimiter) *ReplicationWorker {
 	return &ReplicationWorker{
 		id:      id,
 		tasks:   tasks,
-		results: results,
+		limiter: limiter,
 	}
 }
 
 func (w *ReplicationWorker) Start(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for task := range w.tasks {
-		sourceRepo, _ := s3.New(ctx, task.SourceProvider.Endpoint, task.SourceAccount.Region, task.SourceAccount.AccessKey, task.SourceAccount.Secret)
-		targetRepo, _ := s3.New(ctx, task.TargetProvider.Endpoint, task.TargetAccount.Region, task.TargetAccount.AccessKey, task.TargetAccount.Secret)
 		log.Infof("worker%d: part %d: processing bytes %d-%d...", w.id, task.PartNumber, task.Start, task.End)
-		reader, err := sourceRepo.StreamDownloadObject(ctx, task.SourceBucket, task.ObjectID, task.Start, task.End)
-		if err != nil {
-			log.Errorf("failed to download: %v", err.Error())
+		w.copyPart(ctx, &task)
+		log.Infof("worker%d: part %d: DONE", w.id, task.PartNumber)
+	}
+}
+
+// copyPart downloads the part once and tees it to every target which has not failed yet.
+// Failures are recorded on the targets.
+func (w *ReplicationWorker) copyPart(ctx context.Context, task *PartTask) {
+	var targets []*replicaTarget
+	for _, target := range task.Targets {
+		if target.Err() == nil {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	var reader io.ReadCloser
+	if task.End < task.Start {
+		reader = io.NopCloser(bytes.NewReader(nil))
+	} else {
+		var err error
+		reader, err = task.Source.StreamDownloadObject(ctx, task.SourceBucket, task.ObjectID, task.Start, task.End, task.SourceSSE)
+		if err == nil && reader == nil {
+			err = ErrObjectNotFound
 		}
-		partID, err := targetRepo.StreamWritePart(ctx, task.TargetBucket, task.UploadID, task.ObjectID, task.PartNumber, reader)
 		if err != nil {
-			log.Errorf("failed to download: %v", err.Error())
+			for _, target := range targets {
+				target.fail(fmt.Errorf("failed to download part %d: %w", task.PartNumber, err))
+			}
+			return
 		}
-		w.results <- PartResult{PartNumber: task.PartNumber, Tag: partID}
-		log.Infof("worker%d: part %d: DONE", w.id, task.PartNumber)
 	}
+	defer reader.Close()
+	reader = w.limiter.Reader(ctx, reader, task.LimitID, task.ProviderIDs...)
+
+	size := task.End - task.Start + 1
+	writers := make([]*io.PipeWriter, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		pr, pw := io.Pipe()
+		writers[i] = pw
+		wg.Add(1)
+		go func(target *replicaTarget, pr *io.PipeReader) {
+			defer wg.Done()
+			tag, err := target.repo.StreamWritePart(ctx, target.storage.Bucket, target.uploadID, target.objectID, task.PartNumber, pr, size, target.sse)
+			// Unblock the tee if the upload stopped reading early
+			pr.CloseWithError(errTargetClosed)
+			if err != nil {
+				log.Errorf("worker%d: part %d: failed to upload to bucket %s: %v", w.id, task.PartNumber, target.storage.Bucket, err)
+				target.fail(fmt.Errorf("failed to upload part %d: %w", task.PartNumber, err))
+				return
+			}
+			target.addPart(task.PartNumber, tag)
+		}(target, pr)
+	}
+
+	_, err := io.Copy(newTeeWriter(writers), reader)
+	for _, pw := range writers {
+		pw.CloseWithError(err)
+	}
+	wg.Wait()
+}
+
+var errTargetClosed = errors.New("target stopped reading")
+
+// teeWriter writes to every writer and drops the ones which failed, so a broken target
+// does not stop the others. It fails only when no writer is left. Pipes are synchronous,
+// so the part is copied at the pace of the slowest target.
+type teeWriter struct {
+	writers []io.Writer
+}
+
+func newTeeWriter(pipes []*io.PipeWriter) *teeWriter {
+	writers := make([]io.Writer, len(pipes))
+	for i, pw := range pipes {
+		writers[i] = pw
+	}
+	return &teeWriter{writers: writers}
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	live := t.writers[:0]
+	for _, w := range t.writers {
+		if _, err := w.Write(p); err == nil {
+			live = append(live, w)
+		}
+	}
+	t.writers = live
+	if len(live) == 0 {
+		return 0, errTargetClosed
+	}
+	return len(p), nil
 }
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestFailedTargets(t *testing.T) {
+	failed := func(bucket string) entity.TargetStatus {
+		return entity.TargetStatus{Storage: entity.Storage{Bucket: bucket}, Status: entity.TaskFailed, Error: "access denied"}
+	}
+	completed := entity.TargetStatus{Storage: entity.Storage{Bucket: "ok"}, Status: entity.TaskCompleted}
+	skipped := entity.TargetStatus{Storage: entity.Storage{Bucket: "same"}, Status: entity.TaskSkipped}
+
+	tests := []struct {
+		name     string
+		statuses []entity.TargetStatus
+		wantErr  bool
+	}{
+		{name: "every target copied", statuses: []entity.TargetStatus{completed, completed}},
+		{name: "some targets failed", statuses: []entity.TargetStatus{failed("a"), completed}},
+		{name: "failed and skipped targets", statuses: []entity.TargetStatus{failed("a"), skipped}},
+		{name: "every target failed", statuses: []entity.TargetStatus{failed("a"), failed("b")}, wantErr: true},
+		{name: "single failed target", statuses: []entity.TargetStatus{failed("a")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := failedTargets(tt.statuses)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("failedTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken")
+}
+
+func TestTeeWriter(t *testing.T) {
+	var first, second bytes.Buffer
+
+	tests := []struct {
+		name    string
+		writers []io.Writer
+		wantErr bool
+	}{
+		{name: "every writer", writers: []io.Writer{&first, &second}},
+		{name: "broken writer dropped", writers: []io.Writer{failingWriter{}, &first}},
+		{name: "every writer broken", writers: []io.Writer{failingWriter{}, failingWriter{}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first.Reset()
+			second.Reset()
+			tee := &teeWriter{writers: tt.writers}
+			for _, chunk := range []string{"hello ", "world"} {
+				_, err := tee.Write([]byte(chunk))
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Write() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			}
+			for _, w := range tee.writers {
+				if b, ok := w.(*bytes.Buffer); ok && b.String() != "hello world" {
+					t.Fatalf("writer got %q", b.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// Number of runs kept in the schedule history
+const scheduleHistorySize = 20
+
+type SchedulerConfig struct {
+	// Interval between checks of due schedules
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+var (
+	DefaultSchedulerConfig = SchedulerConfig{
+		Interval: 15 * time.Second,
+	}
+)
+
+type SchedulerService struct {
+	scheduleRepo entity.ScheduleRepository
+	taskRepo     entity.TaskRepository
+	taskService  *TaskService
+	interval     time.Duration
+}
+
+func NewSchedulerService(sRepo entity.ScheduleRepository, tRepo entity.TaskRepository, taskService *TaskService, cfg SchedulerConfig) *SchedulerService {
+	return &SchedulerService{
+		scheduleRepo: sRepo,
+		taskRepo:     tRepo,
+		taskService:  taskService,
+		interval:     cfg.Interval,
+	}
+}
+
+func (s *SchedulerService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.runDue(ctx, now)
+				s.taskService.PurgePendingDeletions(ctx, now)
+			}
+		}
+	}()
+}
+
+func (s *SchedulerService) AddSchedule(ctx context.Context, schedule entity.Schedule) (string, error) {
+	log.Infof("add new schedule")
+	spec, err := cron.ParseStandard(schedule.Cron)
+	if err != nil {
+		return "", ErrInvalidCron
+	}
+
+	switch schedule.Mode {
+	case entity.ScheduleReplication:
+		if schedule.ObjectID == "" {
+			return "", ErrInvalidSchedule
+		}
+	case entity.ScheduleSync, entity.ScheduleMirror:
+	default:
+		return "", ErrInvalidSchedule
+	}
+
+	// Customer keys are not stored, so schedules cannot use SSE-C
+	replication := schedule.Options.Replication
+	if replication.SourceEncryption != nil || (replication.TargetEncryption != nil && replication.TargetEncryption.Mode == entity.SSEC) {
+		return "", ErrInvalidSchedule
+	}
+
+	schedule.ID = entity.NewScheduleID()
+	schedule.TenantID, _ = entity.TenantFromContext(ctx)
+	schedule.NextRun = spec.Next(time.Now())
+	err = s.scheduleRepo.Add(ctx, &schedule)
+	if err != nil {
+		log.Errorf("failed to add schedule: %v", err.Error())
+		return "", err
+	}
+	return schedule.ID, nil
+}
+
+func (s *SchedulerService) GetSchedule(ctx context.Context, scheduleID string) (*entity.Schedule, error) {
+	schedule, err := s.scheduleRepo.GetByID(ctx, scheduleID)
+	if err != nil {
+		log.Errorf("failed to find schedule: %v", err.Error())
+		return nil, err
+	}
+
+	if schedule == nil {
+		return nil, ErrScheduleNotFound
+	}
+
+	s.refreshHistory(ctx, schedule)
+	return schedule, nil
+}
+
+func (s *SchedulerService) ListSchedules(ctx context.Context) ([]*entity.Schedule, error) {
+	schedules, err := s.scheduleRepo.List(ctx)
+	if err != nil {
+		log.Errorf("failed to list schedules: %v", err.Error())
+		return nil, err
+	}
+
+	for _, schedule := range schedules {
+		s.refreshHistory(ctx, schedule)
+	}
+	return schedules, nil
+}
+
+func (s *SchedulerService) DeleteSchedule(ctx context.Context, scheduleID string) error {
+	if _, err := s.GetSchedule(ctx, scheduleID); err != nil {
+		return err
+	}
+
+	log.Infof("delete schedule %s", scheduleID)
+	return s.scheduleRepo.Delete(ctx, scheduleID)
+}
+
+func (s *SchedulerService) runDue(ctx context.Context, now time.Time) {
+	schedules, err := s.scheduleRepo.List(ctx)
+	if err != nil {
+		log.Errorf("failed to list schedules: %v", err.Error())
+		return
+	}
+
+	for _, schedule := range schedules {
+		// Tasks of the schedule belong to its tenant and only use the accounts of it
+		ctx := entity.WithTenant(ctx, schedule.TenantID)
+		changed := s.refreshHistory(ctx, schedule)
+		if now.Before(schedule.NextRun) {
+			if changed {
+				s.save(ctx, schedule)
+			}
+			continue
+		}
+
+		if s.run(ctx, schedule, now) {
+			s.save(ctx, schedule)
+		}
+	}
+}
+
+// run starts the due run of the schedule. Every instance checks the schedules, so the
+// run is claimed first; it reports false when the schedule was left as it is.
+func (s *SchedulerService) run(ctx context.Context, schedule *entity.Schedule, now time.Time) bool {
+	spec, err := cron.ParseStandard(schedule.Cron)
+	if err != nil {
+		log.Errorf("schedule %s has invalid cron expression %q", schedule.ID, schedule.Cron)
+		return false
+	}
+	next := spec.Next(now)
+	claimed, err := s.scheduleRepo.ClaimRun(ctx, schedule.ID, schedule.NextRun, next)
+	if err != nil {
+		log.Errorf("schedule %s: failed to claim run: %v", schedule.ID, err)
+		return false
+	}
+	if !claimed {
+		log.Infof("schedule %s: run claimed by another instance", schedule.ID)
+		return false
+	}
+	schedule.LastRun = now
+	schedule.NextRun = next
+
+	run := entity.ScheduleRun{Time: now}
+	if s.isRunning(schedule) {
+		log.Infof("schedule %s: skip run, task %s is still in progress", schedule.ID, schedule.LastTaskID)
+		run.Status = entity.TaskSkipped
+		run.Error = "previous run is still in progress"
+		s.addRun(schedule, run)
+		return true
+	}
+
+	taskID, err := s.startTask(ctx, schedule)
+	if err != nil {
+		log.Errorf("schedule %s: failed to start task: %v", schedule.ID, err)
+		run.Status = entity.TaskFailed
+		run.Error = err.Error()
+		s.addRun(schedule, run)
+		return true
+	}
+
+	log.Infof("schedule %s: started task %s", schedule.ID, taskID)
+	schedule.LastTaskID = taskID
+	run.TaskID = taskID
+	run.Status = entity.TaskCreated
+	s.addRun(schedule, run)
+	return true
+}
+
+func (s *SchedulerService) startTask(ctx context.Context, schedule *entity.Schedule) (string, error) {
+	switch schedule.Mode {
+	case entity.ScheduleReplication:
+		return s.taskService.Replication(ctx, schedule.ObjectID, schedule.SourceStorage, []entity.Storage{schedule.TargetStorage}, schedule.Options.Replication)
+	case entity.ScheduleSync:
+		return s.taskService.Sync(ctx, schedule.SourceStorage, schedule.TargetStorage, schedule.Options)
+	case entity.ScheduleMirror:
+		return s.taskService.Mirror(ctx, schedule.SourceStorage, schedule.TargetStorage, schedule.Options)
+	}
+	return "", ErrInvalidSchedule
+}
+
+func (s *SchedulerService) isRunning(schedule *entity.Schedule) bool {
+	for _, run := range schedule.History {
+		if run.TaskID != "" && run.Status == entity.TaskCreated {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SchedulerService) addRun(schedule *entity.Schedule, run entity.ScheduleRun) {
+	schedule.History = append(schedule.History, run)
+	if len(schedule.History) > scheduleHistorySize {
+		schedule.History = schedule.History[len(schedule.History)-scheduleHistorySize:]
+	}
+}
+
+// refreshHistory copies the status of finished tasks to the history. It reports
+// whether any run changed.
+func (s *SchedulerService) refreshHistory(ctx context.Context, schedule *entity.Schedule) bool {
+	changed := false
+	for i, run := range schedule.History {
+		if run.TaskID == "" || run.Status != entity.TaskCreated {
+			continue
+		}
+
+		task, err := s.taskRepo.GetByID(ctx, run.TaskID)
+		if err != nil {
+			log.Errorf("failed to get task %s of schedule %s: %v", run.TaskID, schedule.ID, err)
+			continue
+		}
+
+		if task == nil {
+			schedule.History[i].Status = entity.TaskFailed
+			schedule.History[i].Error = "task not found"
+			changed = true
+			continue
+		}
+
+		if task.Status != entity.TaskCreated {
+			schedule.History[i].Status = task.Status
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (s *SchedulerService) save(ctx context.Context, schedule *entity.Schedule) {
+	err := s.scheduleRepo.Update(ctx, schedule)
+	if err != nil {
+		log.Errorf("failed to save schedule %s: %v", schedule.ID, err)
+	}
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"github.com/robfig/cron/v3"
+)
+
+// memSchedules keeps schedules in memory, scoped to the tenant of the context. listed,
+// when set, runs after every List.
+type memSchedules struct {
+	mu        sync.Mutex
+	schedules map[string]entity.Schedule
+	listed    func()
+}
+
+func newMemSchedules() *memSchedules {
+	return &memSchedules{schedules: make(map[string]entity.Schedule)}
+}
+
+func (r *memSchedules) Add(_ context.Context, schedule *entity.Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schedules[schedule.ID] = *schedule
+	return nil
+}
+
+func (r *memSchedules) GetByID(ctx context.Context, scheduleID string) (*entity.Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schedule, exists := r.schedules[scheduleID]
+	if !exists || !entity.InTenant(ctx, schedule.TenantID) {
+		return nil, nil
+	}
+	return &schedule, nil
+}
+
+func (r *memSchedules) List(ctx context.Context) ([]*entity.Schedule, error) {
+	r.mu.Lock()
+	var schedules []*entity.Schedule
+	for _, schedule := range r.schedules {
+		if entity.InTenant(ctx, schedule.TenantID) {
+			schedule := schedule
+			schedules = append(schedules, &schedule)
+		}
+	}
+	listed := r.listed
+	r.mu.Unlock()
+
+	if listed != nil {
+		listed()
+	}
+	return schedules, nil
+}
+
+func (r *memSchedules) Update(_ context.Context, schedule *entity.Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schedules[schedule.ID] = *schedule
+	return nil
+}
+
+func (r *memSchedules) ClaimRun(_ context.Context, scheduleID string, due, next time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schedule, exists := r.schedules[scheduleID]
+	if !exists || !schedule.NextRun.Equal(due) {
+		return false, nil
+	}
+	schedule.NextRun = next
+	r.schedules[scheduleID] = schedule
+	return true, nil
+}
+
+func (r *memSchedules) Delete(_ context.Context, scheduleID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.schedules, scheduleID)
+	return nil
+}
+
+func newTestScheduler(t *testing.T, schedules *memSchedules, tasks *memTasks) *SchedulerService {
+	t.Helper()
+	storages := newFakeStorages(t, entity.Storage{ProviderID: "p1", Bucket: "source"}, entity.Storage{ProviderID: "p1", Bucket: "target"})
+	taskService := NewTaskService(storages.accounts(), storages.providers(), tasks, nil, NewBandwidthLimiter(BandwidthConfig{}), NewAuditService(&memAudit{}), 0)
+	return NewSchedulerService(schedules, tasks, taskService, SchedulerConfig{Interval: time.Minute})
+}
+
+func TestAddSchedule(t *testing.T) {
+	storages := entity.Schedule{SourceStorage: entity.Storage{ProviderID: "p1", Bucket: "source"}, TargetStorage: entity.Storage{ProviderID: "p1", Bucket: "target"}}
+	schedule := func(cron string, mode entity.ScheduleMode, objectID string, opts entity.SyncOptions) entity.Schedule {
+		s := storages
+		s.Cron, s.Mode, s.ObjectID, s.Options = cron, mode, objectID, opts
+		return s
+	}
+	customerKey := entity.SyncOptions{Replication: entity.ReplicationOptions{TargetEncryption: &entity.ServerSideEncryption{Mode: entity.SSEC}}}
+
+	tests := []struct {
+		name     string
+		schedule entity.Schedule
+		want     error
+	}{
+		{name: "sync", schedule: schedule("*/5 * * * *", entity.ScheduleSync, "", entity.SyncOptions{})},
+		{name: "replication", schedule: schedule("@daily", entity.ScheduleReplication, "a.txt", entity.SyncOptions{})},
+		{name: "invalid cron", schedule: schedule("every day", entity.ScheduleSync, "", entity.SyncOptions{}), want: ErrInvalidCron},
+		{name: "replication without object", schedule: schedule("@daily", entity.ScheduleReplication, "", entity.SyncOptions{}), want: ErrInvalidSchedule},
+		{name: "unknown mode", schedule: schedule("@daily", 0, "", entity.SyncOptions{}), want: ErrInvalidSchedule},
+		{name: "SSE-C target", schedule: schedule("@daily", entity.ScheduleMirror, "", customerKey), want: ErrInvalidSchedule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedules := newMemSchedules()
+			s := newTestScheduler(t, schedules, newMemTasks())
+			ctx := entity.WithTenant(context.Background(), "acme")
+
+			id, err := s.AddSchedule(ctx, tt.schedule)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("AddSchedule() error = %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			added, _ := s.GetSchedule(ctx, id)
+			if added == nil || added.TenantID != "acme" || !added.NextRun.After(time.Now()) {
+				t.Fatalf("added schedule = %+v, want a future run in the tenant", added)
+			}
+			if _, err := s.GetSchedule(entity.WithTenant(context.Background(), "globex"), id); !errors.Is(err, ErrScheduleNotFound) {
+				t.Fatalf("GetSchedule() of another tenant error = %v, want %v", err, ErrScheduleNotFound)
+			}
+		})
+	}
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 2, 0, 0, time.UTC)
+	spec, err := cron.ParseStandard("*/5 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := entity.ScheduleRun{Time: now.Add(-5 * time.Minute), TaskID: "previous", Status: entity.TaskCreated}
+
+	tests := []struct {
+		name    string
+		nextRun time.Time
+		history []entity.ScheduleRun
+		// previousStatus is the status of the task of the previous run
+		previousStatus entity.TaskStatus
+		// claimedElsewhere makes another instance claim the run after the listing
+		claimedElsewhere bool
+		wantNextRun      time.Time
+		wantRuns         []entity.TaskStatus
+	}{
+		{
+			name:        "not due",
+			nextRun:     now.Add(time.Minute),
+			wantNextRun: now.Add(time.Minute),
+		},
+		{
+			name:        "due",
+			nextRun:     now.Add(-2 * time.Minute),
+			wantNextRun: spec.Next(now),
+			wantRuns:    []entity.TaskStatus{entity.TaskCreated},
+		},
+		{
+			name:             "claimed by another instance",
+			nextRun:          now.Add(-2 * time.Minute),
+			claimedElsewhere: true,
+			wantNextRun:      spec.Next(now),
+		},
+		{
+			name:           "previous run in progress",
+			nextRun:        now.Add(-2 * time.Minute),
+			history:        []entity.ScheduleRun{previous},
+			previousStatus: entity.TaskCreated,
+			wantNextRun:    spec.Next(now),
+			wantRuns:       []entity.TaskStatus{entity.TaskCreated, entity.TaskSkipped},
+		},
+		{
+			name:           "previous run finished",
+			nextRun:        now.Add(-2 * time.Minute),
+			history:        []entity.ScheduleRun{previous},
+			previousStatus: entity.TaskCompleted,
+			wantNextRun:    spec.Next(now),
+			wantRuns:       []entity.TaskStatus{entity.TaskCompleted, entity.TaskCreated},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedules := newMemSchedules()
+			tasks := newMemTasks()
+			s := newTestScheduler(t, schedules, tasks)
+
+			schedule := entity.Schedule{
+				ID:            "nightly",
+				TenantID:      "acme",
+				Cron:          "*/5 * * * *",
+				Mode:          entity.ScheduleSync,
+				SourceStorage: entity.Storage{ProviderID: "p1", Bucket: "source"},
+				TargetStorage: entity.Storage{ProviderID: "p1", Bucket: "target"},
+				NextRun:       tt.nextRun,
+				History:       tt.history,
+			}
+			schedules.Add(context.Background(), &schedule)
+			if tt.history != nil {
+				tasks.Add(context.Background(), &entity.Task{ID: previous.TaskID, TenantID: "acme", Status: tt.previousStatus})
+			}
+			if tt.claimedElsewhere {
+				schedules.listed = func() {
+					schedules.ClaimRun(context.Background(), schedule.ID, tt.nextRun, spec.Next(now))
+				}
+			}
+
+			s.runDue(context.Background(), now)
+
+			saved, _ := schedules.GetByID(context.Background(), schedule.ID)
+			if !saved.NextRun.Equal(tt.wantNextRun) {
+				t.Fatalf("next run = %v, want %v", saved.NextRun, tt.wantNextRun)
+			}
+			if len(saved.History) != len(tt.wantRuns) {
+				t.Fatalf("history = %+v, want runs %v", saved.History, tt.wantRuns)
+			}
+			for i, run := range saved.History {
+				if run.Status != tt.wantRuns[i] {
+					t.Fatalf("history = %+v, want runs %v", saved.History, tt.wantRuns)
+				}
+			}
+
+			if last := len(saved.History) - 1; last >= 0 && saved.History[last].TaskID != "" && saved.History[last].TaskID != previous.TaskID {
+				task := tasks.wait(t, saved.History[last].TaskID)
+				if task.TenantID != "acme" || task.Type != entity.Sync {
+					t.Fatalf("started task = %+v, want a sync of the tenant", task)
+				}
+			}
+		})
+	}
+}
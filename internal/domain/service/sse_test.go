@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestUploadServerSideEncryption(t *testing.T) {
+	storage := entity.Storage{ProviderID: "p1", Bucket: "b1"}
+	tenant := entity.WithTenant(context.Background(), "acme")
+	customerKey := &entity.ServerSideEncryption{Mode: entity.SSEC, CustomerKey: bytes.Repeat([]byte{1}, 32)}
+	otherKey := &entity.ServerSideEncryption{Mode: entity.SSEC, CustomerKey: bytes.Repeat([]byte{2}, 32)}
+	kms := &entity.ServerSideEncryption{Mode: entity.SSEKMS, KMSKeyID: "key-1"}
+
+	tests := []struct {
+		name       string
+		sse        *entity.ServerSideEncryption
+		writeSSE   *entity.ServerSideEncryption
+		wantCreate error
+		wantWrite  error
+		wantStored *entity.ServerSideEncryption
+	}{
+		{name: "bucket default"},
+		{name: "SSE-S3", sse: &entity.ServerSideEncryption{Mode: entity.SSES3}, wantStored: &entity.ServerSideEncryption{Mode: entity.SSES3}},
+		{name: "SSE-KMS", sse: kms, wantStored: kms},
+		{name: "SSE-C with the customer key", sse: customerKey, writeSSE: customerKey, wantStored: &entity.ServerSideEncryption{Mode: entity.SSEC}},
+		{name: "SSE-C without the customer key", sse: customerKey, wantWrite: ErrCustomerKeyNeeded, wantStored: &entity.ServerSideEncryption{Mode: entity.SSEC}},
+		{name: "SSE-C with another customer key", sse: customerKey, writeSSE: otherKey, wantWrite: errWrongCustomerKey, wantStored: &entity.ServerSideEncryption{Mode: entity.SSEC}},
+		{name: "SSE-S3 with a KMS key", sse: &entity.ServerSideEncryption{Mode: entity.SSES3, KMSKeyID: "key-1"}, wantCreate: ErrInvalidEncryption},
+		{name: "short customer key", sse: &entity.ServerSideEncryption{Mode: entity.SSEC, CustomerKey: []byte("short")}, wantCreate: ErrInvalidEncryption},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, storage)
+			uploads := newMemUploads()
+			s := newTestUploadService(t, storages, uploads, &memAudit{}, QuotasConfig{}, UploadConfig{})
+
+			objectID, err := s.CreateUpload(tenant, 10, nil, nil, tt.sse)
+			if !errors.Is(err, tt.wantCreate) {
+				t.Fatalf("CreateUpload() error = %v, want %v", err, tt.wantCreate)
+			}
+			if err != nil {
+				return
+			}
+
+			// The storage gets the key, the stored upload never does
+			upload, _ := uploads.get(objectID)
+			if !reflect.DeepEqual(upload.ServerSideEncryption, tt.wantStored) {
+				t.Fatalf("stored encryption = %+v, want %+v", upload.ServerSideEncryption, tt.wantStored)
+			}
+			if got := storages.encryption(storage, upload.MultipartID()); !reflect.DeepEqual(got, tt.sse) {
+				t.Fatalf("storage encryption = %+v, want %+v", got, tt.sse)
+			}
+
+			data := []byte("hello")
+			if _, err := s.WritePart(tenant, objectID, 0, &data, tt.writeSSE); !errors.Is(err, tt.wantWrite) {
+				t.Fatalf("WritePart() error = %v, want %v", err, tt.wantWrite)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"github.com/inview-team/gorynych/internal/infrastructure/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// newObjectRepository opens the storage of the provider with the account. Tests replace it.
+var newObjectRepository = func(ctx context.Context, provider *entity.Provider, account *entity.ServiceAccount) (entity.ObjectRepository, error) {
+	region := account.Region
+	if region == "" {
+		region = provider.Region
+	}
+
+	return s3.New(ctx, s3.Config{
+		Endpoint:         provider.Endpoint,
+		Region:           region,
+		AccessKey:        account.AccessKey,
+		Secret:           account.Secret,
+		PathStyle:        provider.PathStyle,
+		SkipTLSVerify:    provider.SkipTLSVerify,
+		SignatureVersion: provider.SignatureVersion,
+		Encryption:       provider.Encryption,
+	})
+}
+
+// findAccountByBucket returns the first account of the storage provider with access to the bucket.
+func findAccountByBucket(ctx context.Context, pRepo entity.ProviderRepository, aRepo entity.AccountRepository, st entity.Storage) (*entity.ServiceAccount, *entity.Provider, error) {
+	return findAccount(ctx, pRepo, aRepo, st, false)
+}
+
+// findWritableAccount returns the first account of the storage provider which may write
+// to the bucket. It fails with ErrStorageNotWritable when accounts only see the bucket.
+func findWritableAccount(ctx context.Context, pRepo entity.ProviderRepository, aRepo entity.AccountRepository, st entity.Storage) (*entity.ServiceAccount, *entity.Provider, error) {
+	return findAccount(ctx, pRepo, aRepo, st, true)
+}
+
+func findAccount(ctx context.Context, pRepo entity.ProviderRepository, aRepo entity.AccountRepository, st entity.Storage, writable bool) (*entity.ServiceAccount, *entity.Provider, error) {
+	log.Info("search bucket")
+	provider, err := pRepo.GetByID(ctx, st.ProviderID)
+	if err != nil {
+		log.Errorf("failed to choose account: failed to find provider: %v", err.Error())
+		return nil, nil, err
+	}
+
+	if provider == nil {
+		return nil, nil, ErrProviderNotFound
+	}
+	accounts, err := aRepo.ListByProvider(ctx, st.ProviderID)
+	if err != nil {
+		log.Errorf("failed to choose account: failed to list accounts: %v", err.Error())
+		return nil, nil, err
+	}
+
+	if len(accounts) == 0 {
+		return nil, nil, ErrNoAvailableAccounts
+	}
+
+	readOnly := false
+	for _, account := range accounts {
+		if account.Disabled {
+			continue
+		}
+		oRepo, err := newObjectRepository(ctx, provider, account)
+		if err != nil {
+			log.Errorf("failed to init storage by account with id: %s", account.ID)
+			continue
+		}
+
+		exists, err := oRepo.IsBucketExist(ctx, st.Bucket)
+		if err != nil {
+			continue
+		}
+
+		if !exists {
+			continue
+		}
+		if writable && !account.CanWrite(st.Bucket) {
+			readOnly = true
+			continue
+		}
+		return account, provider, nil
+	}
+
+	if readOnly {
+		return nil, nil, fmt.Errorf("%w: %s", ErrStorageNotWritable, st)
+	}
+	return nil, nil, ErrNoAvailableBuckets
+}
+
+// openStorage returns an object repository for the bucket.
+func openStorage(ctx context.Context, pRepo entity.ProviderRepository, aRepo entity.AccountRepository, st entity.Storage) (entity.ObjectRepository, error) {
+	account, provider, err := findAccountByBucket(ctx, pRepo, aRepo, st)
+	if err != nil {
+		return nil, err
+	}
+	return newObjectRepository(ctx, provider, account)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestCreateUploadTarget(t *testing.T) {
+	a := entity.Storage{ProviderID: "p1", Bucket: "a"}
+	readOnly := entity.Storage{ProviderID: "p1", Bucket: "ro"}
+	c := entity.Storage{ProviderID: "p2", Bucket: "c"}
+
+	tests := []struct {
+		name         string
+		target       *entity.Storage
+		metadata     map[string]string
+		want         error
+		wantStorage  entity.Storage
+		wantReplicas []entity.Storage
+	}{
+		{name: "target storage", target: &a, wantStorage: a},
+		{name: "storage chosen by placement", wantStorage: a},
+		{name: "unknown provider", target: &entity.Storage{ProviderID: "p9", Bucket: "a"}, want: ErrUnknownStorage},
+		{name: "unknown bucket", target: &entity.Storage{ProviderID: "p1", Bucket: "missing"}, want: ErrUnknownStorage},
+		{name: "read only target", target: &readOnly, want: ErrStorageNotWritable},
+		{
+			name:         "mirrored to writable storages",
+			target:       &c,
+			metadata:     map[string]string{mirrorCopiesKey: "2"},
+			wantStorage:  c,
+			wantReplicas: []entity.Storage{a},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, a, readOnly, c)
+			storages.setReadOnly(readOnly)
+			uploads := newMemUploads()
+			s := newTestUploadService(t, storages, uploads, &memAudit{}, QuotasConfig{}, UploadConfig{})
+
+			objectID, err := s.CreateUpload(context.Background(), 10, tt.metadata, tt.target, nil)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("CreateUpload() error = %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			upload, _ := uploads.get(objectID)
+			if upload.Storage != tt.wantStorage {
+				t.Fatalf("upload storage = %v, want %v", upload.Storage, tt.wantStorage)
+			}
+			if len(upload.Replicas) != len(tt.wantReplicas) {
+				t.Fatalf("upload replicas = %v, want %v", upload.Replicas, tt.wantReplicas)
+			}
+			for i, replica := range upload.Replicas {
+				if replica.Storage != tt.wantReplicas[i] {
+					t.Fatalf("upload replicas = %v, want %v", upload.Replicas, tt.wantReplicas)
+				}
+			}
+		})
+	}
+}
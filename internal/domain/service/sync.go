@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	log "github.com/sirupsen/logrus"
+)
+
+// bulkTask tracks a parent task while its child replication tasks are running.
+type bulkTask struct {
+	task *entity.Task
+	// listed is set once every child task has been enqueued.
+	listed bool
+	// move is set when the task is a move.
+	move *moveJob
+	// storages are the source and target of the task.
+	storages []entity.Storage
+	// snapshots counts the snapshots of the task taken for saving, the last at snapshotAt.
+	snapshots  int
+	snapshotAt time.Time
+
+	// saveMu orders the saves of the task; saved is the last snapshot saved, so a late
+	// older snapshot never overwrites a newer one.
+	saveMu sync.Mutex
+	saved  int
+}
+
+// bulkSnapshot is a copy of a parent task, saved outside of the lock of the service.
+type bulkSnapshot struct {
+	task entity.Task
+	seq  int
+}
+
+// Interval between saves of the progress of a running bulk task
+const bulkSaveInterval = time.Second
+
+// Sync replicates every object of the source bucket which is missing or differs on the target.
+func (s *TaskService) Sync(ctx context.Context, sourceStorage, targetStorage entity.Storage, opts entity.SyncOptions) (string, error) {
+	opts.Delete = false
+	return s.startBulk(ctx, entity.Sync, sourceStorage, targetStorage, opts)
+}
+
+// Mirror makes the target bucket follow the source: changed objects are replicated and,
+// if opts.Delete is set, copies of objects removed from the source are deleted.
+func (s *TaskService) Mirror(ctx context.Context, sourceStorage, targetStorage entity.Storage, opts entity.SyncOptions) (string, error) {
+	return s.startBulk(ctx, entity.Mirror, sourceStorage, targetStorage, opts)
+}
+
+func (s *TaskService) startBulk(ctx context.Context, taskType entity.TaskType, sourceStorage, targetStorage entity.Storage, opts entity.SyncOptions) (id string, err error) {
+	defer func() {
+		action := entity.AuditTaskSync
+		if taskType == entity.Mirror {
+			action = entity.AuditTaskMirror
+		}
+		details := taskDetails(sourceStorage, targetStorage)
+		details["delete"] = strconv.FormatBool(opts.Delete)
+		s.audit.Record(ctx, action, "task/"+id, err, details)
+	}()
+
+	task := &entity.Task{ID: entity.NewTaskID(), Start: time.Now(), Type: taskType, Status: entity.TaskCreated}
+	task.TenantID, _ = entity.TenantFromContext(ctx)
+	err = s.taskRepo.Add(ctx, task)
+	if err != nil {
+		log.Errorf("failed to create bulk task: %v", err)
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.bulks[task.ID] = &bulkTask{task: task, storages: []entity.Storage{sourceStorage, targetStorage}}
+	s.mu.Unlock()
+	s.limiter.SetTaskLimit(task.ID, task.TenantID, opts.Replication.MaxBandwidth)
+
+	go s.runSync(context.WithoutCancel(ctx), task.ID, sourceStorage, targetStorage, opts)
+	return task.ID, nil
+}
+
+func (s *TaskService) runSync(ctx context.Context, taskID string, sourceStorage, targetStorage entity.Storage, opts entity.SyncOptions) {
+	log.Infof("task %s: sync bucket %s to bucket %s", taskID, sourceStorage.Bucket, targetStorage.Bucket)
+	sourceRepo, err := openStorage(ctx, s.providerRepo, s.accountRepo, sourceStorage)
+	if err != nil {
+		s.abortBulk(ctx, taskID, err)
+		return
+	}
+	targetRepo, err := openStorage(ctx, s.providerRepo, s.accountRepo, targetStorage)
+	if err != nil {
+		s.abortBulk(ctx, taskID, err)
+		return
+	}
+
+	sourceObjects, err := sourceRepo.ListObjects(ctx, sourceStorage.Bucket, opts.Prefix)
+	if err != nil {
+		s.abortBulk(ctx, taskID, err)
+		return
+	}
+	targetObjects, err := targetRepo.ListObjects(ctx, targetStorage.Bucket, opts.TargetPrefix())
+	if err != nil {
+		s.abortBulk(ctx, taskID, err)
+		return
+	}
+
+	existing := make(map[string]*entity.Object, len(targetObjects))
+	for _, object := range targetObjects {
+		existing[object.Name] = object
+	}
+
+	sourceKeys := make(map[string]struct{}, len(sourceObjects))
+	for _, object := range sourceObjects {
+		sourceKeys[object.Name] = struct{}{}
+		if !opts.Match(object.Name) {
+			continue
+		}
+
+		if !entity.ObjectChanged(object, existing[opts.Replication.TargetObjectID(object.Name)]) {
+			s.updateBulk(ctx, taskID, func(p *entity.TaskProgress) {
+				p.Total++
+				p.Skipped++
+			})
+			continue
+		}
+
+		s.updateBulk(ctx, taskID, func(p *entity.TaskProgress) { p.Total++ })
+		err := s.enqueue(ctx, entity.ReplicationTask{
+			ID:             entity.NewTaskID(),
+			ParentID:       taskID,
+			ObjectID:       object.Name,
+			SourceStorage:  sourceStorage,
+			TargetStorages: []entity.Storage{targetStorage},
+			Options:        opts.Replication,
+		})
+		if err != nil {
+			s.updateBulk(ctx, taskID, func(p *entity.TaskProgress) { p.Failed++ })
+		}
+	}
+
+	if opts.Delete {
+		s.deleteRemoved(ctx, taskID, targetRepo, targetStorage.Bucket, targetObjects, sourceKeys, opts)
+	}
+
+	s.mu.Lock()
+	var snapshot *bulkSnapshot
+	bulk, exists := s.bulks[taskID]
+	if exists {
+		bulk.listed = true
+		snapshot = s.snapshotBulk(bulk, true)
+	}
+	s.mu.Unlock()
+	s.saveBulk(ctx, bulk, snapshot)
+}
+
+// deleteRemoved deletes target copies of source objects which no longer exist.
+func (s *TaskService) deleteRemoved(ctx context.Context, taskID string, targetRepo entity.ObjectRepository, bucket string, targetObjects []*entity.Object, sourceKeys map[string]struct{}, opts entity.SyncOptions) {
+	for _, object := range targetObjects {
+		sourceKey, ok := opts.SourceKey(object.Name)
+		if !ok {
+			continue
+		}
+		if _, exists := sourceKeys[sourceKey]; exists {
+			continue
+		}
+
+		log.Infof("task %s: delete %s removed from source", taskID, object.Name)
+		err := targetRepo.DeleteObject(ctx, bucket, object.Name)
+		s.updateBulk(ctx, taskID, func(p *entity.TaskProgress) {
+			p.Total++
+			if err != nil {
+				log.Errorf("task %s: failed to delete %s: %v", taskID, object.Name, err)
+				p.Failed++
+				return
+			}
+			p.Deleted++
+		})
+	}
+}
+
+// childFinished accounts the outcome of a child task in its parent.
+func (s *TaskService) childFinished(ctx context.Context, child *entity.Task) {
+	s.mu.Lock()
+	var job *moveJob
+	if bulk, exists := s.bulks[child.ParentID]; exists {
+		job = bulk.move
+	}
+	s.mu.Unlock()
+	if job != nil {
+		s.moveChildFinished(ctx, job, child)
+		return
+	}
+
+	s.updateBulk(ctx, child.ParentID, func(p *entity.TaskProgress) {
+		switch child.Status {
+		case entity.TaskCompleted:
+			p.Copied++
+		case entity.TaskSkipped:
+			p.Skipped++
+		default:
+			p.Failed++
+		}
+	})
+}
+
+func (s *TaskService) updateBulk(ctx context.Context, taskID string, update func(p *entity.TaskProgress)) {
+	s.mu.Lock()
+	bulk, exists := s.bulks[taskID]
+	if !exists {
+		s.mu.Unlock()
+		log.Errorf("failed to update progress of task %s: task is not running", taskID)
+		return
+	}
+	update(&bulk.task.Progress)
+	snapshot := s.snapshotBulk(bulk, false)
+	s.mu.Unlock()
+	s.saveBulk(ctx, bulk, snapshot)
+}
+
+// snapshotBulk completes the parent task once all children are processed, and returns a
+// copy of it to save. Progress is saved at most every bulkSaveInterval unless force is
+// set, nil is returned in between; the end of the task is always saved.
+// Must be called with s.mu held.
+func (s *TaskService) snapshotBulk(bulk *bulkTask, force bool) *bulkSnapshot {
+	task := bulk.task
+	if bulk.listed && task.Progress.Processed() >= task.Progress.Total {
+		task.End = time.Now()
+		if task.Progress.Failed == 0 {
+			task.Status = entity.TaskCompleted
+		} else {
+			task.Status = entity.TaskFailed
+		}
+		delete(s.bulks, task.ID)
+		s.limiter.RemoveTask(task.ID)
+		log.Infof("task %s finished: %d copied, %d skipped, %d deleted, %d failed", task.ID, task.Progress.Copied, task.Progress.Skipped, task.Progress.Deleted, task.Progress.Failed)
+		force = true
+	}
+
+	if !force && time.Since(bulk.snapshotAt) < bulkSaveInterval {
+		return nil
+	}
+	return bulk.snapshot()
+}
+
+// snapshot returns a copy of the task to save. Must be called with s.mu held.
+func (b *bulkTask) snapshot() *bulkSnapshot {
+	b.snapshots++
+	b.snapshotAt = time.Now()
+	return &bulkSnapshot{task: *b.task, seq: b.snapshots}
+}
+
+// saveBulk persists the snapshot of the parent task, unless a newer one was saved already.
+func (s *TaskService) saveBulk(ctx context.Context, bulk *bulkTask, snapshot *bulkSnapshot) {
+	if snapshot == nil {
+		return
+	}
+	bulk.saveMu.Lock()
+	defer bulk.saveMu.Unlock()
+	if snapshot.seq <= bulk.saved {
+		return
+	}
+	bulk.saved = snapshot.seq
+
+	err := s.taskRepo.Update(ctx, &snapshot.task)
+	if err != nil {
+		log.Errorf("failed to save progress of task %s. Reason: %v", snapshot.task.ID, err)
+	}
+}
+
+func (s *TaskService) abortBulk(ctx context.Context, taskID string, reason error) {
+	log.Errorf("task %s failed. Reason: %v", taskID, reason)
+	s.mu.Lock()
+	bulk, exists := s.bulks[taskID]
+	if !exists {
+		s.mu.Unlock()
+		return
+	}
+	bulk.task.Status = entity.TaskFailed
+	bulk.task.End = time.Now()
+	delete(s.bulks, taskID)
+	s.limiter.RemoveTask(taskID)
+	snapshot := bulk.snapshot()
+	s.mu.Unlock()
+	s.saveBulk(ctx, bulk, snapshot)
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// savedTasks records the saves of tasks, and whether one was made with the lock of the
+// task service held.
+type savedTasks struct {
+	entity.TaskRepository
+	service *TaskService
+
+	mu     sync.Mutex
+	saves  []entity.Task
+	locked bool
+}
+
+func (r *savedTasks) Update(_ context.Context, task *entity.Task) error {
+	if r.service.mu.TryLock() {
+		r.service.mu.Unlock()
+	} else {
+		r.locked = true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saves = append(r.saves, *task)
+	return nil
+}
+
+func TestUpdateBulk(t *testing.T) {
+	copied := func(p *entity.TaskProgress) { p.Copied++ }
+	failed := func(p *entity.TaskProgress) { p.Failed++ }
+
+	tests := []struct {
+		name       string
+		total      int
+		listed     bool
+		updates    []func(p *entity.TaskProgress)
+		wantSaves  int
+		wantStatus entity.TaskStatus
+		wantCopied int
+	}{
+		{
+			name:       "progress saved once per interval",
+			total:      5,
+			updates:    []func(p *entity.TaskProgress){copied, copied, copied},
+			wantSaves:  1,
+			wantStatus: entity.TaskCreated,
+			wantCopied: 1,
+		},
+		{
+			name:       "end of the task always saved",
+			total:      3,
+			listed:     true,
+			updates:    []func(p *entity.TaskProgress){copied, copied, copied},
+			wantSaves:  2,
+			wantStatus: entity.TaskCompleted,
+			wantCopied: 3,
+		},
+		{
+			name:       "failed child fails the task",
+			total:      2,
+			listed:     true,
+			updates:    []func(p *entity.TaskProgress){copied, failed},
+			wantSaves:  2,
+			wantStatus: entity.TaskFailed,
+			wantCopied: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &savedTasks{}
+			s := NewTaskService(nil, nil, repo, nil, NewBandwidthLimiter(BandwidthConfig{}), NewAuditService(&memAudit{}), 0)
+			repo.service = s
+			task := &entity.Task{ID: "bulk", Type: entity.Sync, Status: entity.TaskCreated, Progress: entity.TaskProgress{Total: tt.total}}
+			s.bulks[task.ID] = &bulkTask{task: task, listed: tt.listed}
+
+			for _, update := range tt.updates {
+				s.updateBulk(context.Background(), task.ID, update)
+			}
+
+			if repo.locked {
+				t.Fatal("task saved with the lock of the service held")
+			}
+			if len(repo.saves) != tt.wantSaves {
+				t.Fatalf("saves = %d, want %d", len(repo.saves), tt.wantSaves)
+			}
+			last := repo.saves[len(repo.saves)-1]
+			if last.Status != tt.wantStatus || last.Progress.Copied != tt.wantCopied {
+				t.Fatalf("last save = %+v, want status %v and %d copied", last, tt.wantStatus, tt.wantCopied)
+			}
+			_, running := s.bulks[task.ID]
+			if finished := tt.wantStatus != entity.TaskCreated; running == finished {
+				t.Fatalf("task running = %v after status %v", running, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSaveBulkKeepsNewerSnapshot(t *testing.T) {
+	repo := &savedTasks{}
+	s := NewTaskService(nil, nil, repo, nil, NewBandwidthLimiter(BandwidthConfig{}), NewAuditService(&memAudit{}), 0)
+	repo.service = s
+	bulk := &bulkTask{task: &entity.Task{ID: "bulk", Status: entity.TaskCreated}}
+
+	older := bulk.snapshot()
+	bulk.task.Status = entity.TaskCompleted
+	newer := bulk.snapshot()
+
+	s.saveBulk(context.Background(), bulk, newer)
+	s.saveBulk(context.Background(), bulk, older)
+	if len(repo.saves) != 1 || repo.saves[0].Status != entity.TaskCompleted {
+		t.Fatalf("saves = %+v, want only the completed task", repo.saves)
+	}
+}
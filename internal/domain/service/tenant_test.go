@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// newTenantUploadService wires an upload service to the fake storages, with the account
+// of p1 owned by acme and the account of p2 owned by globex.
+func newTenantUploadService(t *testing.T, storages *fakeStorages, uploads *memUploads) *UploadService {
+	t.Helper()
+	placement, err := NewPlacement(PlacementConfig{}, uploads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewUploadService(
+		uploads,
+		newMemAccounts(
+			&entity.ServiceAccount{ID: "acme-account", TenantID: "acme", ProviderID: "p1"},
+			&entity.ServiceAccount{ID: "globex-account", TenantID: "globex", ProviderID: "p2"},
+		),
+		newMemProviders(&entity.Provider{ID: "p1"}, &entity.Provider{ID: "p2"}),
+		NewBandwidthLimiter(BandwidthConfig{}),
+		NewPolicyService(noPolicies{}, nil),
+		placement,
+		NewQuotas(QuotasConfig{}, uploads),
+		NewAuditService(&memAudit{}),
+		nil,
+		UploadConfig{},
+	)
+}
+
+func TestCreateUploadTenant(t *testing.T) {
+	acme := entity.Storage{ProviderID: "p1", Bucket: "a"}
+	globex := entity.Storage{ProviderID: "p2", Bucket: "g"}
+
+	tests := []struct {
+		name        string
+		tenant      string
+		target      *entity.Storage
+		want        error
+		wantStorage entity.Storage
+	}{
+		{name: "placed on the tenant's account", tenant: "acme", wantStorage: acme},
+		{name: "placed on the other tenant's account", tenant: "globex", wantStorage: globex},
+		{name: "target of the tenant", tenant: "acme", target: &acme, wantStorage: acme},
+		{name: "target of another tenant", tenant: "acme", target: &globex, want: ErrUnknownStorage},
+		{name: "tenant without accounts", tenant: "initech", want: ErrNoAvailableAccounts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, acme, globex)
+			uploads := newMemUploads()
+			s := newTenantUploadService(t, storages, uploads)
+
+			objectID, err := s.CreateUpload(entity.WithTenant(context.Background(), tt.tenant), 10, nil, tt.target, nil)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("CreateUpload() error = %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			upload, _ := uploads.get(objectID)
+			if upload.TenantID != tt.tenant || upload.Storage != tt.wantStorage {
+				t.Fatalf("upload of %q on %v, want of %q on %v", upload.TenantID, upload.Storage, tt.tenant, tt.wantStorage)
+			}
+		})
+	}
+}
+
+func TestUploadTenantVisibility(t *testing.T) {
+	storage := entity.Storage{ProviderID: "p1", Bucket: "a"}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want error
+	}{
+		{name: "own tenant", ctx: entity.WithTenant(context.Background(), "acme")},
+		{name: "unscoped", ctx: entity.WithTenant(context.Background(), "")},
+		{name: "other tenant", ctx: entity.WithTenant(context.Background(), "globex"), want: ErrUploadNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, storage)
+			s := newTenantUploadService(t, storages, newMemUploads())
+			objectID, err := s.CreateUpload(entity.WithTenant(context.Background(), "acme"), 10, nil, nil, nil)
+			if err != nil {
+				t.Fatalf("CreateUpload() error = %v", err)
+			}
+
+			_, err = s.GetUpload(tt.ctx, objectID)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("GetUpload() error = %v, want %v", err, tt.want)
+			}
+			listed := len(s.GetUploads(tt.ctx)) == 1
+			if listed != (tt.want == nil) {
+				t.Fatalf("GetUploads() lists the upload: %v, want %v", listed, tt.want == nil)
+			}
+		})
+	}
+}
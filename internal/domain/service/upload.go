@@ -2,81 +2,286 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/inview-team/gorynych/internal/domain/entity"
-	"github.com/inview-team/gorynych/internal/infrastructure/s3"
+	"github.com/inview-team/gorynych/internal/infrastructure/encryption"
+	"github.com/inview-team/gorynych/internal/infrastructure/secrets"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// Upload-Metadata keys selecting a mirrored upload
+const (
+	mirrorCopiesKey = "mirror"
+	mirrorQuorumKey = "mirror-quorum"
+)
+
+type UploadConfig struct {
+	// Copies is the number of storages every upload is written to during the upload.
+	// Uploads may ask for another number with the mirror metadata key.
+	Copies int `yaml:"copies,omitempty"`
+	// Quorum is the number of copies which must acknowledge every chunk. Zero means all.
+	Quorum int `yaml:"quorum,omitempty"`
+	// Encrypt encrypts uploads in the gateway with the master key of the secrets, so
+	// storages only ever see ciphertext. Uploads may ask otherwise with the encrypt
+	// metadata key.
+	Encrypt bool `yaml:"encrypt,omitempty"`
+	// ExpireAfter is how long an upload may go without a chunk before it expires and
+	// stops counting towards quotas. Zero means uploads never expire.
+	ExpireAfter time.Duration `yaml:"expire_after,omitempty"`
+}
+
+var (
+	DefaultUploadConfig = UploadConfig{
+		Copies:      1,
+		ExpireAfter: 24 * time.Hour,
+	}
+)
+
 type UploadService struct {
 	mu           sync.Mutex
 	uploads      map[string]*entity.Upload
 	uploadRepo   entity.UploadRepository
 	providerRepo entity.ProviderRepository
 	accountRepo  entity.AccountRepository
+	limiter      *BandwidthLimiter
+	policies     *PolicyService
+	placement    *Placement
+	quotas       *Quotas
+	audit        *AuditService
+	keyring      *secrets.Keyring
+	cfg          UploadConfig
 }
 
-func NewUploadService(uRepo entity.UploadRepository, aRepo entity.AccountRepository, pRepo entity.ProviderRepository) *UploadService {
+func NewUploadService(uRepo entity.UploadRepository, aRepo entity.AccountRepository, pRepo entity.ProviderRepository, limiter *BandwidthLimiter, policies *PolicyService, placement *Placement, quotas *Quotas, audit *AuditService, keyring *secrets.Keyring, cfg UploadConfig) *UploadService {
 	return &UploadService{
 		uploads:      make(map[string]*entity.Upload),
 		uploadRepo:   uRepo,
 		accountRepo:  aRepo,
 		providerRepo: pRepo,
+		limiter:      limiter,
+		policies:     policies,
+		placement:    placement,
+		quotas:       quotas,
+		audit:        audit,
+		keyring:      keyring,
+		cfg:          cfg,
 	}
 }
 
-func (s *UploadService) CreateUpload(ctx context.Context, size int64, metadata map[string]string) (string, error) {
+// CreateUpload starts an upload. The target storage, if given, receives the upload;
+// otherwise, and for the other copies of a mirrored upload, the placement strategy decides.
+// The server side encryption, if given, replaces the default one of the providers.
+func (s *UploadService) CreateUpload(ctx context.Context, size int64, metadata map[string]string, target *entity.Storage, sse *entity.ServerSideEncryption) (objectID string, err error) {
+	var storages []string
+	defer func() {
+		details := map[string]string{
+			"size":     strconv.FormatInt(size, 10),
+			"storages": strings.Join(storages, ","),
+		}
+		if grant := entity.UploadGrantFromContext(ctx); grant != nil {
+			details["upload_token"] = grant.ID
+		}
+		s.audit.Record(ctx, entity.AuditUploadCreate, "upload/"+objectID, err, details)
+	}()
+
 	log.Infof("create new upload")
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	oRepo, storage, err := s.chooseAccount(ctx)
+	grant := entity.UploadGrantFromContext(ctx)
+	if grant != nil {
+		metadata, target, err = s.applyGrant(ctx, grant, size, metadata, target)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	copies, quorum, err := s.mirrorSettings(metadata)
 	if err != nil {
-		return "", fmt.Errorf("failed to create upload: %w", err)
+		return "", err
 	}
 
-	log.Infof("Choose provider: %s and bucket %s", storage.ProviderID, storage.Bucket)
+	encrypt, err := s.encryptSettings(metadata)
+	if err != nil {
+		return "", err
+	}
+	if sse != nil {
+		if err := sse.Validate(); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidEncryption, err)
+		}
+	}
 
-	objectID := entity.NewObjectID()
-	uploadID, err := oRepo.Create(ctx, storage.Bucket, objectID, metadata)
+	// Uploads are created one at a time per instance, so concurrent ones can't exceed the
+	// quota together here. Uploads created meanwhile by other instances may still overshoot it.
+	err = s.quotas.Check(ctx, size)
 	if err != nil {
-		return "", fmt.Errorf("failed to create upload: %v", err)
+		return "", err
+	}
+
+	var placements []placement
+	if target != nil {
+		log.Infof("Search account from Provider %s with access to bucket %s", target.ProviderID, target.Bucket)
+		account, provider, err := findWritableAccount(ctx, s.providerRepo, s.accountRepo, *target)
+		if errors.Is(err, ErrProviderNotFound) || errors.Is(err, ErrNoAvailableAccounts) || errors.Is(err, ErrNoAvailableBuckets) {
+			return "", fmt.Errorf("%w: %w", ErrUnknownStorage, err)
+		}
+		if err != nil {
+			return "", err
+		}
+		oRepo, err := newObjectRepository(ctx, provider, account)
+		if err != nil {
+			return "", fmt.Errorf("failed to create upload: %w", err)
+		}
+		placements = append(placements, placement{repo: oRepo, storage: *target})
+		copies--
+	}
+
+	if copies > 0 {
+		chosen, err := s.chooseStorages(ctx, copies, PlacementRequest{Size: size, Metadata: metadata}, target)
+		if err != nil {
+			return "", fmt.Errorf("failed to create upload: %w", err)
+		}
+		placements = append(placements, chosen...)
+	}
+
+	objectID = entity.NewObjectID()
+	attrs := entity.ObjectAttributes{Metadata: metadata}
+	var objectKey *encryption.ObjectKey
+	if encrypt {
+		objectKey, err = encryption.NewObjectKey(s.keyring)
+		if err != nil {
+			return "", fmt.Errorf("failed to create upload: %w", err)
+		}
+		attrs.Metadata = encryption.KeepMetadata(objectKey.Metadata(), metadata)
+	}
+	uploadIDs := make([]string, 0, len(placements))
+	for _, placement := range placements {
+		log.Infof("Choose provider: %s and bucket %s", placement.storage.ProviderID, placement.storage.Bucket)
+		uploadID, err := placement.repo.Create(ctx, placement.storage.Bucket, objectID, attrs, sse)
+		if err != nil {
+			for i, id := range uploadIDs {
+				placements[i].repo.AbortUpload(ctx, placements[i].storage.Bucket, id, objectID)
+			}
+			if errors.Is(err, entity.ErrAccessDenied) {
+				return "", fmt.Errorf("%w: %s/%s", ErrStorageNotWritable, placement.storage.ProviderID, placement.storage.Bucket)
+			}
+			return "", fmt.Errorf("failed to create upload: %v", err)
+		}
+		uploadIDs = append(uploadIDs, uploadID)
+		storages = append(storages, placement.storage.String())
 	}
 
 	log.Infof("Create upload for object with ID %s", objectID)
-	upload := entity.NewUpload(uploadID, objectID, size, 0, entity.Active, nil, *storage)
+	upload := entity.NewUpload(uploadIDs[0], objectID, size, 0, entity.Active, nil, placements[0].storage, metadata)
+	upload.TenantID, _ = entity.TenantFromContext(ctx)
+	upload.Owner, _ = entity.SubjectFromContext(ctx)
+	for i, placement := range placements[1:] {
+		upload.Replicas = append(upload.Replicas, entity.UploadReplica{Storage: placement.storage, UploadID: uploadIDs[i+1]})
+	}
+	if len(upload.Replicas) > 0 {
+		upload.Quorum = quorum
+	}
+	if objectKey != nil {
+		upload.Encryption = &entity.UploadEncryption{KeyID: objectKey.KeyID, DataKey: objectKey.Wrapped}
+	}
+	upload.ServerSideEncryption = sse.WithoutKey()
+	if grant != nil {
+		upload.GrantID = grant.ID
+	}
 
-	s.uploads[objectID] = upload
 	err = s.uploadRepo.Add(ctx, upload)
+	if errors.Is(err, entity.ErrGrantUsed) {
+		// Another instance created the upload of the token meanwhile
+		for i, id := range uploadIDs {
+			placements[i].repo.AbortUpload(ctx, placements[i].storage.Bucket, id, objectID)
+		}
+		return "", ErrUploadTokenUsed
+	}
 	if err != nil {
 		log.Errorf("failed to save upload: %v", err.Error())
 	}
+	s.uploads[objectID] = upload
 
 	return objectID, nil
 }
 
-func (s *UploadService) chooseAccount(ctx context.Context) (entity.ObjectRepository, *entity.Storage, error) {
+// mirrorSettings returns the number of copies and the quorum of a new upload from its
+// metadata, falling back to the configured defaults.
+func (s *UploadService) mirrorSettings(metadata map[string]string) (int, int, error) {
+	copies, quorum := s.cfg.Copies, s.cfg.Quorum
+	if copies == 0 {
+		copies = 1
+	}
+
+	var err error
+	if value, exists := metadata[mirrorCopiesKey]; exists {
+		if copies, err = strconv.Atoi(value); err != nil {
+			return 0, 0, ErrInvalidMirror
+		}
+		quorum = 0
+	}
+	if value, exists := metadata[mirrorQuorumKey]; exists {
+		if quorum, err = strconv.Atoi(value); err != nil {
+			return 0, 0, ErrInvalidMirror
+		}
+	}
+
+	if quorum == 0 {
+		quorum = copies
+	}
+	if copies < 1 || quorum < 1 || quorum > copies {
+		return 0, 0, ErrInvalidMirror
+	}
+	return copies, quorum, nil
+}
+
+type placement struct {
+	repo    entity.ObjectRepository
+	storage entity.Storage
+}
+
+// chooseStorages picks n distinct buckets for an upload with the placement strategy
+// selected by its metadata. The excluded storage, if any, is never picked.
+func (s *UploadService) chooseStorages(ctx context.Context, n int, req PlacementRequest, exclude *entity.Storage) ([]placement, error) {
 	log.Info("choose account for upload")
+	strategy, err := s.placement.Strategy(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
 	accounts, err := s.accountRepo.List(ctx)
 	if err != nil {
 		log.Errorf("failed to choose account: failed to list accounts: %v", err.Error())
-		return nil, nil, err
+		return nil, err
 	}
 
 	if len(accounts) == 0 {
-		return nil, nil, ErrNoAvailableAccounts
+		return nil, ErrNoAvailableAccounts
 	}
 
+	repos := make(map[entity.Storage]entity.ObjectRepository)
+	var candidates []entity.Storage
 	for _, account := range accounts {
+		if account.Disabled {
+			continue
+		}
 		provider, err := s.providerRepo.GetByID(ctx, account.ProviderID)
 		if err != nil {
 			log.Errorf("failed to choose account: failed to find provider: %v", err.Error())
-			return nil, nil, err
+			return nil, err
+		}
+		if provider == nil {
+			log.Errorf("failed to choose account: provider %s of account %s not found", account.ProviderID, account.ID)
+			continue
 		}
-		oRepo, err := s3.New(ctx, provider.Endpoint, account.Region, account.AccessKey, account.Secret)
+		oRepo, err := newObjectRepository(ctx, provider, account)
 		if err != nil {
 			log.Errorf("failed to init storage by account with id: %s", account.ID)
 			continue
@@ -89,32 +294,72 @@ func (s *UploadService) chooseAccount(ctx context.Context) (entity.ObjectReposit
 		}
 		log.Infof("found %d buckets: %v", len(buckets), buckets)
 
-		return oRepo, &entity.Storage{ProviderID: account.ProviderID, Bucket: buckets[0]}, nil
+		for _, bucket := range buckets {
+			storage := entity.Storage{ProviderID: account.ProviderID, Bucket: bucket}
+			// Accounts of the same provider may see the same buckets
+			if _, exists := repos[storage]; exists || (exclude != nil && storage == *exclude) || !account.CanWrite(bucket) {
+				continue
+			}
+			repos[storage] = oRepo
+			candidates = append(candidates, storage)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrNoAvailableBuckets
+	}
+
+	req.Copies = n
+	ranked, err := strategy.Rank(ctx, req, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(ranked) == 0 {
+		return nil, ErrNoAvailableBuckets
+	}
+	if len(ranked) < n {
+		return nil, ErrNotEnoughStorages
 	}
-	return nil, nil, ErrNoAvailableBuckets
+
+	placements := make([]placement, 0, n)
+	for _, storage := range ranked[:n] {
+		placements = append(placements, placement{repo: repos[storage], storage: storage})
+	}
+	return placements, nil
 }
 
-func (s *UploadService) WritePart(ctx context.Context, objectID string, offset int64, data *[]byte) (int64, error) {
+// WritePart writes the chunk at the offset of the upload. Uploads created with SSE-C need
+// the same customer key for every chunk.
+func (s *UploadService) WritePart(ctx context.Context, objectID string, offset int64, data *[]byte, sse *entity.ServerSideEncryption) (int64, error) {
 	log.Infof("write part to object with id %s", objectID)
+	// The chunk waits for bandwidth before taking the lock, which every upload shares
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	upload, err := s.lookupUpload(ctx, objectID)
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	var providerIDs []string
+	for _, storage := range upload.Storages() {
+		providerIDs = append(providerIDs, storage.ProviderID)
+	}
+	if err := s.limiter.Wait(ctx, len(*data), "", providerIDs...); err != nil {
+		return 0, fmt.Errorf("failed to upload chunk: %w", err)
+	}
 
-	log.Infof("Search for upload with Object ID %s", objectID)
-	var upload *entity.Upload
-	upload, exists := s.uploads[objectID]
-	if !exists {
-		upload, err := s.uploadRepo.GetByID(ctx, objectID)
-		if err != nil {
-			log.Errorf("failed to write part: failed to find upload: %v", err.Error())
-			return 0, err
-		}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		if upload == nil {
-			return 0, ErrUploadNotFound
-		}
+	upload, err = s.lookupUpload(ctx, objectID)
+	if err != nil {
+		return 0, err
 	}
 
 	log.Infof("Update upload: %v\n", *upload)
+	if err := checkCustomerKey(upload, sse); err != nil {
+		return 0, err
+	}
+
 	if offset != upload.Offset {
 		return 0, ErrWrongOffset
 	}
@@ -123,10 +368,14 @@ func (s *UploadService) WritePart(ctx context.Context, objectID string, offset i
 		return 0, ErrUploadBig
 	}
 
-	log.Infof("Search account from Provider %s with access to bucket %s", upload.Storage.ProviderID, upload.Storage.Bucket)
-	oRepo, err := s.getAccountByBucket(ctx, upload.Storage)
-	if err != nil {
-		return 0, ErrBucketNotFound
+	// Mirrored uploads open the storage of every copy, which counts as any other copy
+	var oRepo entity.ObjectRepository
+	if len(upload.Replicas) == 0 {
+		log.Infof("Search account from Provider %s with access to bucket %s", upload.Storage.ProviderID, upload.Storage.Bucket)
+		oRepo, err = s.getAccountByBucket(ctx, upload.Storage)
+		if err != nil {
+			return 0, ErrBucketNotFound
+		}
 	}
 
 	var position int
@@ -136,22 +385,62 @@ func (s *UploadService) WritePart(ctx context.Context, objectID string, offset i
 		position = 1
 	}
 
-	partID, err := oRepo.WritePart(ctx, upload.Storage.Bucket, upload.ID, upload.ObjectID, position, data)
-	if err != nil {
-		log.Errorf("failed to write part. Reason: %v", err)
-		return 0, fmt.Errorf("failed to upload chunk: %w", err)
+	// Encrypted uploads write whole segments, the rest waits for the next chunk
+	written := data
+	var pending []byte
+	if upload.Encryption != nil {
+		sealed, rest, err := s.sealChunk(upload, *data)
+		if err != nil {
+			log.Errorf("failed to encrypt chunk of %s: %v", objectID, err)
+			return 0, fmt.Errorf("failed to upload chunk: %w", err)
+		}
+		written, pending = &sealed, rest
+	}
+
+	if len(*written) > 0 {
+		if len(upload.Replicas) == 0 {
+			var partID string
+			partID, err = oRepo.WritePart(ctx, upload.Storage.Bucket, upload.MultipartID(), upload.ObjectID, position, written, sse)
+			if err == nil {
+				upload.AddPartial(partID, position)
+			}
+		} else {
+			err = s.writeMirrored(ctx, upload, position, written, sse)
+		}
+		if err != nil {
+			log.Errorf("failed to write part. Reason: %v", err)
+			return 0, fmt.Errorf("failed to upload chunk: %w", err)
+		}
 	}
 
 	upload.SetOffset(offset + int64(len(*data)))
-	upload.AddPartial(partID, position)
+	if upload.Encryption != nil {
+		upload.Encryption.Pending = pending
+	}
 
 	if upload.Offset == upload.Size {
-		err := oRepo.FinishUpload(ctx, upload.Storage.Bucket, upload.ID, upload.ObjectID, upload.Parts)
-		if err != nil {
-			return 0, fmt.Errorf("failed to finish upload: %v", err)
+		if len(upload.Replicas) == 0 {
+			err := oRepo.FinishUpload(ctx, upload.Storage.Bucket, upload.MultipartID(), upload.ObjectID, upload.Parts)
+			if err != nil {
+				err = fmt.Errorf("failed to finish upload: %v", err)
+				s.audit.Record(ctx, entity.AuditUploadFail, "upload/"+objectID, err, nil)
+				return 0, err
+			}
+		} else {
+			err = s.finishMirrored(ctx, upload)
+			if err != nil {
+				upload.Status = entity.Failed
+				delete(s.uploads, objectID)
+				s.uploadRepo.Update(ctx, upload)
+				err = fmt.Errorf("failed to finish upload: %w", err)
+				s.audit.Record(ctx, entity.AuditUploadFail, "upload/"+objectID, err, nil)
+				return 0, err
+			}
 		}
 		upload.Status = entity.Complete
 		delete(s.uploads, objectID)
+		s.audit.Record(ctx, entity.AuditUploadComplete, "upload/"+objectID, nil, map[string]string{"size": strconv.FormatInt(upload.Size, 10)})
+		go s.policies.Apply(context.WithoutCancel(ctx), upload)
 	}
 
 	err = s.uploadRepo.Update(ctx, upload)
@@ -162,58 +451,71 @@ func (s *UploadService) WritePart(ctx context.Context, objectID string, offset i
 	return upload.Offset, nil
 }
 
-func (s *UploadService) getAccountByBucket(ctx context.Context, st entity.Storage) (entity.ObjectRepository, error) {
-	log.Info("search bucket")
-	provider, err := s.providerRepo.GetByID(ctx, st.ProviderID)
-	if err != nil {
-		log.Errorf("failed to choose account: failed to find provider: %v", err.Error())
-		return nil, err
+// lookupUpload returns the active upload a chunk is written to, and caches it.
+// Must be called with s.mu held.
+func (s *UploadService) lookupUpload(ctx context.Context, objectID string) (*entity.Upload, error) {
+	log.Infof("Search for upload with Object ID %s", objectID)
+	upload, exists := s.uploads[objectID]
+	if exists {
+		if !entity.InTenant(ctx, upload.TenantID) || !granted(ctx, upload) {
+			return nil, ErrUploadNotFound
+		}
+		return upload, nil
 	}
-	accounts, err := s.accountRepo.ListByProvider(ctx, st.ProviderID)
+
+	upload, err := s.uploadRepo.GetByID(ctx, objectID)
 	if err != nil {
-		log.Errorf("failed to choose account: failed to list accounts: %v", err.Error())
+		log.Errorf("failed to write part: failed to find upload: %v", err.Error())
 		return nil, err
 	}
-
-	if len(accounts) == 0 {
-		return nil, ErrNoAvailableAccounts
+	if upload == nil || !granted(ctx, upload) || upload.Status == entity.Expired {
+		return nil, ErrUploadNotFound
 	}
+	// Finished uploads take no more chunks, a retried last one would finish them again
+	if upload.Status != entity.Active {
+		return nil, ErrUploadFinished
+	}
+	s.uploads[objectID] = upload
+	return upload, nil
+}
 
-	for _, account := range accounts {
-		oRepo, err := s3.New(ctx, provider.Endpoint, account.Region, account.AccessKey, account.Secret)
-		if err != nil {
-			log.Errorf("failed to init storage by account with id: %s", account.ID)
-			continue
-		}
-
-		exists, err := oRepo.IsBucketExist(ctx, st.Bucket)
-		if err != nil {
-			continue
-		}
-
-		if !exists {
-			continue
-		}
-		return oRepo, nil
+// checkCustomerKey makes sure the request gives a customer key when the upload was
+// created with SSE-C. Storages reject a wrong key by themselves.
+func checkCustomerKey(upload *entity.Upload, sse *entity.ServerSideEncryption) error {
+	if upload.ServerSideEncryption == nil || upload.ServerSideEncryption.Mode != entity.SSEC {
+		return nil
 	}
+	if sse == nil || sse.Mode != entity.SSEC {
+		return ErrCustomerKeyNeeded
+	}
+	return nil
+}
 
-	return nil, ErrNoAvailableBuckets
+func (s *UploadService) getAccountByBucket(ctx context.Context, st entity.Storage) (entity.ObjectRepository, error) {
+	return openStorage(ctx, s.providerRepo, s.accountRepo, st)
 }
 
-func (s *UploadService) GetUploads() []*entity.Upload {
+func (s *UploadService) GetUploads(ctx context.Context) []*entity.Upload {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	var uploads []*entity.Upload
 	for _, upload := range s.uploads {
-		uploads = append(uploads, upload)
+		if entity.InTenant(ctx, upload.TenantID) {
+			uploads = append(uploads, upload)
+		}
 	}
 	return uploads
 }
 
 func (s *UploadService) GetUpload(ctx context.Context, id string) (*entity.Upload, error) {
 	upload, exists := s.uploads[id]
-	if !exists {
+	if !exists || !entity.InTenant(ctx, upload.TenantID) || !granted(ctx, upload) {
 		return nil, ErrUploadNotFound
 	}
 	return upload, nil
 }
+
+// MaxUploadSize returns the largest upload the caller may start, and whether it is limited.
+func (s *UploadService) MaxUploadSize(ctx context.Context) (int64, bool, error) {
+	return s.quotas.MaxUploadSize(ctx)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestWritePart(t *testing.T) {
+	storage := entity.Storage{ProviderID: "p1", Bucket: "b1"}
+	tenant := entity.WithTenant(context.Background(), "acme")
+
+	// setStatus changes the status of the stored upload, as another instance would
+	setStatus := func(status entity.UploadStatus) func(*testing.T, *UploadService, *memUploads, string) {
+		return func(t *testing.T, s *UploadService, uploads *memUploads, objectID string) {
+			s.mu.Lock()
+			delete(s.uploads, objectID)
+			s.mu.Unlock()
+			upload, _ := uploads.get(objectID)
+			upload.Status = status
+			uploads.Update(context.Background(), &upload)
+		}
+	}
+	complete := func(t *testing.T, s *UploadService, _ *memUploads, objectID string) {
+		data := []byte("helloworld")
+		if _, err := s.WritePart(tenant, objectID, 0, &data, nil); err != nil {
+			t.Fatalf("WritePart() of the whole upload error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		setup      func(*testing.T, *UploadService, *memUploads, string)
+		ctx        context.Context
+		offset     int64
+		data       string
+		want       error
+		wantOffset int64
+		wantObject bool
+	}{
+		{name: "first chunk", ctx: tenant, data: "hello", wantOffset: 5},
+		{name: "last chunk", ctx: tenant, data: "helloworld", wantOffset: 10, wantObject: true},
+		{name: "wrong offset", ctx: tenant, offset: 3, data: "hello", want: ErrWrongOffset},
+		{name: "chunk over the size", ctx: tenant, data: "hello world", want: ErrUploadBig},
+		{name: "other tenant", ctx: entity.WithTenant(context.Background(), "globex"), data: "hello", want: ErrUploadNotFound},
+		{name: "empty chunk on a complete upload", setup: complete, ctx: tenant, offset: 10, want: ErrUploadFinished, wantObject: true},
+		{name: "chunk on a failed upload", setup: setStatus(entity.Failed), ctx: tenant, data: "hello", want: ErrUploadFinished},
+		{name: "chunk on an expired upload", setup: setStatus(entity.Expired), ctx: tenant, data: "hello", want: ErrUploadNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storages := newFakeStorages(t, storage)
+			uploads := newMemUploads()
+			audit := &memAudit{}
+			s := newTestUploadService(t, storages, uploads, audit, QuotasConfig{}, UploadConfig{})
+
+			objectID, err := s.CreateUpload(tenant, 10, nil, &storage, nil)
+			if err != nil {
+				t.Fatalf("CreateUpload() error = %v", err)
+			}
+			if tt.setup != nil {
+				tt.setup(t, s, uploads, objectID)
+			}
+
+			data := []byte(tt.data)
+			offset, err := s.WritePart(tt.ctx, objectID, tt.offset, &data, nil)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("WritePart() error = %v, want %v", err, tt.want)
+			}
+			if offset != tt.wantOffset {
+				t.Fatalf("WritePart() offset = %d, want %d", offset, tt.wantOffset)
+			}
+			object, exists := storages.object(storage, objectID)
+			if exists != tt.wantObject || exists && string(object) != "helloworld" {
+				t.Fatalf("object = %q, exists %v, want exists %v", object, exists, tt.wantObject)
+			}
+
+			var completions int
+			for _, action := range audit.actions() {
+				if action == entity.AuditUploadComplete {
+					completions++
+				}
+			}
+			if completions > 1 {
+				t.Fatalf("upload completed %d times", completions)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package service
 
 import (
 	"context"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -9,68 +12,198 @@ import (
 	"github.com/inview-team/gorynych/internal/domain/entity"
 )
 
+// Tasks in progress are touched every taskHeartbeat. Those not touched for
+// taskStaleAfter were left behind by a stopped instance and are failed.
+const (
+	taskHeartbeat  = time.Minute
+	taskStaleAfter = 5 * time.Minute
+)
+
 type TaskService struct {
 	accountRepo  entity.AccountRepository
 	providerRepo entity.ProviderRepository
 	taskRepo     entity.TaskRepository
+	pendingRepo  entity.PendingDeletionRepository
 	tasksChan    chan entity.ReplicationTask
 	resultChan   chan entity.ReplicationResult
 	workerCount  int
+	limiter      *BandwidthLimiter
+	audit        *AuditService
+
+	mu    sync.Mutex
+	bulks map[string]*bulkTask
+	// running holds the replication tasks in progress.
+	running map[string]entity.ReplicationTask
+	// queue holds the replication tasks waiting for a worker, so enqueueing never waits
+	// for the workers. queued is signalled when a task is added.
+	queue  []entity.ReplicationTask
+	queued chan struct{}
 }
 
-func NewTaskService(aRepo entity.AccountRepository, pRepo entity.ProviderRepository, tRepo entity.TaskRepository, workerCount int) *TaskService {
+func NewTaskService(aRepo entity.AccountRepository, pRepo entity.ProviderRepository, tRepo entity.TaskRepository, pdRepo entity.PendingDeletionRepository, limiter *BandwidthLimiter, audit *AuditService, workerCount int) *TaskService {
 	return &TaskService{
 		accountRepo:  aRepo,
 		providerRepo: pRepo,
 		taskRepo:     tRepo,
+		pendingRepo:  pdRepo,
 		tasksChan:    make(chan entity.ReplicationTask),
 		resultChan:   make(chan entity.ReplicationResult),
 		workerCount:  workerCount,
+		limiter:      limiter,
+		audit:        audit,
+		bulks:        make(map[string]*bulkTask),
+		running:      make(map[string]entity.ReplicationTask),
+		queued:       make(chan struct{}, 1),
 	}
 }
 
 func (s *TaskService) Start(ctx context.Context) {
 	for i := 0; i < s.workerCount; i++ {
-		worker := NewReplicationService(s.tasksChan, s.resultChan, s.accountRepo, s.providerRepo)
+		worker := NewReplicationService(s.tasksChan, s.resultChan, s.accountRepo, s.providerRepo, s.limiter)
 		worker.Start(ctx)
 	}
+	go s.dispatch(ctx)
+	go s.heartbeat(ctx)
 
 	go func() {
 		for result := range s.resultChan {
+			s.limiter.RemoveTask(result.ID)
+			s.mu.Lock()
+			delete(s.running, result.ID)
+			s.mu.Unlock()
 			task, err := s.taskRepo.GetByID(ctx, result.ID)
-			if err != nil {
+			if err != nil || task == nil {
 				log.Errorf("failed to save result of task %s. Reason: %v", result.ID, err)
+				continue
 			}
 			if result.Error != nil {
 				log.Errorf("task %s failed. Reason: %v", result.ID, result.Error)
 				task.Status = entity.TaskFailed
 			} else {
-				task.Status = entity.TaskCompleted
+				task.Status = entity.ReplicationStatus(result.Targets)
 			}
+			task.Targets = result.Targets
 			task.Start = result.Start
 			task.End = result.End
 			err = s.taskRepo.Update(ctx, task)
 			if err != nil {
 				log.Errorf("failed to save result of task %s. Reason: %v", result.ID, err)
 			}
+
+			if task.ParentID != "" {
+				s.childFinished(ctx, task)
+			}
 		}
 	}()
 }
 
-func (s *TaskService) Replication(ctx context.Context, objectID string, sourceStorage, targetStorage entity.Storage) (string, error) {
+// Replication copies the object to all target storages in a single task.
+func (s *TaskService) Replication(ctx context.Context, objectID string, sourceStorage entity.Storage, targetStorages []entity.Storage, opts entity.ReplicationOptions) (id string, err error) {
+	defer func() {
+		details := taskDetails(sourceStorage, targetStorages...)
+		details["object_id"] = objectID
+		s.audit.Record(ctx, entity.AuditTaskReplicate, "task/"+id, err, details)
+	}()
+
+	if len(targetStorages) == 0 {
+		return "", ErrNoTargets
+	}
+
 	task := entity.ReplicationTask{
-		ID:            entity.NewTaskID(),
-		ObjectID:      objectID,
-		SourceStorage: sourceStorage,
-		TargetStorage: targetStorage,
+		ID:             entity.NewTaskID(),
+		ObjectID:       objectID,
+		SourceStorage:  sourceStorage,
+		TargetStorages: targetStorages,
+		Options:        opts,
 	}
-	err := s.taskRepo.Add(ctx, &entity.Task{ID: task.ID, Start: time.Time{}, End: time.Time{}, Type: entity.Replication, Status: entity.TaskCreated})
+	tenantID, _ := entity.TenantFromContext(ctx)
+	s.limiter.SetTaskLimit(task.ID, tenantID, opts.MaxBandwidth)
+	err = s.enqueue(ctx, task)
 	if err != nil {
-		log.Errorf("failed to create replication task: %v", err)
+		s.limiter.RemoveTask(task.ID)
 		return "", err
 	}
-	s.tasksChan <- task
-	return task.ID, err
+	return task.ID, nil
+}
+
+func (s *TaskService) enqueue(ctx context.Context, task entity.ReplicationTask) error {
+	task.TenantID, _ = entity.TenantFromContext(ctx)
+	err := s.taskRepo.Add(ctx, &entity.Task{ID: task.ID, TenantID: task.TenantID, ParentID: task.ParentID, Start: time.Time{}, End: time.Time{}, Type: entity.Replication, Status: entity.TaskCreated})
+	if err != nil {
+		log.Errorf("failed to create replication task: %v", err)
+		return err
+	}
+
+	s.mu.Lock()
+	s.running[task.ID] = task
+	s.queue = append(s.queue, task)
+	s.mu.Unlock()
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// dispatch hands the queued tasks to the workers in order.
+func (s *TaskService) dispatch(ctx context.Context) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.queued:
+			}
+			continue
+		}
+		task := s.queue[0]
+		s.queue[0] = entity.ReplicationTask{}
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case s.tasksChan <- task:
+		}
+	}
+}
+
+// heartbeat keeps the tasks in progress alive and fails the tasks a stopped instance
+// left behind, whose progress was lost with it.
+func (s *TaskService) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(taskHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		s.mu.Lock()
+		ids := make([]string, 0, len(s.running)+len(s.bulks))
+		for id := range s.running {
+			ids = append(ids, id)
+		}
+		for id := range s.bulks {
+			ids = append(ids, id)
+		}
+		s.mu.Unlock()
+
+		if err := s.taskRepo.Touch(ctx, ids); err != nil {
+			log.Errorf("failed to touch tasks in progress: %v", err)
+		}
+		failed, err := s.taskRepo.FailStale(ctx, time.Now().Add(-taskStaleAfter))
+		if err != nil {
+			log.Errorf("failed to fail stale tasks: %v", err)
+		} else if failed > 0 {
+			log.Warnf("failed %d tasks left behind by a stopped instance", failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 func (s *TaskService) GetTask(ctx context.Context, taskID string) (*entity.Task, error) {
@@ -85,3 +218,72 @@ func (s *TaskService) GetTask(ctx context.Context, taskID string) (*entity.Task,
 	}
 	return task, err
 }
+
+func (s *TaskService) SetTaskBandwidth(ctx context.Context, taskID string, limit int64) (err error) {
+	defer func() {
+		s.audit.Record(ctx, entity.AuditTaskBandwidth, "task/"+taskID, err, map[string]string{"limit": strconv.FormatInt(limit, 10)})
+	}()
+
+	task, err := s.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+
+	if task.Status != entity.TaskCreated {
+		return ErrTaskFinished
+	}
+
+	log.Infof("set bandwidth limit of task %s to %d bytes/s", taskID, limit)
+	s.limiter.SetTaskLimit(taskID, task.TenantID, limit)
+	return nil
+}
+
+func (s *TaskService) ListChildTasks(ctx context.Context, taskID string) ([]*entity.Task, error) {
+	if _, err := s.GetTask(ctx, taskID); err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.taskRepo.ListByParent(ctx, taskID)
+	if err != nil {
+		log.Errorf("failed to list child tasks of task %s. Reason: %v", taskID, err)
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// UsesProvider reports whether a task of the tenant in progress reads from or writes to
+// a storage of the provider.
+func (s *TaskService) UsesProvider(ctx context.Context, providerID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, task := range s.running {
+		if !entity.InTenant(ctx, task.TenantID) {
+			continue
+		}
+		for _, storage := range append([]entity.Storage{task.SourceStorage}, task.TargetStorages...) {
+			if storage.ProviderID == providerID {
+				return true
+			}
+		}
+	}
+	for _, bulk := range s.bulks {
+		if !entity.InTenant(ctx, bulk.task.TenantID) {
+			continue
+		}
+		for _, storage := range bulk.storages {
+			if storage.ProviderID == providerID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// taskDetails describes the storages of a task for the audit trail.
+func taskDetails(source entity.Storage, targets ...entity.Storage) map[string]string {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.String())
+	}
+	return map[string]string{"source": source.String(), "targets": strings.Join(names, ",")}
+}
@@ -0,0 +1,73 @@
+package auditlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+var outcomes = map[entity.AuditOutcome]string{
+	entity.AuditSuccess: "success",
+	entity.AuditFailure: "failure",
+}
+
+type event struct {
+	ID        string            `json:"id"`
+	TenantID  string            `json:"tenant_id,omitempty"`
+	Time      time.Time         `json:"time"`
+	Actor     string            `json:"actor"`
+	Action    string            `json:"action"`
+	Target    string            `json:"target"`
+	RequestID string            `json:"request_id,omitempty"`
+	SourceIP  string            `json:"source_ip,omitempty"`
+	Outcome   string            `json:"outcome"`
+	Error     string            `json:"error,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// FileSink appends audit events to a file, one JSON object per line.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Add(ctx context.Context, e *entity.AuditEvent) error {
+	line, err := json.Marshal(&event{
+		ID:        e.ID,
+		TenantID:  e.TenantID,
+		Time:      e.Time,
+		Actor:     e.Actor,
+		Action:    e.Action,
+		Target:    e.Target,
+		RequestID: e.RequestID,
+		SourceIP:  e.SourceIP,
+		Outcome:   outcomes[e.Outcome],
+		Error:     e.Error,
+		Details:   e.Details,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
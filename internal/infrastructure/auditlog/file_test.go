@@ -0,0 +1,83 @@
+package auditlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestFileSink(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		event entity.AuditEvent
+		want  event
+	}{
+		{
+			name:  "success",
+			event: entity.AuditEvent{ID: "1", TenantID: "acme", Time: at, Actor: "alice", Action: entity.AuditAccountAdd, Target: "account/1", RequestID: "req-1", SourceIP: "10.0.0.1", Outcome: entity.AuditSuccess, Details: map[string]string{"provider": "p1"}},
+			want:  event{ID: "1", TenantID: "acme", Time: at, Actor: "alice", Action: entity.AuditAccountAdd, Target: "account/1", RequestID: "req-1", SourceIP: "10.0.0.1", Outcome: "success", Details: map[string]string{"provider": "p1"}},
+		},
+		{
+			name:  "failure",
+			event: entity.AuditEvent{ID: "2", Time: at, Actor: entity.AuditSystemActor, Action: entity.AuditUploadExpire, Target: "upload/1", Outcome: entity.AuditFailure, Error: "storage is down"},
+			want:  event{ID: "2", Time: at, Actor: entity.AuditSystemActor, Action: entity.AuditUploadExpire, Target: "upload/1", Outcome: "failure", Error: "storage is down"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			// Events of earlier runs are kept
+			if err := os.WriteFile(path, []byte("{\"id\":\"0\"}\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			sink, err := NewFileSink(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Add(context.Background(), &tt.event); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			var lines []event
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				var e event
+				if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+					t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+				}
+				lines = append(lines, e)
+			}
+			if len(lines) != 2 || lines[0].ID != "0" {
+				t.Fatalf("file holds %+v, want the earlier event and the new one", lines)
+			}
+			if !reflect.DeepEqual(lines[1], tt.want) {
+				t.Fatalf("line = %+v, want %+v", lines[1], tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFileSinkMissingDirectory(t *testing.T) {
+	if _, err := NewFileSink(filepath.Join(t.TempDir(), "missing", "audit.jsonl")); err == nil {
+		t.Fatal("NewFileSink() of a missing directory succeeded")
+	}
+}
@@ -0,0 +1,243 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/inview-team/gorynych/internal/infrastructure/secrets"
+)
+
+// Objects are encrypted in segments so that ranges can be read without the whole object.
+// Every SegmentSize bytes of plaintext, the last segment possibly shorter or empty, are
+// sealed with AES-256-GCM under the data key of the object. A sealed segment is the nonce
+// followed by the ciphertext and the tag. The index of the segment and whether it is the
+// last one are authenticated, so segments can't be reordered, dropped or cut off.
+const (
+	SegmentSize = 64 * 1024
+
+	nonceSize         = 12
+	tagSize           = 16
+	segmentOverhead   = nonceSize + tagSize
+	sealedSegmentSize = SegmentSize + segmentOverhead
+)
+
+// Object metadata holding the data key of encrypted objects. Copies of an object keep the
+// metadata, so any copy can be decrypted.
+const (
+	MetadataKeyID   = "gorynych-key-id"
+	MetadataDataKey = "gorynych-data-key"
+)
+
+var ErrCorrupted = errors.New("encrypted object is corrupted")
+
+// ObjectKey is the data key of an object.
+type ObjectKey struct {
+	// KeyID names the master key wrapping the data key.
+	KeyID   string
+	Wrapped []byte
+	aead    cipher.AEAD
+}
+
+// NewObjectKey generates a data key wrapped by the current master key of the keyring.
+func NewObjectKey(keyring *secrets.Keyring) (*ObjectKey, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %v", err)
+	}
+
+	keyID, wrapped, err := keyring.WrapKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return newObjectKey(keyID, wrapped, dataKey)
+}
+
+// OpenObjectKey unwraps the data key of an object.
+func OpenObjectKey(keyring *secrets.Keyring, keyID string, wrapped []byte) (*ObjectKey, error) {
+	dataKey, err := keyring.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return newObjectKey(keyID, wrapped, dataKey)
+}
+
+// KeyFromMetadata returns the data key of an object from its metadata, or nil if the
+// object isn't encrypted.
+func KeyFromMetadata(keyring *secrets.Keyring, metadata map[string]string) (*ObjectKey, error) {
+	keyID, encoded := lookup(metadata, MetadataKeyID), lookup(metadata, MetadataDataKey)
+	if keyID == "" && encoded == "" {
+		return nil, nil
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || keyID == "" {
+		return nil, fmt.Errorf("%w: invalid key metadata", ErrCorrupted)
+	}
+	return OpenObjectKey(keyring, keyID, wrapped)
+}
+
+// lookup finds a metadata value regardless of the case of the key, as storages may
+// return metadata keys capitalized.
+func lookup(metadata map[string]string, key string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// Encrypted reports whether the metadata is the one of an encrypted object.
+func Encrypted(metadata map[string]string) bool {
+	return lookup(metadata, MetadataKeyID) != ""
+}
+
+// KeepMetadata returns the metadata with the key metadata of the source object, if any,
+// so the copy of an encrypted object stays readable whatever its metadata.
+func KeepMetadata(source, metadata map[string]string) map[string]string {
+	keyID, dataKey := lookup(source, MetadataKeyID), lookup(source, MetadataDataKey)
+	if keyID == "" && dataKey == "" {
+		return metadata
+	}
+
+	kept := make(map[string]string, len(metadata)+2)
+	for k, v := range metadata {
+		if !strings.EqualFold(k, MetadataKeyID) && !strings.EqualFold(k, MetadataDataKey) {
+			kept[k] = v
+		}
+	}
+	kept[MetadataKeyID] = keyID
+	kept[MetadataDataKey] = dataKey
+	return kept
+}
+
+func newObjectKey(keyID string, wrapped []byte, dataKey []byte) (*ObjectKey, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectKey{KeyID: keyID, Wrapped: wrapped, aead: aead}, nil
+}
+
+// Metadata returns the object metadata describing the key.
+func (k *ObjectKey) Metadata() map[string]string {
+	return map[string]string{
+		MetadataKeyID:   k.KeyID,
+		MetadataDataKey: base64.StdEncoding.EncodeToString(k.Wrapped),
+	}
+}
+
+// Seal encrypts consecutive segments of the object starting with the segment at index.
+// Unless final is set, the plaintext must be made of whole segments. The final call seals
+// the last segment, even an empty one.
+func (k *ObjectKey) Seal(index int64, plaintext []byte, final bool) ([]byte, error) {
+	if !final && len(plaintext)%SegmentSize != 0 {
+		return nil, errors.New("plaintext is not made of whole segments")
+	}
+
+	segments := len(plaintext) / SegmentSize
+	if final {
+		segments = int(segmentCount(int64(len(plaintext))))
+	}
+
+	sealed := make([]byte, 0, len(plaintext)+segments*segmentOverhead)
+	for i := 0; i < segments; i++ {
+		segment := plaintext[i*SegmentSize : min((i+1)*SegmentSize, len(plaintext))]
+		nonce := make([]byte, nonceSize)
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, fmt.Errorf("failed to generate nonce: %v", err)
+		}
+		sealed = append(sealed, nonce...)
+		sealed = k.aead.Seal(sealed, nonce, segment, segmentData(index+int64(i), final && i == segments-1))
+	}
+	return sealed, nil
+}
+
+// SealPending encrypts the plaintext received after the last whole segment of an upload,
+// so it is stored encrypted until the next chunk completes the segment. The offset the
+// plaintext ends at is authenticated, so an older tail can't be given back.
+func (k *ObjectKey) SealPending(end int64, plaintext []byte) ([]byte, error) {
+	if len(plaintext) == 0 {
+		return nil, nil
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return k.aead.Seal(nonce, nonce, plaintext, pendingData(end)), nil
+}
+
+// OpenPending decrypts the tail sealed by SealPending.
+func (k *ObjectKey) OpenPending(end int64, sealed []byte) ([]byte, error) {
+	if len(sealed) == 0 {
+		return nil, nil
+	}
+	if len(sealed) < segmentOverhead {
+		return nil, fmt.Errorf("%w: pending data is too short", ErrCorrupted)
+	}
+	plaintext, err := k.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], pendingData(end))
+	if err != nil {
+		return nil, fmt.Errorf("%w: pending data: %v", ErrCorrupted, err)
+	}
+	return plaintext, nil
+}
+
+// pendingData is the additional data of a pending tail. It is longer than the one of
+// segments, so a tail can't pass for a segment.
+func pendingData(end int64) []byte {
+	return binary.BigEndian.AppendUint64([]byte("pending"), uint64(end))
+}
+
+func (k *ObjectKey) open(index int64, sealed []byte, final bool) ([]byte, error) {
+	if len(sealed) < segmentOverhead {
+		return nil, fmt.Errorf("%w: segment %d is too short", ErrCorrupted, index)
+	}
+	plaintext, err := k.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], segmentData(index, final))
+	if err != nil {
+		return nil, fmt.Errorf("%w: segment %d: %v", ErrCorrupted, index, err)
+	}
+	return plaintext, nil
+}
+
+// segmentData is the additional data of a segment: its index and whether it is the last one.
+func segmentData(index int64, final bool) []byte {
+	data := binary.BigEndian.AppendUint64(nil, uint64(index))
+	if final {
+		return append(data, 1)
+	}
+	return append(data, 0)
+}
+
+// segmentCount returns the number of segments of a plaintext of the given size.
+func segmentCount(size int64) int64 {
+	return max((size+SegmentSize-1)/SegmentSize, 1)
+}
+
+// PlaintextSize returns the size of the plaintext of an encrypted object.
+func PlaintextSize(sealedSize int64) (int64, error) {
+	segments := (sealedSize + sealedSegmentSize - 1) / sealedSegmentSize
+	// Only the last segment may be short, and only the segment of an empty object is empty
+	last := sealedSize - (segments-1)*sealedSegmentSize
+	if segments == 0 || last < segmentOverhead || (segments > 1 && last == segmentOverhead) {
+		return 0, fmt.Errorf("%w: invalid size %d", ErrCorrupted, sealedSize)
+	}
+	return sealedSize - segments*segmentOverhead, nil
+}
+
+// SealedRange returns the range of the encrypted object, bounds included, holding the
+// segments of the plaintext range from start to end.
+func SealedRange(sealedSize int64, start, end int64) (int64, int64) {
+	first, last := start/SegmentSize, end/SegmentSize
+	return first * sealedSegmentSize, min((last+1)*sealedSegmentSize, sealedSize) - 1
+}
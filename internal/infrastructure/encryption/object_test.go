@@ -0,0 +1,283 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/infrastructure/secrets"
+)
+
+func newTestKey(t *testing.T) *ObjectKey {
+	t.Helper()
+	master := make([]byte, 32)
+	if _, err := rand.Read(master); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GORYNYCH_TEST_MASTER_KEY", base64.StdEncoding.EncodeToString(master))
+	keyring, err := secrets.NewKeyring(secrets.Config{Key: &secrets.KeyConfig{ID: "test", Env: "GORYNYCH_TEST_MASTER_KEY"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewObjectKey(keyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// readRange decrypts the plaintext range of a sealed object as downloads do.
+func readRange(key *ObjectKey, sealed []byte, start, end int64) ([]byte, error) {
+	first, last := SealedRange(int64(len(sealed)), start, end)
+	reader, err := NewReader(key, io.NopCloser(bytes.NewReader(sealed[first:last+1])), int64(len(sealed)), start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func TestSealSegmentBoundaries(t *testing.T) {
+	key := newTestKey(t)
+
+	tests := []struct {
+		name     string
+		size     int
+		segments int
+	}{
+		{name: "empty", size: 0, segments: 1},
+		{name: "one byte", size: 1, segments: 1},
+		{name: "one byte short of a segment", size: SegmentSize - 1, segments: 1},
+		{name: "whole segment", size: SegmentSize, segments: 1},
+		{name: "one byte past a segment", size: SegmentSize + 1, segments: 2},
+		{name: "whole segments", size: 3 * SegmentSize, segments: 3},
+		{name: "short last segment", size: 3*SegmentSize - 5, segments: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext := randomBytes(t, tt.size)
+			sealed, err := key.Seal(0, plaintext, true)
+			if err != nil {
+				t.Fatalf("Seal() error = %v", err)
+			}
+			if want := tt.size + tt.segments*segmentOverhead; len(sealed) != want {
+				t.Fatalf("sealed size = %d, want %d", len(sealed), want)
+			}
+
+			size, err := PlaintextSize(int64(len(sealed)))
+			if err != nil {
+				t.Fatalf("PlaintextSize() error = %v", err)
+			}
+			if size != int64(tt.size) {
+				t.Fatalf("PlaintextSize() = %d, want %d", size, tt.size)
+			}
+			if tt.size == 0 {
+				return
+			}
+
+			ranges := [][2]int64{
+				{0, size - 1},
+				{0, 0},
+				{size - 1, size - 1},
+				{size / 2, size - 1},
+			}
+			if size > SegmentSize {
+				// A range across the boundary of the first two segments
+				ranges = append(ranges, [2]int64{SegmentSize - 2, min(SegmentSize+1, size-1)})
+			}
+			for _, r := range ranges {
+				got, err := readRange(key, sealed, r[0], r[1])
+				if err != nil {
+					t.Fatalf("range %d-%d: error = %v", r[0], r[1], err)
+				}
+				if !bytes.Equal(got, plaintext[r[0]:r[1]+1]) {
+					t.Fatalf("range %d-%d: plaintext differs", r[0], r[1])
+				}
+			}
+		})
+	}
+}
+
+func TestSealInChunks(t *testing.T) {
+	key := newTestKey(t)
+	plaintext := randomBytes(t, 2*SegmentSize+100)
+
+	// Uploads seal whole segments as chunks arrive and the rest with the last chunk
+	first, err := key.Seal(0, plaintext[:SegmentSize], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, err := key.Seal(1, plaintext[SegmentSize:], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readRange(key, append(first, rest...), 0, int64(len(plaintext))-1)
+	if err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatal("plaintext differs")
+	}
+
+	if _, err := key.Seal(0, plaintext[:SegmentSize+1], false); err == nil {
+		t.Fatal("Seal() of a partial segment which is not final succeeded")
+	}
+}
+
+func TestOpenTamperedObject(t *testing.T) {
+	key := newTestKey(t)
+	plaintext := randomBytes(t, 2*SegmentSize+100)
+	sealed, err := key.Seal(0, plaintext, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segment := func(i int) []byte {
+		return sealed[i*sealedSegmentSize : min((i+1)*sealedSegmentSize, len(sealed))]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	tests := []struct {
+		name   string
+		sealed []byte
+		key    *ObjectKey
+	}{
+		{
+			name: "flipped bit",
+			sealed: func() []byte {
+				tampered := bytes.Clone(sealed)
+				tampered[sealedSegmentSize+nonceSize+10] ^= 1
+				return tampered
+			}(),
+		},
+		{
+			name: "flipped nonce",
+			sealed: func() []byte {
+				tampered := bytes.Clone(sealed)
+				tampered[0] ^= 1
+				return tampered
+			}(),
+		},
+		{name: "reordered segments", sealed: join(segment(1), segment(0), segment(2))},
+		{name: "duplicated segment", sealed: join(segment(0), segment(0), segment(2))},
+		{name: "dropped last segment", sealed: join(segment(0), segment(1))},
+		{name: "cut off last segment", sealed: sealed[:len(sealed)-10]},
+		{name: "other data key", sealed: sealed, key: newTestKey(t)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := key
+			if tt.key != nil {
+				k = tt.key
+			}
+			size, err := PlaintextSize(int64(len(tt.sealed)))
+			if err == nil {
+				_, err = readRange(k, tt.sealed, 0, size-1)
+			}
+			if !errors.Is(err, ErrCorrupted) {
+				t.Fatalf("error = %v, want %v", err, ErrCorrupted)
+			}
+		})
+	}
+}
+
+func TestPlaintextSizeRejectsInvalidSizes(t *testing.T) {
+	tests := []struct {
+		name string
+		size int64
+	}{
+		{name: "empty", size: 0},
+		{name: "shorter than a segment overhead", size: segmentOverhead - 1},
+		{name: "empty segment after a whole one", size: sealedSegmentSize + segmentOverhead},
+		{name: "truncated overhead after a whole one", size: sealedSegmentSize + 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := PlaintextSize(tt.size); !errors.Is(err, ErrCorrupted) {
+				t.Fatalf("PlaintextSize(%d) error = %v, want %v", tt.size, err, ErrCorrupted)
+			}
+		})
+	}
+}
+
+func TestPending(t *testing.T) {
+	key := newTestKey(t)
+	tail := randomBytes(t, 100)
+	sealed, err := key.SealPending(1100, tail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, tail) {
+		t.Fatal("sealed tail holds the plaintext")
+	}
+
+	tests := []struct {
+		name    string
+		end     int64
+		sealed  []byte
+		key     *ObjectKey
+		wantErr bool
+	}{
+		{name: "same offset", end: 1100, sealed: sealed},
+		{name: "other offset", end: 1200, sealed: sealed, wantErr: true},
+		{
+			name: "flipped bit",
+			end:  1100,
+			sealed: func() []byte {
+				tampered := bytes.Clone(sealed)
+				tampered[len(tampered)-1] ^= 1
+				return tampered
+			}(),
+			wantErr: true,
+		},
+		{name: "too short", end: 1100, sealed: sealed[:segmentOverhead-1], wantErr: true},
+		{name: "other data key", end: 1100, sealed: sealed, key: newTestKey(t), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := key
+			if tt.key != nil {
+				k = tt.key
+			}
+			got, err := k.OpenPending(tt.end, tt.sealed)
+			if tt.wantErr {
+				if !errors.Is(err, ErrCorrupted) {
+					t.Fatalf("OpenPending() error = %v, want %v", err, ErrCorrupted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OpenPending() error = %v", err)
+			}
+			if !bytes.Equal(got, tail) {
+				t.Fatal("OpenPending() returned another tail")
+			}
+		})
+	}
+
+	// A tail can't pass for a segment of the object
+	for _, final := range []bool{false, true} {
+		if _, err := key.open(0, sealed, final); !errors.Is(err, ErrCorrupted) {
+			t.Fatalf("open() of a tail as a segment error = %v, want %v", err, ErrCorrupted)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// reader decrypts the segments read from the range of an encrypted object returned by
+// SealedRange and yields the plaintext range.
+type reader struct {
+	key *ObjectKey
+	src io.ReadCloser
+	// index of the next segment and of the last segment of the object
+	index int64
+	last  int64
+	// sealed size of the object
+	sealedSize int64
+	buf        []byte
+	plaintext  []byte
+	// skip is the offset of the range in its first segment
+	skip      int64
+	remaining int64
+}
+
+// NewReader decrypts the plaintext range from start to end, bounds included, of an
+// encrypted object of the given size. src reads the range returned by SealedRange.
+func NewReader(key *ObjectKey, src io.ReadCloser, sealedSize int64, start, end int64) (io.ReadCloser, error) {
+	size, err := PlaintextSize(sealedSize)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 || end < start || end >= size {
+		return nil, fmt.Errorf("invalid range %d-%d of %d bytes", start, end, size)
+	}
+
+	return &reader{
+		key:        key,
+		src:        src,
+		index:      start / SegmentSize,
+		last:       segmentCount(size) - 1,
+		sealedSize: sealedSize,
+		buf:        make([]byte, sealedSegmentSize),
+		skip:       start % SegmentSize,
+		remaining:  end - start + 1,
+	}, nil
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(r.plaintext) == 0 {
+		if r.remaining == 0 {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.plaintext)
+	r.plaintext = r.plaintext[n:]
+	return n, nil
+}
+
+// next decrypts the next segment.
+func (r *reader) next() error {
+	size := int64(sealedSegmentSize)
+	if r.index == r.last {
+		size = r.sealedSize - r.last*sealedSegmentSize
+	}
+
+	_, err := io.ReadFull(r.src, r.buf[:size])
+	if err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: segment %d is truncated", ErrCorrupted, r.index)
+		}
+		return err
+	}
+
+	plaintext, err := r.key.open(r.index, r.buf[:size], r.index == r.last)
+	if err != nil {
+		return err
+	}
+	r.index++
+
+	plaintext = plaintext[min(r.skip, int64(len(plaintext))):]
+	r.skip = 0
+	if int64(len(plaintext)) > r.remaining {
+		plaintext = plaintext[:r.remaining]
+	}
+	r.remaining -= int64(len(plaintext))
+	r.plaintext = plaintext
+	return nil
+}
+
+func (r *reader) Close() error {
+	return r.src.Close()
+}
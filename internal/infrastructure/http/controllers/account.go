@@ -5,4 +5,12 @@ type Account struct {
 	Region    string `json:"region"`
 	AccessKey string `json:"access_key"`
 	Secret    string `json:"secret"`
+	// Buckets are checked instead of listing the buckets, for accounts which may not list them.
+	Buckets []string `json:"buckets"`
+}
+
+type AccountUpdate struct {
+	Region    string `json:"region"`
+	AccessKey string `json:"access_key"`
+	Secret    string `json:"secret"`
 }
@@ -0,0 +1,5 @@
+package controllers
+
+type Bandwidth struct {
+	Limit int64 `json:"limit"`
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// Headers asking for server side encryption of an upload or a download. The customer key
+// implies SSE-C and must be given on every request touching the object.
+const (
+	SSEHeader         = "Gorynych-Server-Side-Encryption"
+	SSEKMSKeyIDHeader = "Gorynych-Server-Side-Encryption-Kms-Key-Id"
+	SSECustomerHeader = "Gorynych-Server-Side-Encryption-Customer-Key"
+)
+
+// NewServerSideEncryption returns the server side encryption asked by the headers of a
+// request, or nil if there is none. The encryption header is AES256 or aws:kms.
+func NewServerSideEncryption(header http.Header) (*entity.ServerSideEncryption, error) {
+	algorithm := header.Get(SSEHeader)
+	kmsKeyID := header.Get(SSEKMSKeyIDHeader)
+	customerKey := header.Get(SSECustomerHeader)
+	if algorithm == "" && kmsKeyID == "" && customerKey == "" {
+		return nil, nil
+	}
+
+	sse := &entity.ServerSideEncryption{KMSKeyID: kmsKeyID}
+	switch {
+	case customerKey != "":
+		if algorithm != "" && algorithm != "AES256" {
+			return nil, errors.New(SSEHeader + " must be AES256 with a customer key")
+		}
+		key, err := base64.StdEncoding.DecodeString(customerKey)
+		if err != nil {
+			return nil, errors.New(SSECustomerHeader + " must be base64")
+		}
+		sse.Mode, sse.CustomerKey = entity.SSEC, key
+	case algorithm == "AES256":
+		sse.Mode = entity.SSES3
+	case algorithm == "aws:kms", algorithm == "" && kmsKeyID != "":
+		sse.Mode = entity.SSEKMS
+	default:
+		return nil, errors.New(SSEHeader + " must be AES256 or aws:kms")
+	}
+
+	if err := sse.Validate(); err != nil {
+		return nil, err
+	}
+	return sse, nil
+}
+
+// ServerSideEncryption is the server side encryption of a replication source or target.
+type ServerSideEncryption struct {
+	// Mode is SSE-S3, SSE-KMS or SSE-C.
+	Mode     string `json:"mode"`
+	KMSKeyID string `json:"kms_key_id"`
+	// CustomerKey is the base64 key of SSE-C.
+	CustomerKey string `json:"customer_key"`
+}
+
+func (i *ServerSideEncryption) Encryption() (*entity.ServerSideEncryption, error) {
+	if i == nil {
+		return nil, nil
+	}
+
+	mode, ok := entity.ParseSSEMode(strings.TrimSpace(i.Mode))
+	if !ok {
+		return nil, errors.New("encryption mode must be one of SSE-S3, SSE-KMS, SSE-C")
+	}
+	key, err := base64.StdEncoding.DecodeString(i.CustomerKey)
+	if err != nil {
+		return nil, errors.New("customer_key must be base64")
+	}
+
+	sse := &entity.ServerSideEncryption{Mode: mode, KMSKeyID: i.KMSKeyID}
+	if len(key) != 0 {
+		sse.CustomerKey = key
+	}
+	if err := sse.Validate(); err != nil {
+		return nil, err
+	}
+	return sse, nil
+}
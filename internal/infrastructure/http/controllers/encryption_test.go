@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestNewServerSideEncryption(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    *entity.ServerSideEncryption
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "SSE-S3", headers: map[string]string{SSEHeader: "AES256"}, want: &entity.ServerSideEncryption{Mode: entity.SSES3}},
+		{name: "SSE-KMS", headers: map[string]string{SSEHeader: "aws:kms"}, want: &entity.ServerSideEncryption{Mode: entity.SSEKMS}},
+		{name: "SSE-KMS key", headers: map[string]string{SSEKMSKeyIDHeader: "key-1"}, want: &entity.ServerSideEncryption{Mode: entity.SSEKMS, KMSKeyID: "key-1"}},
+		{name: "SSE-C", headers: map[string]string{SSECustomerHeader: encodedKey}, want: &entity.ServerSideEncryption{Mode: entity.SSEC, CustomerKey: key}},
+		{name: "SSE-C with AES256", headers: map[string]string{SSEHeader: "AES256", SSECustomerHeader: encodedKey}, want: &entity.ServerSideEncryption{Mode: entity.SSEC, CustomerKey: key}},
+		{name: "unknown algorithm", headers: map[string]string{SSEHeader: "DES"}, wantErr: true},
+		{name: "SSE-C with aws:kms", headers: map[string]string{SSEHeader: "aws:kms", SSECustomerHeader: encodedKey}, wantErr: true},
+		{name: "customer key not in base64", headers: map[string]string{SSECustomerHeader: "not base64!"}, wantErr: true},
+		{name: "short customer key", headers: map[string]string{SSECustomerHeader: base64.StdEncoding.EncodeToString([]byte("short"))}, wantErr: true},
+		{name: "SSE-S3 with a KMS key", headers: map[string]string{SSEHeader: "AES256", SSEKMSKeyIDHeader: "key-1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+			got, err := NewServerSideEncryption(header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewServerSideEncryption() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("NewServerSideEncryption() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerSideEncryptionOfTask(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	tests := []struct {
+		name    string
+		input   *ServerSideEncryption
+		want    *entity.ServerSideEncryption
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "SSE-S3", input: &ServerSideEncryption{Mode: "SSE-S3"}, want: &entity.ServerSideEncryption{Mode: entity.SSES3}},
+		{name: "SSE-KMS", input: &ServerSideEncryption{Mode: " sse-kms ", KMSKeyID: "key-1"}, want: &entity.ServerSideEncryption{Mode: entity.SSEKMS, KMSKeyID: "key-1"}},
+		{name: "SSE-C", input: &ServerSideEncryption{Mode: "SSE-C", CustomerKey: base64.StdEncoding.EncodeToString(key)}, want: &entity.ServerSideEncryption{Mode: entity.SSEC, CustomerKey: key}},
+		{name: "unknown mode", input: &ServerSideEncryption{Mode: "AES256"}, wantErr: true},
+		{name: "SSE-C without key", input: &ServerSideEncryption{Mode: "SSE-C"}, wantErr: true},
+		{name: "customer key not in base64", input: &ServerSideEncryption{Mode: "SSE-C", CustomerKey: "not base64!"}, wantErr: true},
+		{name: "SSE-S3 with a customer key", input: &ServerSideEncryption{Mode: "SSE-S3", CustomerKey: base64.StdEncoding.EncodeToString(key)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.input.Encryption()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Encryption() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Encryption() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// StorageHeader names the storage of a new upload as "provider_id/bucket".
+const StorageHeader = "Gorynych-Storage"
+
+// Upload-Metadata keys naming the storage of a new upload
+const (
+	providerKey = "provider"
+	bucketKey   = "bucket"
+)
+
+// NewTargetStorage returns the storage requested for a new upload, or nil if the client
+// left the choice to the server. The header takes precedence over the metadata keys.
+func NewTargetStorage(header string, metadata map[string]string) (*Storage, error) {
+	if header != "" {
+		providerID, bucket, found := strings.Cut(header, "/")
+		if !found || providerID == "" || bucket == "" {
+			return nil, errors.New(StorageHeader + " must be provider_id/bucket")
+		}
+		return &Storage{ProviderID: providerID, Bucket: bucket}, nil
+	}
+
+	providerID, hasProvider := metadata[providerKey]
+	bucket, hasBucket := metadata[bucketKey]
+	if !hasProvider && !hasBucket {
+		return nil, nil
+	}
+
+	if providerID == "" || bucket == "" {
+		return nil, errors.New("upload metadata must set both provider and bucket")
+	}
+	return &Storage{ProviderID: providerID, Bucket: bucket}, nil
+}
+
+var ErrInvalidRange = errors.New("range must be a single bytes range within the object")
+
+// Range is the part of an object requested by the Range header, bounds included.
+type Range struct {
+	Start int64
+	End   int64
+}
+
+// NewRange returns the range requested by the Range header of a download, or nil if the
+// whole object is requested. Only single ranges are supported.
+func NewRange(header string, size int64) (*Range, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return nil, ErrInvalidRange
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return nil, ErrInvalidRange
+	}
+
+	if first == "" {
+		// The suffix of the object
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return nil, ErrInvalidRange
+		}
+		return &Range{Start: max(size-n, 0), End: size - 1}, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return nil, ErrInvalidRange
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return nil, ErrInvalidRange
+		}
+		end = min(end, size-1)
+	}
+	return &Range{Start: start, End: end}, nil
+}
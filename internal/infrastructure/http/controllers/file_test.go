@@ -0,0 +1,39 @@
+package controllers
+
+import "testing"
+
+func TestNewTargetStorage(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		metadata map[string]string
+		want     *Storage
+		wantErr  bool
+	}{
+		{name: "left to the server"},
+		{name: "header", header: "p1/b1", want: &Storage{ProviderID: "p1", Bucket: "b1"}},
+		{name: "metadata", metadata: map[string]string{"provider": "p1", "bucket": "b1"}, want: &Storage{ProviderID: "p1", Bucket: "b1"}},
+		{
+			name:     "header over metadata",
+			header:   "p2/b2",
+			metadata: map[string]string{"provider": "p1", "bucket": "b1"},
+			want:     &Storage{ProviderID: "p2", Bucket: "b2"},
+		},
+		{name: "header without bucket", header: "p1", wantErr: true},
+		{name: "header with empty bucket", header: "p1/", wantErr: true},
+		{name: "metadata without bucket", metadata: map[string]string{"provider": "p1"}, wantErr: true},
+		{name: "metadata without provider", metadata: map[string]string{"bucket": "b1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewTargetStorage(tt.header, tt.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTargetStorage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Fatalf("NewTargetStorage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
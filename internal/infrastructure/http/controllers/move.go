@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+type MoveInput struct {
+	SyncInput
+	ObjectID       string `json:"object_id"`
+	DryRun         bool   `json:"dry_run"`
+	KeepSourceDays int    `json:"keep_source_days"`
+}
+
+func (i *MoveInput) Options() (entity.MoveOptions, error) {
+	if i.KeepSourceDays < 0 {
+		return entity.MoveOptions{}, errors.New("keep_source_days must not be negative")
+	}
+
+	opts := entity.MoveOptions{ObjectID: i.ObjectID, KeepSourceDays: i.KeepSourceDays}
+	if i.ObjectID == "" {
+		sync, err := i.SyncInput.Options()
+		if err != nil {
+			return entity.MoveOptions{}, err
+		}
+		opts.Sync = sync
+		return opts, nil
+	}
+
+	if i.Prefix != "" || len(i.Include) > 0 || len(i.Exclude) > 0 {
+		return entity.MoveOptions{}, errors.New("object_id and prefix filters are mutually exclusive")
+	}
+
+	if len(i.TargetStorages) > 0 {
+		return entity.MoveOptions{}, errors.New("target_storages is not supported by move, use target_storage")
+	}
+
+	replication, err := i.ReplicateInput.Options()
+	if err != nil {
+		return entity.MoveOptions{}, err
+	}
+	opts.Sync.Replication = replication
+	return opts, nil
+}
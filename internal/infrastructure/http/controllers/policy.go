@@ -0,0 +1,32 @@
+package controllers
+
+import "github.com/inview-team/gorynych/internal/domain/entity"
+
+type PolicyInput struct {
+	Name           string            `json:"name"`
+	Enabled        *bool             `json:"enabled"`
+	SourceStorage  Storage           `json:"source_storage"`
+	TargetStorages []Storage         `json:"target_storages"`
+	Metadata       map[string]string `json:"metadata"`
+	MinSize        int64             `json:"min_size"`
+	MaxSize        int64             `json:"max_size"`
+}
+
+func (i *PolicyInput) Policy() entity.ReplicationPolicy {
+	var targets []entity.Storage
+	for _, target := range i.TargetStorages {
+		targets = append(targets, entity.Storage(target))
+	}
+
+	// Policies are enabled unless explicitly disabled
+	enabled := i.Enabled == nil || *i.Enabled
+	return entity.ReplicationPolicy{
+		Name:     i.Name,
+		Enabled:  enabled,
+		Source:   entity.Storage(i.SourceStorage),
+		Targets:  targets,
+		Metadata: i.Metadata,
+		MinSize:  i.MinSize,
+		MaxSize:  i.MaxSize,
+	}
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import "github.com/inview-team/gorynych/internal/domain/entity"
+
+type ProviderInput struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	Endpoint         string `json:"endpoint"`
+	Region           string `json:"region"`
+	PathStyle        bool   `json:"path_style"`
+	SkipTLSVerify    bool   `json:"skip_tls_verify"`
+	SignatureVersion string `json:"signature_version"`
+	// Encryption is SSE-S3 or SSE-KMS, applied to objects written without one.
+	Encryption string `json:"encryption"`
+	KMSKeyID   string `json:"kms_key_id"`
+}
+
+func (i *ProviderInput) Provider() *entity.Provider {
+	provider := entity.NewProvider(i.ID, i.Name, i.Endpoint)
+	provider.Region = i.Region
+	provider.PathStyle = i.PathStyle
+	provider.SkipTLSVerify = i.SkipTLSVerify
+	if i.SignatureVersion != "" {
+		provider.SignatureVersion = entity.SignatureVersion(i.SignatureVersion)
+	}
+	if i.Encryption != "" {
+		mode, _ := entity.ParseSSEMode(i.Encryption)
+		provider.Encryption = &entity.ServerSideEncryption{Mode: mode, KMSKeyID: i.KMSKeyID}
+	}
+	return provider
+}
@@ -1,11 +1,118 @@
 package controllers
 
+import (
+	"errors"
+	"strings"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
 type ReplicateInput struct {
-	SourceStorage Storage `json:"source_storage"`
-	TargetStorage Storage `json:"target_storage"`
+	SourceStorage     Storage          `json:"source_storage"`
+	TargetStorage     Storage          `json:"target_storage"`
+	TargetStorages    []Storage        `json:"target_storages"`
+	MaxBandwidth      int64            `json:"max_bandwidth"`
+	MetadataDirective string           `json:"metadata_directive"`
+	Attributes        ObjectAttributes `json:"attributes"`
+	TargetKey         string           `json:"target_key"`
+	PrefixRewrite     *PrefixRewrite   `json:"prefix_rewrite"`
+	ConflictPolicy    string           `json:"conflict_policy"`
+	// SourceEncryption holds the SSE-C key of the source objects, if any.
+	SourceEncryption *ServerSideEncryption `json:"source_encryption"`
+	// TargetEncryption is the server side encryption of the copies.
+	TargetEncryption *ServerSideEncryption `json:"target_encryption"`
+}
+
+type PrefixRewrite struct {
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
 type Storage struct {
 	ProviderID string `json:"provider_id"`
 	Bucket     string `json:"bucket"`
 }
+
+type ObjectAttributes struct {
+	ContentType        string            `json:"content_type"`
+	CacheControl       string            `json:"cache_control"`
+	ContentEncoding    string            `json:"content_encoding"`
+	ContentDisposition string            `json:"content_disposition"`
+	ContentLanguage    string            `json:"content_language"`
+	Metadata           map[string]string `json:"metadata"`
+	Tags               map[string]string `json:"tags"`
+}
+
+// Targets returns target_storages together with target_storage, without duplicates.
+func (i *ReplicateInput) Targets() []entity.Storage {
+	var targets []entity.Storage
+	seen := make(map[Storage]bool)
+	for _, target := range append([]Storage{i.TargetStorage}, i.TargetStorages...) {
+		if target.Bucket == "" || seen[target] {
+			continue
+		}
+		seen[target] = true
+		targets = append(targets, entity.Storage(target))
+	}
+	return targets
+}
+
+func (i *ReplicateInput) Options() (entity.ReplicationOptions, error) {
+	if i.MaxBandwidth < 0 {
+		return entity.ReplicationOptions{}, errors.New("max_bandwidth must not be negative")
+	}
+
+	var directive entity.MetadataDirective
+	switch strings.ToUpper(i.MetadataDirective) {
+	case "", "COPY":
+		directive = entity.MetadataCopy
+	case "REPLACE":
+		directive = entity.MetadataReplace
+	default:
+		return entity.ReplicationOptions{}, errors.New("metadata_directive must be COPY or REPLACE")
+	}
+
+	if i.TargetKey != "" && i.PrefixRewrite != nil {
+		return entity.ReplicationOptions{}, errors.New("target_key and prefix_rewrite are mutually exclusive")
+	}
+
+	var policy entity.ConflictPolicy
+	switch strings.ToLower(i.ConflictPolicy) {
+	case "", "overwrite":
+		policy = entity.ConflictOverwrite
+	case "skip_if_exists":
+		policy = entity.ConflictSkipIfExists
+	case "skip_if_same":
+		policy = entity.ConflictSkipIfSame
+	case "fail":
+		policy = entity.ConflictFail
+	default:
+		return entity.ReplicationOptions{}, errors.New("conflict_policy must be one of overwrite, skip_if_exists, skip_if_same, fail")
+	}
+
+	source, err := i.SourceEncryption.Encryption()
+	if err != nil {
+		return entity.ReplicationOptions{}, err
+	}
+	if source != nil && source.Mode != entity.SSEC {
+		return entity.ReplicationOptions{}, errors.New("source_encryption is only needed for SSE-C, the storage decrypts other objects")
+	}
+	target, err := i.TargetEncryption.Encryption()
+	if err != nil {
+		return entity.ReplicationOptions{}, err
+	}
+
+	opts := entity.ReplicationOptions{
+		MaxBandwidth:      i.MaxBandwidth,
+		MetadataDirective: directive,
+		Attributes:        entity.ObjectAttributes(i.Attributes),
+		TargetKey:         i.TargetKey,
+		ConflictPolicy:    policy,
+		SourceEncryption:  source,
+		TargetEncryption:  target,
+	}
+	if i.PrefixRewrite != nil {
+		opts.PrefixRewrite = &entity.PrefixRewrite{From: i.PrefixRewrite.From, To: i.PrefixRewrite.To}
+	}
+	return opts, nil
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// ScheduleInput takes the options of the started tasks: those of a replication in
+// replicate mode, those of a sync or mirror otherwise.
+type ScheduleInput struct {
+	MirrorInput
+	Cron     string `json:"cron"`
+	Mode     string `json:"mode"`
+	ObjectID string `json:"object_id"`
+}
+
+func (i *ScheduleInput) Schedule() (entity.Schedule, error) {
+	var mode entity.ScheduleMode
+	switch strings.ToLower(i.Mode) {
+	case "replicate":
+		mode = entity.ScheduleReplication
+	case "sync":
+		mode = entity.ScheduleSync
+	case "mirror":
+		mode = entity.ScheduleMirror
+	default:
+		return entity.Schedule{}, errors.New("mode must be one of replicate, sync, mirror")
+	}
+
+	if mode == entity.ScheduleReplication && i.ObjectID == "" {
+		return entity.Schedule{}, errors.New("object_id is required in replicate mode")
+	}
+
+	if len(i.TargetStorages) > 0 {
+		return entity.Schedule{}, errors.New("target_storages is not supported by schedules, use target_storage")
+	}
+
+	var opts entity.SyncOptions
+	var err error
+	switch mode {
+	case entity.ScheduleReplication:
+		if i.Prefix != "" || len(i.Include) > 0 || len(i.Exclude) > 0 || i.Delete {
+			return entity.Schedule{}, errors.New("prefix, include, exclude and delete are not supported in replicate mode")
+		}
+		opts.Replication, err = i.ReplicateInput.Options()
+	case entity.ScheduleSync:
+		if i.Delete {
+			return entity.Schedule{}, errors.New("delete is only supported in mirror mode")
+		}
+		opts, err = i.SyncInput.Options()
+	case entity.ScheduleMirror:
+		opts, err = i.MirrorInput.Options()
+	}
+	if err != nil {
+		return entity.Schedule{}, err
+	}
+
+	// Customer keys would have to be stored with the schedule
+	target := opts.Replication.TargetEncryption
+	if opts.Replication.SourceEncryption != nil || (target != nil && target.Mode == entity.SSEC) {
+		return entity.Schedule{}, errors.New("schedules do not support SSE-C")
+	}
+
+	return entity.Schedule{
+		Cron:          i.Cron,
+		Mode:          mode,
+		SourceStorage: entity.Storage(i.SourceStorage),
+		TargetStorage: entity.Storage(i.TargetStorage),
+		ObjectID:      i.ObjectID,
+		Options:       opts,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"errors"
+	"path"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+type SyncInput struct {
+	ReplicateInput
+	Prefix  string   `json:"prefix"`
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+func (i *SyncInput) Options() (entity.SyncOptions, error) {
+	if i.TargetKey != "" {
+		return entity.SyncOptions{}, errors.New("target_key is not supported by sync, use prefix_rewrite")
+	}
+
+	if len(i.TargetStorages) > 0 {
+		return entity.SyncOptions{}, errors.New("target_storages is not supported by sync, use target_storage")
+	}
+
+	for _, pattern := range append(append([]string{}, i.Include...), i.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return entity.SyncOptions{}, errors.New("invalid glob pattern: " + pattern)
+		}
+	}
+
+	replication, err := i.ReplicateInput.Options()
+	if err != nil {
+		return entity.SyncOptions{}, err
+	}
+
+	return entity.SyncOptions{
+		Prefix:      i.Prefix,
+		Include:     i.Include,
+		Exclude:     i.Exclude,
+		Replication: replication,
+	}, nil
+}
+
+type MirrorInput struct {
+	SyncInput
+	Delete bool `json:"delete"`
+}
+
+func (i *MirrorInput) Options() (entity.SyncOptions, error) {
+	opts, err := i.SyncInput.Options()
+	if err != nil {
+		return entity.SyncOptions{}, err
+	}
+	opts.Delete = i.Delete
+	return opts, nil
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+type UploadTokenInput struct {
+	// Size is the largest upload the token allows.
+	Size          int64             `json:"size"`
+	TargetStorage *Storage          `json:"target_storage"`
+	Metadata      map[string]string `json:"metadata"`
+	// ExpiresIn is the lifetime of the token in seconds. Zero means the default.
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+func (i *UploadTokenInput) Storage() (*entity.Storage, error) {
+	if i.TargetStorage == nil {
+		return nil, nil
+	}
+	if i.TargetStorage.ProviderID == "" || i.TargetStorage.Bucket == "" {
+		return nil, errors.New("target_storage needs both provider_id and bucket")
+	}
+	return (*entity.Storage)(i.TargetStorage), nil
+}
+
+func (i *UploadTokenInput) TTL() (time.Duration, error) {
+	if i.ExpiresIn < 0 {
+		return 0, errors.New("expires_in must not be negative")
+	}
+	return time.Duration(i.ExpiresIn) * time.Second, nil
+}
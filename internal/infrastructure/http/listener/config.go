@@ -0,0 +1,71 @@
+package listener
+
+import "time"
+
+// Config describes the listeners of the HTTP server.
+type Config struct {
+	Address string        `yaml:"address,omitempty"`
+	Timeout TimeoutConfig `yaml:"timeout,omitempty"`
+	// MaxHeaderBytes limits the request headers. Zero means the net/http default.
+	MaxHeaderBytes int `yaml:"max_header_bytes,omitempty"`
+	// MaxBodyBytes limits request bodies, tus chunks included. Zero means no limit.
+	MaxBodyBytes int64     `yaml:"max_body_bytes,omitempty"`
+	TLS          TLSConfig `yaml:"tls,omitempty"`
+	// Admin serves internal endpoints on a separate address.
+	Admin AdminConfig `yaml:"admin,omitempty"`
+}
+
+type TimeoutConfig struct {
+	Idle       time.Duration `yaml:"idle"`
+	Read       time.Duration `yaml:"read"`
+	ReadHeader time.Duration `yaml:"read_header"`
+	Write      time.Duration `yaml:"write"`
+	// Shutdown is how long requests in flight may take to finish on shutdown.
+	Shutdown time.Duration `yaml:"shutdown"`
+}
+
+// TLSConfig turns on TLS when both the certificate and its key are set. The files are
+// watched and reloaded when they change, so certificates can be renewed in place.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+	// ClientCAFile verifies client certificates against these CAs.
+	ClientCAFile string `yaml:"client_ca_file,omitempty"`
+	// ClientAuth is "require" to reject clients without a certificate, or "verify_if_given".
+	ClientAuth string `yaml:"client_auth,omitempty"`
+	// ReloadInterval is how often the files are checked for changes.
+	ReloadInterval time.Duration `yaml:"reload_interval,omitempty"`
+}
+
+// AdminConfig describes the listener of health checks and profiling. It is off unless an
+// address is set, and should only be reachable from inside the deployment.
+type AdminConfig struct {
+	Address string `yaml:"address,omitempty"`
+	// TLS serves the admin listener with the TLS configuration of the server, client
+	// certificates included.
+	TLS bool `yaml:"tls,omitempty"`
+	// Profiling serves pprof, which tells the command line and lets callers profile the
+	// server. Profiles must be shorter than the write timeout.
+	Profiling bool `yaml:"profiling,omitempty"`
+}
+
+var (
+	DefaultConfig = Config{
+		Address: ":30000",
+		Timeout: TimeoutConfig{
+			Idle:       time.Second * 30,
+			Read:       time.Second * 30,
+			ReadHeader: time.Second * 10,
+			Write:      time.Second * 30,
+			Shutdown:   time.Second * 15,
+		},
+		TLS: TLSConfig{
+			ReloadInterval: time.Minute,
+		},
+	}
+)
+
+// Enabled reports whether the server is served with TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
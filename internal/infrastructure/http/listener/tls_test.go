@@ -0,0 +1,248 @@
+package listener
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a self-signed certificate, usable as its own CA, with its files.
+type testCert struct {
+	cert     tls.Certificate
+	leaf     *x509.Certificate
+	certFile string
+	keyFile  string
+}
+
+func newTestCert(t *testing.T, dir, name string) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &testCert{
+		cert:     tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf},
+		leaf:     leaf,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	writeFile(t, c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return c
+}
+
+func writeFile(t *testing.T, name string, content []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewReloader(t *testing.T) {
+	dir := t.TempDir()
+	server := newTestCert(t, dir, "server")
+	client := newTestCert(t, dir, "client")
+	notPEM := filepath.Join(dir, "not.pem")
+	writeFile(t, notPEM, []byte("not a certificate"))
+
+	tests := []struct {
+		name           string
+		cfg            TLSConfig
+		wantErr        bool
+		wantClientAuth tls.ClientAuthType
+	}{
+		{name: "server certificate", cfg: TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile}, wantClientAuth: tls.NoClientCert},
+		{name: "client CAs", cfg: TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: client.certFile}, wantClientAuth: tls.RequireAndVerifyClientCert},
+		{
+			name:           "client certificate if given",
+			cfg:            TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: client.certFile, ClientAuth: "verify_if_given"},
+			wantClientAuth: tls.VerifyClientCertIfGiven,
+		},
+		{name: "missing key", cfg: TLSConfig{CertFile: server.certFile}, wantErr: true},
+		{name: "key of another certificate", cfg: TLSConfig{CertFile: server.certFile, KeyFile: client.keyFile}, wantErr: true},
+		{name: "missing certificate file", cfg: TLSConfig{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: server.keyFile}, wantErr: true},
+		{name: "client auth without CAs", cfg: TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientAuth: "require"}, wantErr: true},
+		{name: "unknown client auth", cfg: TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: client.certFile, ClientAuth: "optional"}, wantErr: true},
+		{name: "client CAs without certificates", cfg: TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: notPEM}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReloader(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewReloader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if r.current.ClientAuth != tt.wantClientAuth {
+				t.Fatalf("client auth = %v, want %v", r.current.ClientAuth, tt.wantClientAuth)
+			}
+		})
+	}
+}
+
+func TestReloaderHandshake(t *testing.T) {
+	dir := t.TempDir()
+	server := newTestCert(t, dir, "server")
+	client := newTestCert(t, dir, "client")
+	stranger := newTestCert(t, dir, "stranger")
+
+	tests := []struct {
+		name       string
+		clientAuth string
+		clientCert *testCert
+		wantErr    bool
+	}{
+		{name: "required client certificate", clientAuth: "require", clientCert: client},
+		{name: "missing required client certificate", clientAuth: "require", wantErr: true},
+		{name: "unknown client certificate", clientAuth: "require", clientCert: stranger, wantErr: true},
+		{name: "client certificate not given", clientAuth: "verify_if_given"},
+		{name: "unknown client certificate given", clientAuth: "verify_if_given", clientCert: stranger, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReloader(TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: client.certFile, ClientAuth: tt.clientAuth})
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			srv.TLS = r.Config()
+			srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+			srv.StartTLS()
+			defer srv.Close()
+
+			roots := x509.NewCertPool()
+			roots.AddCert(server.leaf)
+			config := &tls.Config{RootCAs: roots}
+			if tt.clientCert != nil {
+				// Sent even when the server does not trust its issuer
+				config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+					return &tt.clientCert.cert, nil
+				}
+			}
+			httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+			resp, err := httpClient.Get(srv.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GET error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				resp.Body.Close()
+			}
+		})
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	tests := []struct {
+		name string
+		// replace writes new files over the certificate and returns the one expected after
+		replace func(t *testing.T, dir string, current *testCert) *testCert
+	}{
+		{
+			name: "renewed certificate",
+			replace: func(t *testing.T, dir string, current *testCert) *testCert {
+				renewed := newTestCert(t, t.TempDir(), "renewed")
+				cert, _ := os.ReadFile(renewed.certFile)
+				key, _ := os.ReadFile(renewed.keyFile)
+				writeFile(t, current.keyFile, key)
+				writeFile(t, current.certFile, cert)
+				return renewed
+			},
+		},
+		{
+			name: "broken certificate keeps the previous one",
+			replace: func(t *testing.T, dir string, current *testCert) *testCert {
+				writeFile(t, current.certFile, []byte("not a certificate"))
+				return current
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			server := newTestCert(t, dir, "server")
+			r, err := NewReloader(TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ReloadInterval: 10 * time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go r.Watch(ctx)
+
+			want := tt.replace(t, dir, server)
+			// Make the change visible on file systems with coarse modification times
+			later := time.Now().Add(time.Minute)
+			for _, file := range []string{server.certFile, server.keyFile} {
+				if err := os.Chtimes(file, later, later); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			getCertificate := r.Config().GetCertificate
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				cert, err := getCertificate(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(cert.Certificate[0]) == string(want.cert.Certificate[0]) {
+					// Give the watcher time to pick a broken file, which must change nothing
+					time.Sleep(50 * time.Millisecond)
+					if cert, _ := getCertificate(nil); string(cert.Certificate[0]) == string(want.cert.Certificate[0]) {
+						return
+					}
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("certificate is %s, want %s", cert.Leaf.Subject.CommonName, want.leaf.Subject.CommonName)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
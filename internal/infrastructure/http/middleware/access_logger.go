@@ -16,7 +16,15 @@ type Logger struct {
 func (l *Logger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	l.handler.ServeHTTP(w, r)
-	log.Infof("%s %s %v", r.Method, r.URL.Path, r.Header, time.Since(start))
+	header := r.Header.Clone()
+	// Tokens must not end up in the logs
+	if header.Get("Authorization") != "" {
+		header.Set("Authorization", "[redacted]")
+	}
+	if header.Get(UploadTokenHeader) != "" {
+		header.Set(UploadTokenHeader, "[redacted]")
+	}
+	log.Infof("%s %s %v %v", r.Method, r.URL.Path, header, time.Since(start))
 }
 
 // NewLogger constructs a new Logger middleware handler
@@ -0,0 +1,313 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	log "github.com/sirupsen/logrus"
+)
+
+// AuthConfig describes how bearer tokens are verified. At least one of the HMAC secret,
+// the public keys or the JWKS file must be set unless authentication is disabled.
+type AuthConfig struct {
+	// Disabled leaves every route open. Only meant for local development.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// HMACSecretFile holds the secret of HS256/HS384/HS512 tokens.
+	HMACSecretFile string `yaml:"hmac_secret_file,omitempty"`
+	// HMACSecretEnv names the environment variable holding the HMAC secret.
+	HMACSecretEnv string `yaml:"hmac_secret_env,omitempty"`
+	// PublicKeyFiles are PEM encoded RSA or ECDSA public keys.
+	PublicKeyFiles []string `yaml:"public_key_files,omitempty"`
+	// JWKSFile is a JSON Web Key Set with RSA or EC keys.
+	JWKSFile string        `yaml:"jwks_file,omitempty"`
+	Issuer   string        `yaml:"issuer,omitempty"`
+	Audience string        `yaml:"audience,omitempty"`
+	Leeway   time.Duration `yaml:"leeway,omitempty"`
+	// Roles grant scopes to the tokens listing them in the "roles" claim.
+	Roles map[string][]string `yaml:"roles,omitempty"`
+	// Policy gives the scope each route requires. It replaces the default policy.
+	Policy []PolicyRule `yaml:"policy,omitempty"`
+	// TenantClaim names the claim holding the tenant of the caller. Callers only see the
+	// accounts, uploads and tasks of their tenant; callers without a tenant see everything.
+	TenantClaim string `yaml:"tenant_claim,omitempty"`
+	// RequireTenant rejects tokens without a tenant. Otherwise such tokens see the
+	// records of every tenant.
+	RequireTenant bool `yaml:"require_tenant,omitempty"`
+	// UploadTokens lets browsers upload with short lived tokens minted by a backend.
+	UploadTokens UploadTokenConfig `yaml:"upload_tokens,omitempty"`
+}
+
+var (
+	DefaultAuthConfig = AuthConfig{
+		Leeway:       30 * time.Second,
+		Policy:       DefaultPolicy,
+		TenantClaim:  "tenant",
+		UploadTokens: DefaultUploadTokenConfig,
+	}
+)
+
+var (
+	hmacMethods      = []string{"HS256", "HS384", "HS512"}
+	publicKeyMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string
+	Tenant  string
+	Scopes  []string
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the caller of the request, or nil if it is anonymous.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// Authenticator verifies the bearer token of requests.
+type Authenticator struct {
+	disabled      bool
+	tenantClaim   string
+	requireTenant bool
+	roles         map[string][]string
+	policy        []PolicyRule
+	hmacSecret    []byte
+	uploadTokens  *UploadTokens
+	// Public keys by key id. Keys without an id are stored under an empty id.
+	publicKeys map[string][]jwt.VerificationKey
+	parser     *jwt.Parser
+}
+
+func NewAuthenticator(cfg AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		disabled:      cfg.Disabled,
+		tenantClaim:   cfg.TenantClaim,
+		requireTenant: cfg.RequireTenant,
+		roles:         cfg.Roles,
+		policy:        cfg.Policy,
+		publicKeys:    make(map[string][]jwt.VerificationKey),
+	}
+	if cfg.Disabled {
+		log.Warn("authentication is disabled, every route is open")
+		return a, nil
+	}
+
+	var err error
+	switch {
+	case cfg.HMACSecretFile != "" && cfg.HMACSecretEnv != "":
+		return nil, errors.New("auth: hmac_secret_file and hmac_secret_env are mutually exclusive")
+	case cfg.HMACSecretFile != "":
+		a.hmacSecret, err = os.ReadFile(cfg.HMACSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: failed to read hmac secret: %v", err)
+		}
+		a.hmacSecret = []byte(strings.TrimSpace(string(a.hmacSecret)))
+	case cfg.HMACSecretEnv != "":
+		a.hmacSecret = []byte(os.Getenv(cfg.HMACSecretEnv))
+		if len(a.hmacSecret) == 0 {
+			return nil, fmt.Errorf("auth: environment variable %s is not set", cfg.HMACSecretEnv)
+		}
+	}
+
+	for _, file := range cfg.PublicKeyFiles {
+		key, err := loadPublicKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %v", err)
+		}
+		a.publicKeys[""] = append(a.publicKeys[""], key)
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %v", err)
+		}
+		for kid, key := range keys {
+			a.publicKeys[kid] = append(a.publicKeys[kid], key)
+		}
+	}
+
+	a.uploadTokens, err = NewUploadTokens(cfg.UploadTokens, cfg.Leeway)
+	if err != nil {
+		return nil, fmt.Errorf("auth: %v", err)
+	}
+	// Upload tokens would otherwise pass for bearer tokens
+	if a.uploadTokens != nil && len(a.hmacSecret) > 0 && string(a.uploadTokens.secret) == string(a.hmacSecret) {
+		return nil, errors.New("auth: upload tokens need their own secret")
+	}
+
+	var methods []string
+	if len(a.hmacSecret) > 0 {
+		methods = append(methods, hmacMethods...)
+	}
+	if len(a.publicKeys) > 0 {
+		methods = append(methods, publicKeyMethods...)
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("auth: configure an hmac secret, public keys or a jwks file, or disable authentication")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+	return a, nil
+}
+
+func loadPublicKey(file string) (jwt.VerificationKey, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %v", err)
+	}
+
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(content); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(content); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%s is neither a RSA nor an ECDSA public key", file)
+}
+
+func (a *Authenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	if strings.HasPrefix(token.Method.Alg(), "HS") {
+		return a.hmacSecret, nil
+	}
+
+	// Tokens naming a known key are only checked against that key
+	kid, _ := token.Header["kid"].(string)
+	if keys, exists := a.publicKeys[kid]; exists && kid != "" {
+		return jwt.VerificationKeySet{Keys: keys}, nil
+	}
+
+	var keys []jwt.VerificationKey
+	for _, set := range a.publicKeys {
+		keys = append(keys, set...)
+	}
+	return jwt.VerificationKeySet{Keys: keys}, nil
+}
+
+// Authenticate verifies the bearer token of the request and returns its caller.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found || token == "" {
+		return nil, errors.New("missing bearer token")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(token, claims, a.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	tenant, _ := claims[a.tenantClaim].(string)
+	if tenant == "" && a.requireTenant {
+		return nil, errors.New("token has no tenant")
+	}
+
+	scopes := stringsClaim(claims, "scope", "scp")
+	for _, role := range stringsClaim(claims, "roles") {
+		scopes = append(scopes, a.roles[role]...)
+	}
+	return &Principal{Subject: subject, Tenant: tenant, Scopes: scopes}, nil
+}
+
+// stringsClaim reads claims holding either a space separated string, like the "scope"
+// claim of OAuth 2.0, or a list of strings.
+func stringsClaim(claims jwt.MapClaims, names ...string) []string {
+	var values []string
+	for _, name := range names {
+		switch value := claims[name].(type) {
+		case string:
+			values = append(values, strings.Fields(value)...)
+		case []interface{}:
+			for _, v := range value {
+				if s, ok := v.(string); ok {
+					values = append(values, s)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// Middleware rejects requests without a valid token with 401 and puts the caller of the
+// others in the request context. OPTIONS requests may stay anonymous so clients can
+// discover the tus server.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.disabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Browsers holding an upload token have no bearer token
+		if token := uploadToken(r); token != "" && r.Header.Get("Authorization") == "" {
+			principal, grant, err := a.authenticateUpload(r, token)
+			if err != nil {
+				log.Infof("rejected upload token for %s: %v", r.URL.Path, err)
+				http.Error(w, "", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := WithPrincipal(r.Context(), principal)
+			ctx = entity.WithSubject(ctx, principal.Subject)
+			ctx = entity.WithUploadGrant(ctx, grant)
+			next.ServeHTTP(w, r.WithContext(entity.WithTenant(ctx, principal.Tenant)))
+			return
+		}
+
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			log.Infof("rejected request to %s: %v", r.URL.Path, err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := WithPrincipal(r.Context(), principal)
+		ctx = entity.WithSubject(ctx, principal.Subject)
+		next.ServeHTTP(w, r.WithContext(entity.WithTenant(ctx, principal.Tenant)))
+	})
+}
@@ -0,0 +1,254 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testHMACSecret = "bearer-secret"
+
+// testKeys is a RSA key pair with its public key written to a PEM file.
+type testKeys struct {
+	private   *rsa.PrivateKey
+	publicPEM []byte
+	file      string
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	file := filepath.Join(t.TempDir(), "public.pem")
+	if err := os.WriteFile(file, publicPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &testKeys{private: private, publicPEM: publicPEM, file: file}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":    "alice",
+		"tenant": "acme",
+		"scope":  "uploads:write tasks:read",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func withClaims(change func(jwt.MapClaims)) jwt.MapClaims {
+	claims := validClaims()
+	change(claims)
+	return claims
+}
+
+func authRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Setenv("GORYNYCH_TEST_HMAC_SECRET", testHMACSecret)
+	keys := newTestKeys(t)
+
+	hmacOnly := AuthConfig{HMACSecretEnv: "GORYNYCH_TEST_HMAC_SECRET", TenantClaim: "tenant", Leeway: time.Second}
+	publicKeyOnly := AuthConfig{PublicKeyFiles: []string{keys.file}, TenantClaim: "tenant", Leeway: time.Second}
+	both := AuthConfig{HMACSecretEnv: "GORYNYCH_TEST_HMAC_SECRET", PublicKeyFiles: []string{keys.file}, TenantClaim: "tenant", Leeway: time.Second}
+	strict := AuthConfig{HMACSecretEnv: "GORYNYCH_TEST_HMAC_SECRET", TenantClaim: "tenant", RequireTenant: true, Issuer: "idp", Audience: "gorynych"}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     AuthConfig
+		token   string
+		wantErr bool
+	}{
+		{name: "HS256", cfg: hmacOnly, token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), validClaims())},
+		{name: "HS512", cfg: both, token: sign(t, jwt.SigningMethodHS512, []byte(testHMACSecret), validClaims())},
+		{name: "RS256", cfg: publicKeyOnly, token: sign(t, jwt.SigningMethodRS256, keys.private, validClaims())},
+		{name: "PS256", cfg: both, token: sign(t, jwt.SigningMethodPS256, keys.private, validClaims())},
+		{name: "missing token", cfg: hmacOnly, wantErr: true},
+		{name: "other HMAC secret", cfg: hmacOnly, token: sign(t, jwt.SigningMethodHS256, []byte("other"), validClaims()), wantErr: true},
+		{name: "other RSA key", cfg: publicKeyOnly, token: sign(t, jwt.SigningMethodRS256, newTestKeys(t).private, validClaims()), wantErr: true},
+		{name: "alg none", cfg: hmacOnly, token: unsigned, wantErr: true},
+		// A token switching from RS to HS must not be verified with the public key as secret
+		{name: "HS256 signed with the public key", cfg: publicKeyOnly, token: sign(t, jwt.SigningMethodHS256, keys.publicPEM, validClaims()), wantErr: true},
+		{name: "HS256 signed with the public key next to a secret", cfg: both, token: sign(t, jwt.SigningMethodHS256, keys.publicPEM, validClaims()), wantErr: true},
+		{name: "RS256 without public keys", cfg: hmacOnly, token: sign(t, jwt.SigningMethodRS256, keys.private, validClaims()), wantErr: true},
+		{name: "missing exp", cfg: hmacOnly, token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), withClaims(func(c jwt.MapClaims) { delete(c, "exp") })), wantErr: true},
+		{name: "expired", cfg: hmacOnly, token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), withClaims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })), wantErr: true},
+		{name: "expired within the leeway", cfg: hmacOnly, token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), withClaims(func(c jwt.MapClaims) { c["exp"] = time.Now().Unix() }))},
+		{name: "not valid yet", cfg: hmacOnly, token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), withClaims(func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Minute).Unix() })), wantErr: true},
+		{name: "missing subject", cfg: hmacOnly, token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), withClaims(func(c jwt.MapClaims) { delete(c, "sub") })), wantErr: true},
+		{name: "issuer and audience", cfg: strict, token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), withClaims(func(c jwt.MapClaims) { c["iss"], c["aud"] = "idp", "gorynych" }))},
+		{name: "other issuer", cfg: strict, token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), withClaims(func(c jwt.MapClaims) { c["iss"], c["aud"] = "other", "gorynych" })), wantErr: true},
+		{name: "other audience", cfg: strict, token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), withClaims(func(c jwt.MapClaims) { c["iss"], c["aud"] = "idp", "other" })), wantErr: true},
+		{name: "missing required tenant", cfg: strict, token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), withClaims(func(c jwt.MapClaims) { c["iss"], c["aud"] = "idp", "gorynych"; delete(c, "tenant") })), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAuthenticator(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			principal, err := a.Authenticate(authRequest(tt.token))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if principal.Subject != "alice" || principal.Tenant != "acme" {
+				t.Fatalf("Authenticate() = %+v, want alice of acme", principal)
+			}
+			if !principal.HasScope("uploads:write") || !principal.HasScope("tasks:read") {
+				t.Fatalf("Authenticate() scopes = %v", principal.Scopes)
+			}
+		})
+	}
+}
+
+// writeJWKS writes the public keys to a JSON Web Key Set under the given key ids.
+func writeJWKS(t *testing.T, keys map[string]*testKeys) string {
+	t.Helper()
+	encode := func(n *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(n.Bytes())
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, k := range keys {
+		public := k.private.PublicKey
+		set.Keys = append(set.Keys, jwk{Kty: "RSA", Kid: kid, Use: "sig", N: encode(public.N), E: encode(big.NewInt(int64(public.E)))})
+	}
+	content, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestAuthenticateKeyID(t *testing.T) {
+	first, second, unknown := newTestKeys(t), newTestKeys(t), newTestKeys(t)
+	a, err := NewAuthenticator(AuthConfig{JWKSFile: writeJWKS(t, map[string]*testKeys{"first": first, "second": second})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signWithKid := func(k *testKeys, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(k.private)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "matching key id", token: signWithKid(first, "first")},
+		{name: "other key id", token: signWithKid(second, "second")},
+		{name: "no key id", token: signWithKid(second, "")},
+		// Tokens naming a known key are not checked against the other keys
+		{name: "key id of another key", token: signWithKid(second, "first"), wantErr: true},
+		{name: "unknown key", token: signWithKid(unknown, "first"), wantErr: true},
+		{name: "unknown key without key id", token: signWithKid(unknown, ""), wantErr: true},
+		{name: "HS256 with a key id", token: func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+			token.Header["kid"] = "first"
+			signed, err := token.SignedString([]byte(""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signed
+		}(), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Authenticate(authRequest(tt.token))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	t.Setenv("GORYNYCH_TEST_HMAC_SECRET", testHMACSecret)
+	t.Setenv("GORYNYCH_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		cfg     AuthConfig
+		wantErr bool
+	}{
+		{name: "disabled", cfg: AuthConfig{Disabled: true}},
+		{name: "no key", cfg: AuthConfig{}, wantErr: true},
+		{name: "empty secret", cfg: AuthConfig{HMACSecretEnv: "GORYNYCH_TEST_EMPTY"}, wantErr: true},
+		{name: "secret file and env", cfg: AuthConfig{HMACSecretFile: "secret", HMACSecretEnv: "GORYNYCH_TEST_HMAC_SECRET"}, wantErr: true},
+		{name: "missing public key", cfg: AuthConfig{PublicKeyFiles: []string{filepath.Join(t.TempDir(), "missing.pem")}}, wantErr: true},
+		{
+			name: "upload tokens sharing the bearer secret",
+			cfg: AuthConfig{
+				HMACSecretEnv: "GORYNYCH_TEST_HMAC_SECRET",
+				UploadTokens:  UploadTokenConfig{SecretEnv: "GORYNYCH_TEST_HMAC_SECRET", DefaultTTL: time.Minute, MaxTTL: time.Hour},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAuthenticator(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAuthenticator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int64
+		body    string
+		wantErr bool
+	}{
+		{name: "body under the limit", limit: 10, body: "hello"},
+		{name: "body at the limit", limit: 5, body: "hello"},
+		{name: "body over the limit", limit: 4, body: "hello", wantErr: true},
+		{name: "no limit", body: strings.Repeat("a", 1<<20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			handler := MaxBodySize(tt.limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, err = io.ReadAll(r.Body)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/files/1", strings.NewReader(tt.body)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reading the body error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
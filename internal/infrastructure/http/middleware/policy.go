@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Scopes of the default policy
+const (
+	ScopeFilesRead     = "files:read"
+	ScopeFilesWrite    = "files:write"
+	ScopeTasksRead     = "tasks:read"
+	ScopeTasksWrite    = "tasks:write"
+	ScopeAccountsAdmin = "accounts:admin"
+	ScopeAuditRead     = "audit:read"
+)
+
+// PolicyRule gives the scope required by the routes below a path. The rule with the
+// longest matching path wins; routes without a rule are denied.
+type PolicyRule struct {
+	// Methods the rule applies to. No methods means every method.
+	Methods []string `yaml:"methods,omitempty"`
+	// Path is a route template prefix, like /api/tasks or /files/{object_id}.
+	Path string `yaml:"path"`
+	// Scope required by the routes. Empty means any authenticated caller.
+	Scope string `yaml:"scope"`
+	// Operator limits the routes to callers without a tenant, for settings shared by
+	// every tenant like provider endpoints and global bandwidth limits.
+	Operator bool `yaml:"operator,omitempty"`
+}
+
+var (
+	DefaultPolicy = []PolicyRule{
+		{Methods: []string{"OPTIONS"}, Path: "/files", Scope: ""},
+		{Methods: []string{"GET", "HEAD"}, Path: "/files", Scope: ScopeFilesRead},
+		{Methods: []string{"POST", "PATCH"}, Path: "/files", Scope: ScopeFilesWrite},
+		{Path: "/api/accounts", Scope: ScopeAccountsAdmin},
+		{Methods: []string{"GET"}, Path: "/api/providers", Scope: ScopeAccountsAdmin},
+		{Path: "/api/providers", Scope: ScopeAccountsAdmin, Operator: true},
+		{Methods: []string{"GET"}, Path: "/api/tasks", Scope: ScopeTasksRead},
+		{Path: "/api/tasks", Scope: ScopeTasksWrite},
+		{Methods: []string{"GET"}, Path: "/api/schedules", Scope: ScopeTasksRead},
+		{Path: "/api/schedules", Scope: ScopeTasksWrite},
+		{Methods: []string{"GET"}, Path: "/api/policies", Scope: ScopeTasksRead},
+		{Path: "/api/policies", Scope: ScopeTasksWrite},
+		{Methods: []string{"GET"}, Path: "/api/bandwidth", Scope: ScopeTasksRead},
+		{Path: "/api/bandwidth", Scope: ScopeTasksWrite, Operator: true},
+		{Methods: []string{"GET"}, Path: "/api/audit", Scope: ScopeAuditRead},
+		{Methods: []string{"POST"}, Path: "/api/upload-tokens", Scope: ScopeFilesWrite},
+	}
+)
+
+func (r *PolicyRule) matches(method, template string) bool {
+	if template != r.Path && !strings.HasPrefix(template, strings.TrimSuffix(r.Path, "/")+"/") {
+		return false
+	}
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// rule returns the policy rule of the route, or nil if there is none.
+func (a *Authenticator) rule(method, template string) *PolicyRule {
+	var match *PolicyRule
+	for i, rule := range a.policy {
+		if rule.matches(method, template) && (match == nil || len(rule.Path) > len(match.Path)) {
+			match = &a.policy[i]
+		}
+	}
+	return match
+}
+
+// Authorize answers 403 to callers without the scope the policy requires for the route
+// with the given template.
+func (a *Authenticator) Authorize(template string, methods []string, next http.Handler) http.Handler {
+	for _, method := range methods {
+		if a.rule(method, template) == nil && !a.disabled {
+			log.Warnf("no policy rule for %s %s, the route is denied", method, template)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.disabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rule := a.rule(r.Method, template)
+		if rule == nil {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		principal := PrincipalFromContext(r.Context())
+		if rule.Scope != "" && (principal == nil || !principal.HasScope(rule.Scope)) {
+			http.Error(w, "missing scope "+rule.Scope, http.StatusForbidden)
+			return
+		}
+		if rule.Operator && (principal == nil || principal.Tenant != "") {
+			http.Error(w, "only operators without a tenant may change this", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
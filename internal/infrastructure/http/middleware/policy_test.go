@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAuthorize(t *testing.T) {
+	writer := &Principal{Subject: "alice", Tenant: "acme", Scopes: []string{ScopeFilesWrite, ScopeTasksRead}}
+	admin := &Principal{Subject: "bob", Tenant: "acme", Scopes: []string{ScopeAccountsAdmin, ScopeTasksWrite}}
+	operator := &Principal{Subject: "ops", Scopes: []string{ScopeAccountsAdmin, ScopeTasksWrite}}
+
+	tests := []struct {
+		name      string
+		disabled  bool
+		policy    []PolicyRule
+		method    string
+		template  string
+		principal *Principal
+		want      int
+	}{
+		{name: "scope granted", method: http.MethodPost, template: "/files", principal: writer, want: http.StatusOK},
+		{name: "rule of a parent path", method: http.MethodPatch, template: "/files/{object_id}", principal: writer, want: http.StatusOK},
+		{name: "scope missing", method: http.MethodGet, template: "/files/{object_id}", principal: writer, want: http.StatusForbidden},
+		{name: "anonymous", method: http.MethodPost, template: "/files", want: http.StatusForbidden},
+		{name: "anonymous OPTIONS", method: http.MethodOptions, template: "/files", want: http.StatusOK},
+		{name: "route without a rule", method: http.MethodGet, template: "/api/unknown", principal: admin, want: http.StatusForbidden},
+		{name: "method without a rule", method: http.MethodDelete, template: "/api/audit", principal: admin, want: http.StatusForbidden},
+		{name: "path sharing a prefix only", method: http.MethodGet, template: "/filesystem", principal: writer, want: http.StatusForbidden},
+		{name: "read rule of a write route", method: http.MethodGet, template: "/api/tasks/{task_id}", principal: writer, want: http.StatusOK},
+		{name: "write rule of a write route", method: http.MethodPost, template: "/api/tasks", principal: writer, want: http.StatusForbidden},
+		{name: "operator route read by a tenant", method: http.MethodGet, template: "/api/providers", principal: admin, want: http.StatusOK},
+		{name: "operator route changed by a tenant", method: http.MethodPost, template: "/api/providers", principal: admin, want: http.StatusForbidden},
+		{name: "operator route changed by an operator", method: http.MethodPost, template: "/api/providers", principal: operator, want: http.StatusOK},
+		{
+			name:      "longest path wins",
+			policy:    []PolicyRule{{Path: "/api", Scope: ScopeAuditRead}, {Path: "/api/tasks", Scope: ScopeTasksRead}},
+			method:    http.MethodDelete,
+			template:  "/api/tasks/{task_id}",
+			principal: writer,
+			want:      http.StatusOK,
+		},
+		{name: "disabled", disabled: true, method: http.MethodGet, template: "/api/unknown", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := tt.policy
+			if policy == nil {
+				policy = DefaultPolicy
+			}
+			a := &Authenticator{disabled: tt.disabled, policy: policy}
+			handler := a.Authorize(tt.template, []string{tt.method}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r := httptest.NewRequest(tt.method, "/", nil)
+			if tt.principal != nil {
+				r = r.WithContext(WithPrincipal(r.Context(), tt.principal))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("%s %s answered %d, want %d", tt.method, tt.template, w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRoles(t *testing.T) {
+	t.Setenv("GORYNYCH_TEST_HMAC_SECRET", testHMACSecret)
+	a, err := NewAuthenticator(AuthConfig{
+		HMACSecretEnv: "GORYNYCH_TEST_HMAC_SECRET",
+		TenantClaim:   "tenant",
+		Leeway:        time.Second,
+		Roles: map[string][]string{
+			"uploader": {ScopeFilesWrite},
+			"admin":    {ScopeAccountsAdmin, ScopeTasksWrite},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		roles interface{}
+		want  []string
+	}{
+		{name: "no roles", want: []string{"uploads:write", "tasks:read"}},
+		{name: "role list", roles: []string{"uploader"}, want: []string{"uploads:write", "tasks:read", ScopeFilesWrite}},
+		{name: "space separated roles", roles: "uploader admin", want: []string{"uploads:write", "tasks:read", ScopeFilesWrite, ScopeAccountsAdmin, ScopeTasksWrite}},
+		{name: "unknown role", roles: []string{"root"}, want: []string{"uploads:write", "tasks:read"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := withClaims(func(c jwt.MapClaims) {
+				if tt.roles != nil {
+					c["roles"] = tt.roles
+				}
+			})
+			principal, err := a.Authenticate(authRequest(sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), claims)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(principal.Scopes, tt.want) {
+				t.Fatalf("Authenticate() scopes = %v, want %v", principal.Scopes, tt.want)
+			}
+		})
+	}
+}
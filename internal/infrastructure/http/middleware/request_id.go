@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	// Longer request ids sent by clients are replaced
+	maxRequestIDLength = 128
+)
+
+// RequestID tags every request with the X-Request-ID header of the client, or a new one,
+// and puts it in the request context together with the address of the client.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		sourceIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			sourceIP = r.RemoteAddr
+		}
+		ctx := entity.WithRequestInfo(r.Context(), entity.RequestInfo{ID: id, SourceIP: sourceIP})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       string
+		remoteAddr   string
+		wantID       string
+		wantSourceIP string
+	}{
+		{name: "id of the client", header: "req-1", remoteAddr: "10.0.0.1:4321", wantID: "req-1", wantSourceIP: "10.0.0.1"},
+		{name: "new id", remoteAddr: "[2001:db8::1]:4321", wantSourceIP: "2001:db8::1"},
+		{name: "too long id", header: strings.Repeat("a", maxRequestIDLength+1), remoteAddr: "10.0.0.1:4321", wantSourceIP: "10.0.0.1"},
+		{name: "address without port", header: "req-1", remoteAddr: "10.0.0.1", wantID: "req-1", wantSourceIP: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var info entity.RequestInfo
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				info = entity.RequestInfoFromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set(requestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if tt.wantID != "" && info.ID != tt.wantID {
+				t.Fatalf("request id = %q, want %q", info.ID, tt.wantID)
+			}
+			// Generated ids are 16 random bytes in hex
+			if tt.wantID == "" && (len(info.ID) != 32 || info.ID == tt.header) {
+				t.Fatalf("request id = %q, want a new one", info.ID)
+			}
+			if got := w.Header().Get(requestIDHeader); got != info.ID {
+				t.Fatalf("response %s = %q, want %q", requestIDHeader, got, info.ID)
+			}
+			if info.SourceIP != tt.wantSourceIP {
+				t.Fatalf("source ip = %q, want %q", info.SourceIP, tt.wantSourceIP)
+			}
+		})
+	}
+}
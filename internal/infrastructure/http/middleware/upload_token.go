@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// Upload tokens are given in a header or in the query of the upload URL, so browser tus
+// clients can use the Location of the upload as is.
+const (
+	UploadTokenHeader = "Gorynych-Upload-Token"
+	UploadTokenParam  = "upload_token"
+)
+
+// uploadTokenAudience tells upload tokens apart from the bearer tokens of the API.
+const uploadTokenAudience = "gorynych-upload"
+
+// UploadTokenConfig describes the HMAC signed tokens minted for browser uploads. They are
+// off unless a secret is set.
+type UploadTokenConfig struct {
+	// SecretFile holds the secret signing the tokens. It must differ from the secret
+	// of bearer tokens.
+	SecretFile string `yaml:"secret_file,omitempty"`
+	// SecretEnv names the environment variable holding the secret.
+	SecretEnv string `yaml:"secret_env,omitempty"`
+	// DefaultTTL is the lifetime of tokens minted without one.
+	DefaultTTL time.Duration `yaml:"default_ttl,omitempty"`
+	// MaxTTL is the longest lifetime a token may be minted with.
+	MaxTTL time.Duration `yaml:"max_ttl,omitempty"`
+}
+
+var (
+	DefaultUploadTokenConfig = UploadTokenConfig{
+		DefaultTTL: 15 * time.Minute,
+		MaxTTL:     24 * time.Hour,
+	}
+)
+
+// uploadTokenRoutes are the tus routes accepting upload tokens: creating an upload,
+// resuming it and writing its chunks.
+var uploadTokenRoutes = map[string][]string{
+	"/files":             {http.MethodPost},
+	"/files/{object_id}": {http.MethodHead, http.MethodPatch},
+}
+
+var ErrInvalidUploadToken = errors.New("upload token needs a positive size and a lifetime within the maximum")
+
+type uploadClaims struct {
+	jwt.RegisteredClaims
+	Tenant   string            `json:"tenant,omitempty"`
+	Size     int64             `json:"size"`
+	Storage  *uploadStorage    `json:"storage,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type uploadStorage struct {
+	ProviderID string `json:"provider_id"`
+	Bucket     string `json:"bucket"`
+}
+
+// UploadTokens mints and verifies upload tokens.
+type UploadTokens struct {
+	secret     []byte
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+	parser     *jwt.Parser
+}
+
+func NewUploadTokens(cfg UploadTokenConfig, leeway time.Duration) (*UploadTokens, error) {
+	var secret []byte
+	switch {
+	case cfg.SecretFile != "" && cfg.SecretEnv != "":
+		return nil, errors.New("upload tokens: secret_file and secret_env are mutually exclusive")
+	case cfg.SecretFile != "":
+		content, err := os.ReadFile(cfg.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("upload tokens: failed to read secret: %v", err)
+		}
+		secret = []byte(strings.TrimSpace(string(content)))
+	case cfg.SecretEnv != "":
+		secret = []byte(os.Getenv(cfg.SecretEnv))
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("upload tokens: environment variable %s is not set", cfg.SecretEnv)
+		}
+	default:
+		return nil, nil
+	}
+
+	if cfg.DefaultTTL <= 0 || cfg.MaxTTL < cfg.DefaultTTL {
+		return nil, errors.New("upload tokens: default_ttl must be positive and at most max_ttl")
+	}
+	return &UploadTokens{
+		secret:     secret,
+		defaultTTL: cfg.DefaultTTL,
+		maxTTL:     cfg.MaxTTL,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{"HS256"}),
+			jwt.WithExpirationRequired(),
+			jwt.WithAudience(uploadTokenAudience),
+			jwt.WithLeeway(leeway),
+		),
+	}, nil
+}
+
+// Mint signs a token letting its holder create a single upload of at most size bytes on
+// behalf of the principal. A zero ttl means the default lifetime.
+func (t *UploadTokens) Mint(principal *Principal, size int64, storage *entity.Storage, metadata map[string]string, ttl time.Duration) (string, *entity.UploadGrant, error) {
+	if ttl == 0 {
+		ttl = t.defaultTTL
+	}
+	if size <= 0 || ttl < 0 || ttl > t.maxTTL {
+		return "", nil, ErrInvalidUploadToken
+	}
+
+	now := time.Now()
+	grant := &entity.UploadGrant{
+		ID:        entity.NewObjectID(),
+		MaxSize:   size,
+		Storage:   storage,
+		Metadata:  metadata,
+		ExpiresAt: now.Add(ttl).Truncate(time.Second),
+	}
+	claims := uploadClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        grant.ID,
+			Subject:   principal.Subject,
+			Audience:  jwt.ClaimStrings{uploadTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(grant.ExpiresAt),
+		},
+		Tenant:   principal.Tenant,
+		Size:     size,
+		Metadata: metadata,
+	}
+	if storage != nil {
+		claims.Storage = &uploadStorage{ProviderID: storage.ProviderID, Bucket: storage.Bucket}
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, grant, nil
+}
+
+// Verify checks an upload token and returns the caller which minted it and its grant.
+func (t *UploadTokens) Verify(token string) (*Principal, *entity.UploadGrant, error) {
+	claims := &uploadClaims{}
+	_, err := t.parser.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return t.secret, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.ID == "" || claims.Subject == "" || claims.Size <= 0 {
+		return nil, nil, errors.New("upload token misses its id, subject or size")
+	}
+
+	grant := &entity.UploadGrant{
+		ID:        claims.ID,
+		MaxSize:   claims.Size,
+		Metadata:  claims.Metadata,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if claims.Storage != nil {
+		grant.Storage = &entity.Storage{ProviderID: claims.Storage.ProviderID, Bucket: claims.Storage.Bucket}
+	}
+	return &Principal{Subject: claims.Subject, Tenant: claims.Tenant}, grant, nil
+}
+
+// uploadToken returns the upload token of the request, if any.
+func uploadToken(r *http.Request) string {
+	if token := r.Header.Get(UploadTokenHeader); token != "" {
+		return token
+	}
+	return r.URL.Query().Get(UploadTokenParam)
+}
+
+// authenticateUpload verifies the upload token of a request to a tus route. The caller
+// gets the scope the policy requires for the route, and nothing more.
+func (a *Authenticator) authenticateUpload(r *http.Request, token string) (*Principal, *entity.UploadGrant, error) {
+	if a.uploadTokens == nil {
+		return nil, nil, errors.New("upload tokens are not enabled")
+	}
+
+	var template string
+	if route := mux.CurrentRoute(r); route != nil {
+		template, _ = route.GetPathTemplate()
+	}
+	accepted := false
+	for _, method := range uploadTokenRoutes[template] {
+		accepted = accepted || method == r.Method
+	}
+	if !accepted {
+		return nil, nil, fmt.Errorf("upload tokens are not accepted by %s %s", r.Method, template)
+	}
+
+	principal, grant, err := a.uploadTokens.Verify(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rule := a.rule(r.Method, template); rule != nil && rule.Scope != "" {
+		principal.Scopes = []string{rule.Scope}
+	}
+	return principal, grant, nil
+}
+
+// UploadTokens returns the minter of upload tokens, or nil if they are not enabled.
+func (a *Authenticator) UploadTokens() *UploadTokens {
+	return a.uploadTokens
+}
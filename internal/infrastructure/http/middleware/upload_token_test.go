@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+const testUploadSecret = "upload-secret"
+
+func newUploadAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	t.Setenv("GORYNYCH_TEST_HMAC_SECRET", testHMACSecret)
+	t.Setenv("GORYNYCH_TEST_UPLOAD_SECRET", testUploadSecret)
+	a, err := NewAuthenticator(AuthConfig{
+		HMACSecretEnv: "GORYNYCH_TEST_HMAC_SECRET",
+		Leeway:        time.Second,
+		Policy:        DefaultPolicy,
+		TenantClaim:   "tenant",
+		UploadTokens: UploadTokenConfig{
+			SecretEnv:  "GORYNYCH_TEST_UPLOAD_SECRET",
+			DefaultTTL: time.Minute,
+			MaxTTL:     time.Hour,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// newUploadRouter routes the requests through the middleware as the API does, so it
+// sees the matched route. Handlers answer 204 with the caller in the context.
+func newUploadRouter(a *Authenticator) *mux.Router {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if PrincipalFromContext(r.Context()) == nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	r := mux.NewRouter()
+	r.Use(a.Middleware)
+	r.Handle("/files", handler).Methods(http.MethodPost)
+	r.Handle("/files/{object_id}", handler).Methods(http.MethodHead, http.MethodPatch, http.MethodGet, http.MethodDelete)
+	r.Handle("/api/tasks", handler).Methods(http.MethodGet, http.MethodPost)
+	r.Handle("/api/upload-tokens", handler).Methods(http.MethodPost)
+	return r
+}
+
+func TestUploadTokenRoutes(t *testing.T) {
+	a := newUploadAuthenticator(t)
+	router := newUploadRouter(a)
+
+	token, _, err := a.UploadTokens().Mint(&Principal{Subject: "alice", Tenant: "acme"}, 100, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		query  bool
+		want   int
+	}{
+		{name: "create upload", method: http.MethodPost, path: "/files", want: http.StatusNoContent},
+		{name: "resume upload", method: http.MethodHead, path: "/files/abc", want: http.StatusNoContent},
+		{name: "write chunk", method: http.MethodPatch, path: "/files/abc", want: http.StatusNoContent},
+		{name: "write chunk with the token in the query", method: http.MethodPatch, path: "/files/abc", query: true, want: http.StatusNoContent},
+		{name: "download", method: http.MethodGet, path: "/files/abc", want: http.StatusUnauthorized},
+		{name: "delete", method: http.MethodDelete, path: "/files/abc", want: http.StatusUnauthorized},
+		{name: "list tasks", method: http.MethodGet, path: "/api/tasks", want: http.StatusUnauthorized},
+		{name: "start task", method: http.MethodPost, path: "/api/tasks", want: http.StatusUnauthorized},
+		{name: "mint upload token", method: http.MethodPost, path: "/api/upload-tokens", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.query {
+				r.URL.RawQuery = UploadTokenParam + "=" + token
+			} else {
+				r.Header.Set(UploadTokenHeader, token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("%s %s = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadTokenPrincipal(t *testing.T) {
+	a := newUploadAuthenticator(t)
+	storage := &entity.Storage{ProviderID: "p1", Bucket: "b1"}
+	token, minted, err := a.UploadTokens().Mint(&Principal{Subject: "alice", Tenant: "acme", Scopes: []string{ScopeTasksWrite}}, 100, storage, map[string]string{"owner": "backend"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var principal *Principal
+	var grant *entity.UploadGrant
+	var tenant string
+	r := mux.NewRouter()
+	r.Use(a.Middleware)
+	r.HandleFunc("/files", func(w http.ResponseWriter, r *http.Request) {
+		principal = PrincipalFromContext(r.Context())
+		grant = entity.UploadGrantFromContext(r.Context())
+		tenant, _ = entity.TenantFromContext(r.Context())
+	}).Methods(http.MethodPost)
+
+	req := httptest.NewRequest(http.MethodPost, "/files", nil)
+	req.Header.Set(UploadTokenHeader, token)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if principal == nil || grant == nil {
+		t.Fatal("upload token was not accepted")
+	}
+	if principal.Subject != "alice" || tenant != "acme" {
+		t.Fatalf("caller = %s of %s, want alice of acme", principal.Subject, tenant)
+	}
+	// The caller only gets the scope of the route, not the scopes of the minter
+	if len(principal.Scopes) != 1 || principal.Scopes[0] != ScopeFilesWrite {
+		t.Fatalf("scopes = %v, want [%s]", principal.Scopes, ScopeFilesWrite)
+	}
+	if grant.ID != minted.ID || grant.MaxSize != 100 || grant.Storage == nil || *grant.Storage != *storage || grant.Metadata["owner"] != "backend" {
+		t.Fatalf("grant = %+v, want %+v", grant, minted)
+	}
+}
+
+func TestVerifyUploadToken(t *testing.T) {
+	a := newUploadAuthenticator(t)
+	tokens := a.UploadTokens()
+
+	valid, _, err := tokens.Mint(&Principal{Subject: "alice"}, 100, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"jti":  "g1",
+			"sub":  "alice",
+			"aud":  uploadTokenAudience,
+			"size": 100,
+			"exp":  time.Now().Add(time.Minute).Unix(),
+		}
+		change(c)
+		return c
+	}
+	keep := func(jwt.MapClaims) {}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "minted", token: valid},
+		{name: "signed with the upload secret", token: sign(t, jwt.SigningMethodHS256, []byte(testUploadSecret), claims(keep))},
+		{name: "bearer token", token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), validClaims()), wantErr: true},
+		{name: "signed with the bearer secret", token: sign(t, jwt.SigningMethodHS256, []byte(testHMACSecret), claims(keep)), wantErr: true},
+		{name: "HS512", token: sign(t, jwt.SigningMethodHS512, []byte(testUploadSecret), claims(keep)), wantErr: true},
+		{name: "other audience", token: sign(t, jwt.SigningMethodHS256, []byte(testUploadSecret), claims(func(c jwt.MapClaims) { c["aud"] = "gorynych" })), wantErr: true},
+		{name: "missing exp", token: sign(t, jwt.SigningMethodHS256, []byte(testUploadSecret), claims(func(c jwt.MapClaims) { delete(c, "exp") })), wantErr: true},
+		{name: "expired", token: sign(t, jwt.SigningMethodHS256, []byte(testUploadSecret), claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })), wantErr: true},
+		{name: "missing id", token: sign(t, jwt.SigningMethodHS256, []byte(testUploadSecret), claims(func(c jwt.MapClaims) { delete(c, "jti") })), wantErr: true},
+		{name: "missing subject", token: sign(t, jwt.SigningMethodHS256, []byte(testUploadSecret), claims(func(c jwt.MapClaims) { delete(c, "sub") })), wantErr: true},
+		{name: "missing size", token: sign(t, jwt.SigningMethodHS256, []byte(testUploadSecret), claims(func(c jwt.MapClaims) { delete(c, "size") })), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := tokens.Verify(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMintUploadToken(t *testing.T) {
+	tokens := newUploadAuthenticator(t).UploadTokens()
+
+	tests := []struct {
+		name    string
+		size    int64
+		ttl     time.Duration
+		wantErr bool
+	}{
+		{name: "default lifetime", size: 100},
+		{name: "maximum lifetime", size: 100, ttl: time.Hour},
+		{name: "no size", ttl: time.Minute, wantErr: true},
+		{name: "negative lifetime", size: 100, ttl: -time.Minute, wantErr: true},
+		{name: "beyond the maximum lifetime", size: 100, ttl: 2 * time.Hour, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, grant, err := tokens.Mint(&Principal{Subject: "alice"}, tt.size, nil, nil, tt.ttl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Mint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && grant.ID == "" {
+				t.Fatal("Mint() returned a grant without id")
+			}
+		})
+	}
+}
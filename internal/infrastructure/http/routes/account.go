@@ -2,6 +2,7 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -25,9 +26,10 @@ func AddAccount(s *service.AccountService) http.Handler {
 			return
 		}
 
-		id, err := s.AddAccount(ctx, cAccount.Provider, cAccount.Region, cAccount.AccessKey, cAccount.Secret)
+		id, err := s.AddAccount(ctx, cAccount.Provider, cAccount.Region, cAccount.AccessKey, cAccount.Secret, cAccount.Buckets)
 		if err != nil {
-			http.Error(w, "", http.StatusInternalServerError)
+			writeAccountError(w, err)
+			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -35,8 +37,141 @@ func AddAccount(s *service.AccountService) http.Handler {
 	})
 }
 
+func ListAccounts(s *service.AccountService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		accounts, err := s.ListAccounts(ctx)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewAccounts(accounts))
+	})
+}
+
+func GetAccount(s *service.AccountService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		accountID := mux.Vars(r)["account_id"]
+
+		account, err := s.GetAccountByID(ctx, accountID)
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewAccount(account))
+	})
+}
+
+func UpdateAccount(s *service.AccountService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorMessage := "Error updating account"
+		ctx := r.Context()
+		accountID := mux.Vars(r)["account_id"]
+
+		cUpdate := new(controllers.AccountUpdate)
+		if err := json.NewDecoder(r.Body).Decode(&cUpdate); err != nil {
+			log.Errorf("failed to decode payload")
+			http.Error(w, errorMessage, http.StatusBadRequest)
+			return
+		}
+
+		err := s.UpdateAccount(ctx, accountID, service.AccountUpdate(*cUpdate))
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func SetAccountDisabled(s *service.AccountService, disabled bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		accountID := mux.Vars(r)["account_id"]
+
+		err := s.SetAccountDisabled(ctx, accountID, disabled)
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func VerifyAccount(s *service.AccountService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		accountID := mux.Vars(r)["account_id"]
+
+		account, err := s.VerifyAccount(ctx, accountID)
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewAccount(account))
+	})
+}
+
+func DeleteAccount(s *service.AccountService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		accountID := mux.Vars(r)["account_id"]
+
+		err := s.DeleteAccount(ctx, accountID)
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func writeAccountError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrAccountNotFound) {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, service.ErrInvalidAccount) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if errors.Is(err, service.ErrAccountInUse) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if errors.Is(err, service.ErrProviderNotFound) || errors.Is(err, service.ErrInvalidCredentials) ||
+		errors.Is(err, service.ErrBucketNotFound) || errors.Is(err, service.ErrNoAccessibleBuckets) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if errors.Is(err, service.ErrProviderUnavailable) {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	http.Error(w, "", http.StatusInternalServerError)
+}
+
 func makeAccountRoutes(r *mux.Router, app *application.Application) {
 	path := "/accounts"
 	serviceRouter := r.PathPrefix(path).Subrouter()
 	serviceRouter.Handle("", AddAccount(app.AccountService)).Methods("POST")
+	serviceRouter.Handle("", ListAccounts(app.AccountService)).Methods("GET")
+	serviceRouter.Handle("/{account_id}", GetAccount(app.AccountService)).Methods("GET")
+	serviceRouter.Handle("/{account_id}", UpdateAccount(app.AccountService)).Methods("PUT")
+	serviceRouter.Handle("/{account_id}", DeleteAccount(app.AccountService)).Methods("DELETE")
+	serviceRouter.Handle("/{account_id}/verify", VerifyAccount(app.AccountService)).Methods("POST")
+	serviceRouter.Handle("/{account_id}/disable", SetAccountDisabled(app.AccountService, true)).Methods("POST")
+	serviceRouter.Handle("/{account_id}/enable", SetAccountDisabled(app.AccountService, false)).Methods("POST")
 }
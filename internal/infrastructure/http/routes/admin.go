@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/handlers"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/middleware"
+	"github.com/inview-team/gorynych/internal/infrastructure/mongo"
+	log "github.com/sirupsen/logrus"
+)
+
+// Live answers as long as the server runs.
+func Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// Ready answers once the database is reachable.
+func Ready(client *mongo.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*5)
+		defer cancel()
+
+		if err := client.Ping(ctx); err != nil {
+			log.Warnf("readiness check failed: %v", err)
+			http.Error(w, "database is unreachable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// MakeAdmin returns the internal endpoints served by the admin listener: health checks
+// and, if asked, profiling. They need no token, so the admin listener must not be public.
+func MakeAdmin(client *mongo.Client, profiling bool) http.Handler {
+	r := mux.NewRouter()
+	r.MethodNotAllowedHandler = handlers.NotAllowedHandler()
+	r.NotFoundHandler = handlers.NotFoundHandler()
+
+	r.Handle("/healthz", Live()).Methods("GET")
+	r.Handle("/readyz", Ready(client)).Methods("GET")
+	if !profiling {
+		return middleware.NewLogger(r)
+	}
+
+	debugRouter := r.PathPrefix("/debug/pprof").Subrouter()
+	debugRouter.HandleFunc("/cmdline", pprof.Cmdline)
+	debugRouter.HandleFunc("/profile", pprof.Profile)
+	debugRouter.HandleFunc("/symbol", pprof.Symbol)
+	debugRouter.HandleFunc("/trace", pprof.Trace)
+	debugRouter.PathPrefix("/").HandlerFunc(pprof.Index)
+	return middleware.NewLogger(r)
+}
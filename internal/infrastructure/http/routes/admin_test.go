@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMakeAdmin(t *testing.T) {
+	tests := []struct {
+		name      string
+		profiling bool
+		method    string
+		path      string
+		want      int
+	}{
+		{name: "liveness", method: http.MethodGet, path: "/healthz", want: http.StatusOK},
+		{name: "liveness with another method", method: http.MethodPost, path: "/healthz", want: http.StatusMethodNotAllowed},
+		{name: "profiles served", profiling: true, method: http.MethodGet, path: "/debug/pprof/", want: http.StatusOK},
+		{name: "command line served", profiling: true, method: http.MethodGet, path: "/debug/pprof/cmdline", want: http.StatusOK},
+		{name: "profiles off", method: http.MethodGet, path: "/debug/pprof/", want: http.StatusNotFound},
+		{name: "command line off", method: http.MethodGet, path: "/debug/pprof/cmdline", want: http.StatusNotFound},
+		{name: "unknown path", method: http.MethodGet, path: "/metrics", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Readiness is left out, it needs a database
+			handler := MakeAdmin(nil, tt.profiling)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.want {
+				t.Fatalf("%s %s answered %d, want %d", tt.method, tt.path, w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/inview-team/gorynych/internal/application"
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"github.com/inview-team/gorynych/internal/domain/service"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/views"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+func ListAuditEvents(s *service.AuditService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		filter, err := auditFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		events, err := s.ListEvents(ctx, filter)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewAuditEvents(events))
+	})
+}
+
+// auditFilter reads the filter of the audit trail from the query. Times are RFC 3339.
+func auditFilter(query url.Values) (entity.AuditFilter, error) {
+	filter := entity.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+		Limit:  defaultAuditLimit,
+	}
+
+	var err error
+	if since := query.Get("since"); since != "" {
+		filter.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			return filter, fmt.Errorf("invalid since: %v", err)
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		filter.Until, err = time.Parse(time.RFC3339, until)
+		if err != nil {
+			return filter, fmt.Errorf("invalid until: %v", err)
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit <= 0 || filter.Limit > maxAuditLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxAuditLimit)
+		}
+	}
+	return filter, nil
+}
+
+func makeAuditRoutes(r *mux.Router, app *application.Application) {
+	path := "/audit"
+	serviceRouter := r.PathPrefix(path).Subrouter()
+	serviceRouter.Handle("", ListAuditEvents(app.AuditService)).Methods("GET")
+}
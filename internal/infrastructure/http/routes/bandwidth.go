@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/inview-team/gorynych/internal/application"
+	"github.com/inview-team/gorynych/internal/domain/service"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/controllers"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/views"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func GetBandwidthLimits(l *service.BandwidthLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewBandwidthLimits(l.Limits(r.Context())))
+	})
+}
+
+func SetGlobalBandwidth(l *service.BandwidthLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cBandwidth, ok := decodeBandwidth(w, r)
+		if !ok {
+			return
+		}
+
+		l.SetGlobalLimit(cBandwidth.Limit)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func SetProviderBandwidth(l *service.BandwidthLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		providerID := mux.Vars(r)["provider_id"]
+
+		cBandwidth, ok := decodeBandwidth(w, r)
+		if !ok {
+			return
+		}
+
+		l.SetProviderLimit(providerID, cBandwidth.Limit)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func decodeBandwidth(w http.ResponseWriter, r *http.Request) (*controllers.Bandwidth, bool) {
+	cBandwidth := new(controllers.Bandwidth)
+	if err := json.NewDecoder(r.Body).Decode(&cBandwidth); err != nil {
+		log.Errorf("failed to decode payload")
+		http.Error(w, "Error setting bandwidth limit", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if cBandwidth.Limit < 0 {
+		http.Error(w, "limit must not be negative", http.StatusBadRequest)
+		return nil, false
+	}
+	return cBandwidth, true
+}
+
+func makeBandwidthRoutes(r *mux.Router, app *application.Application) {
+	path := "/bandwidth"
+	serviceRouter := r.PathPrefix(path).Subrouter()
+	serviceRouter.Handle("", GetBandwidthLimits(app.BandwidthLimiter)).Methods("GET")
+	serviceRouter.Handle("", SetGlobalBandwidth(app.BandwidthLimiter)).Methods("PUT")
+	serviceRouter.Handle("/providers/{provider_id}", SetProviderBandwidth(app.BandwidthLimiter)).Methods("PUT")
+}
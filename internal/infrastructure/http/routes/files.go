@@ -9,8 +9,12 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/inview-team/gorynych/internal/application"
+	"github.com/inview-team/gorynych/internal/domain/entity"
 	"github.com/inview-team/gorynych/internal/domain/service"
 	"github.com/inview-team/gorynych/internal/infrastructure/http/controllers"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/views"
+
+	log "github.com/sirupsen/logrus"
 )
 
 func CreateUpload(s *service.UploadService) http.Handler {
@@ -20,17 +24,75 @@ func CreateUpload(s *service.UploadService) http.Handler {
 		size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
 		if err != nil {
 			http.Error(w, "", http.StatusBadRequest)
+			return
 		}
 
 		meta := controllers.NewMetadata(r.Header.Get("Upload-Metadata"))
 
-		id, err := s.CreateUpload(ctx, size, meta)
+		cStorage, err := controllers.NewTargetStorage(r.Header.Get(controllers.StorageHeader), meta)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var target *entity.Storage
+		if cStorage != nil {
+			target = (*entity.Storage)(cStorage)
+		}
+
+		sse, err := controllers.NewServerSideEncryption(r.Header)
 		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id, err := s.CreateUpload(ctx, size, meta, target, sse)
+		if err != nil {
+			if errors.Is(err, service.ErrUploadBig) {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			if errors.Is(err, service.ErrQuotaExceeded) {
+				http.Error(w, err.Error(), http.StatusInsufficientStorage)
+				return
+			}
+
+			if errors.Is(err, service.ErrInvalidMirror) || errors.Is(err, service.ErrUnknownPlacement) || errors.Is(err, service.ErrInvalidEncryption) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if errors.Is(err, service.ErrUnknownStorage) {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+
+			if errors.Is(err, service.ErrUploadNotGranted) || errors.Is(err, service.ErrUploadTokenUsed) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+
+			if errors.Is(err, service.ErrStorageNotWritable) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+
+			if errors.Is(err, service.ErrNotEnoughStorages) {
+				http.Error(w, err.Error(), http.StatusInsufficientStorage)
+				return
+			}
 			http.Error(w, "", http.StatusInternalServerError)
+			return
 		}
 
 		fmt.Println(id)
-		w.Header().Set("Location", fmt.Sprintf("%s/%s", r.URL.String(), string(id)))
+		// The query keeps the upload token of clients using the upload URL
+		location := fmt.Sprintf("%s/%s", r.URL.Path, string(id))
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+		w.Header().Set("Location", location)
 		w.WriteHeader(http.StatusCreated)
 	})
 }
@@ -51,14 +113,26 @@ func WriteChunk(s *service.UploadService) http.Handler {
 			return
 		}
 
+		sse, err := controllers.NewServerSideEncryption(r.Header)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		var bodyBuffer []byte
 		bodyBuffer, err = io.ReadAll(r.Body)
 		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "chunk is larger than the server accepts", http.StatusRequestEntityTooLarge)
+				return
+			}
 			http.Error(w, "", http.StatusBadRequest)
+			return
 		}
 		defer r.Body.Close()
 
-		newOffset, err := s.WritePart(ctx, objectID, offset, &bodyBuffer)
+		newOffset, err := s.WritePart(ctx, objectID, offset, &bodyBuffer, sse)
 		if err != nil {
 			if errors.Is(err, service.ErrUploadNotFound) {
 				http.Error(w, "", http.StatusNotFound)
@@ -70,11 +144,27 @@ func WriteChunk(s *service.UploadService) http.Handler {
 				return
 			}
 
+			if errors.Is(err, service.ErrUploadFinished) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+
 			if errors.Is(err, service.ErrUploadBig) {
 				http.Error(w, "", http.StatusRequestEntityTooLarge)
 				return
 			}
+
+			if errors.Is(err, service.ErrCustomerKeyNeeded) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if errors.Is(err, service.ErrQuorumNotReached) {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
 			http.Error(w, "", http.StatusInternalServerError)
+			return
 		}
 
 		w.Header().Add("Upload-Offset", strconv.Itoa(int(newOffset)))
@@ -96,10 +186,10 @@ func GetUploadInformation(s *service.UploadService) http.Handler {
 			}
 		}
 
-		//metaHeader := views.NewResponseMetadata(uploadInfo.Metadata)
-		//if metaHeader != "" {
-		//	w.Header().Add("Upload-Metadata", metaHeader)
-		//}
+		metaHeader := views.NewResponseMetadata(uploadInfo.Metadata)
+		if metaHeader != "" {
+			w.Header().Add("Upload-Metadata", metaHeader)
+		}
 		w.Header().Add("Upload-Offset", strconv.Itoa(int(uploadInfo.Offset)))
 		w.Header().Add("Upload-Length", strconv.Itoa(int(uploadInfo.Size)))
 		w.Header().Add("Cache-Control", "no-store")
@@ -108,14 +198,112 @@ func GetUploadInformation(s *service.UploadService) http.Handler {
 	})
 }
 
-func GetServerInformation(service *service.UploadService) http.Handler {
+// DownloadObject sends the object of a completed upload, or the range asked by the
+// Range header. Objects encrypted by the gateway are decrypted on the fly; objects
+// written with SSE-C need their key in the headers.
+func DownloadObject(s *service.UploadService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		objectID := mux.Vars(r)["object_id"]
+
+		sse, err := controllers.NewServerSideEncryption(r.Header)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		object, err := s.GetObject(ctx, objectID, sse)
+		if err != nil {
+			if errors.Is(err, service.ErrObjectNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, service.ErrCustomerKeyNeeded) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		cRange, err := controllers.NewRange(r.Header.Get("Range"), object.Size)
+		if err != nil {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", object.Size))
+			http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+
+		contentType := object.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Accept-Ranges", "bytes")
+		if object.ETag != "" {
+			w.Header().Set("ETag", object.ETag)
+		}
+
+		if object.Size == 0 {
+			w.Header().Set("Content-Length", "0")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		start, end := int64(0), object.Size-1
+		if cRange != nil {
+			start, end = cRange.Start, cRange.End
+		}
+
+		reader, err := s.Download(ctx, objectID, start, end, sse)
+		if err != nil {
+			if errors.Is(err, service.ErrObjectNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, service.ErrInvalidRange) {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", object.Size))
+				http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			if errors.Is(err, service.ErrCustomerKeyNeeded) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		defer reader.Close()
+
+		status := http.StatusOK
+		if cRange != nil {
+			status = http.StatusPartialContent
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, object.Size))
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+		w.WriteHeader(status)
+		if _, err := io.Copy(w, reader); err != nil {
+			log.Errorf("failed to send object %s: %v", objectID, err)
+		}
+	})
+}
+
+func GetServerInformation(s *service.UploadService) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		maxSize, limited, err := s.MaxUploadSize(ctx)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
 		w.Header().Add("Tus-Version", "1.0.0")
 		w.Header().Add("Tus-Resumable", "1.0.0")
 		w.Header().Add("Tus-Extension", "creation")
-		w.Header().Set("Tus-Max-Size", strconv.Itoa(int(500000000)))
+		if limited {
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(maxSize, 10))
+		}
 		w.WriteHeader(http.StatusNoContent)
-		return
 	})
 }
 
@@ -126,4 +314,5 @@ func makeFileRoutes(r *mux.Router, app *application.Application) {
 	serviceRouter.Handle("/{object_id}", GetUploadInformation(app.UploadService)).Methods("HEAD")
 	serviceRouter.Handle("", GetServerInformation(app.UploadService)).Methods("OPTIONS")
 	serviceRouter.Handle("/{object_id}", WriteChunk(app.UploadService)).Methods("PATCH")
+	serviceRouter.Handle("/{object_id}", DownloadObject(app.UploadService)).Methods("GET")
 }
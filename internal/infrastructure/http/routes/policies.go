@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/inview-team/gorynych/internal/application"
+	"github.com/inview-team/gorynych/internal/domain/service"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/controllers"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/views"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func AddPolicy(s *service.PolicyService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorMessage := "Error creating policy"
+		ctx := r.Context()
+
+		cPolicy := new(controllers.PolicyInput)
+		if err := json.NewDecoder(r.Body).Decode(&cPolicy); err != nil {
+			log.Errorf("failed to decode payload")
+			http.Error(w, errorMessage, http.StatusBadRequest)
+			return
+		}
+
+		id, err := s.AddPolicy(ctx, cPolicy.Policy())
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidPolicy) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&views.ID{ID: id})
+	})
+}
+
+func ListPolicies(s *service.PolicyService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		policies, err := s.ListPolicies(ctx)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewPolicies(policies))
+	})
+}
+
+func GetPolicy(s *service.PolicyService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		policyID := mux.Vars(r)["policy_id"]
+
+		policy, err := s.GetPolicy(ctx, policyID)
+		if err != nil {
+			if errors.Is(err, service.ErrPolicyNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewPolicy(policy))
+	})
+}
+
+func UpdatePolicy(s *service.PolicyService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorMessage := "Error updating policy"
+		ctx := r.Context()
+		policyID := mux.Vars(r)["policy_id"]
+
+		cPolicy := new(controllers.PolicyInput)
+		if err := json.NewDecoder(r.Body).Decode(&cPolicy); err != nil {
+			log.Errorf("failed to decode payload")
+			http.Error(w, errorMessage, http.StatusBadRequest)
+			return
+		}
+
+		policy := cPolicy.Policy()
+		policy.ID = policyID
+		err := s.UpdatePolicy(ctx, policy)
+		if err != nil {
+			if errors.Is(err, service.ErrPolicyNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+
+			if errors.Is(err, service.ErrInvalidPolicy) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func DeletePolicy(s *service.PolicyService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		policyID := mux.Vars(r)["policy_id"]
+
+		err := s.DeletePolicy(ctx, policyID)
+		if err != nil {
+			if errors.Is(err, service.ErrPolicyNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func makePolicyRoutes(r *mux.Router, app *application.Application) {
+	path := "/policies"
+	serviceRouter := r.PathPrefix(path).Subrouter()
+	serviceRouter.Handle("", AddPolicy(app.PolicyService)).Methods("POST")
+	serviceRouter.Handle("", ListPolicies(app.PolicyService)).Methods("GET")
+	serviceRouter.Handle("/{policy_id}", GetPolicy(app.PolicyService)).Methods("GET")
+	serviceRouter.Handle("/{policy_id}", UpdatePolicy(app.PolicyService)).Methods("PUT")
+	serviceRouter.Handle("/{policy_id}", DeletePolicy(app.PolicyService)).Methods("DELETE")
+}
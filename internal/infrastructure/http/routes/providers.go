@@ -0,0 +1,149 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/inview-team/gorynych/internal/application"
+	"github.com/inview-team/gorynych/internal/domain/service"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/controllers"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/views"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func AddProvider(s *service.ProviderService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorMessage := "Error creating provider"
+		ctx := r.Context()
+
+		cProvider := new(controllers.ProviderInput)
+		if err := json.NewDecoder(r.Body).Decode(&cProvider); err != nil {
+			log.Errorf("failed to decode payload")
+			http.Error(w, errorMessage, http.StatusBadRequest)
+			return
+		}
+
+		id, err := s.AddProvider(ctx, cProvider.Provider())
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidProvider) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if errors.Is(err, service.ErrProviderExists) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&views.ID{ID: id})
+	})
+}
+
+func ListProviders(s *service.ProviderService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		providers, err := s.ListProviders(ctx)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewProviders(providers))
+	})
+}
+
+func GetProvider(s *service.ProviderService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		providerID := mux.Vars(r)["provider_id"]
+
+		provider, err := s.GetProvider(ctx, providerID)
+		if err != nil {
+			if errors.Is(err, service.ErrProviderNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewProvider(provider))
+	})
+}
+
+func UpdateProvider(s *service.ProviderService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorMessage := "Error updating provider"
+		ctx := r.Context()
+		providerID := mux.Vars(r)["provider_id"]
+
+		cProvider := new(controllers.ProviderInput)
+		if err := json.NewDecoder(r.Body).Decode(&cProvider); err != nil {
+			log.Errorf("failed to decode payload")
+			http.Error(w, errorMessage, http.StatusBadRequest)
+			return
+		}
+
+		provider := cProvider.Provider()
+		provider.ID = providerID
+		err := s.UpdateProvider(ctx, provider)
+		if err != nil {
+			if errors.Is(err, service.ErrProviderNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+
+			if errors.Is(err, service.ErrInvalidProvider) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func DeleteProvider(s *service.ProviderService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		providerID := mux.Vars(r)["provider_id"]
+
+		err := s.DeleteProvider(ctx, providerID)
+		if err != nil {
+			if errors.Is(err, service.ErrProviderNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+
+			if errors.Is(err, service.ErrProviderInUse) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func makeProviderRoutes(r *mux.Router, app *application.Application) {
+	path := "/providers"
+	serviceRouter := r.PathPrefix(path).Subrouter()
+	serviceRouter.Handle("", AddProvider(app.ProviderService)).Methods("POST")
+	serviceRouter.Handle("", ListProviders(app.ProviderService)).Methods("GET")
+	serviceRouter.Handle("/{provider_id}", GetProvider(app.ProviderService)).Methods("GET")
+	serviceRouter.Handle("/{provider_id}", UpdateProvider(app.ProviderService)).Methods("PUT")
+	serviceRouter.Handle("/{provider_id}", DeleteProvider(app.ProviderService)).Methods("DELETE")
+}
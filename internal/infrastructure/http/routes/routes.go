@@ -9,17 +9,38 @@ import (
 	"github.com/inview-team/gorynych/internal/infrastructure/http/middleware"
 )
 
-func Make(app *application.Application) http.Handler {
+func Make(app *application.Application, auth *middleware.Authenticator) http.Handler {
 	r := mux.NewRouter()
 	//r.PathPrefix("/docs/").Handler(httpSwagger.WrapHandler)
 
 	r.MethodNotAllowedHandler = handlers.NotAllowedHandler()
 	r.NotFoundHandler = handlers.NotFoundHandler()
+	r.Use(middleware.RequestID)
+	// Every route of /api and /files needs a token, tus uploads may use an upload token
+	r.Use(auth.Middleware)
 
 	path := "/api"
 	apiRouter := r.PathPrefix(path).Subrouter()
 	makeFileRoutes(r, app)
 	makeAccountRoutes(apiRouter, app)
+	makeProviderRoutes(apiRouter, app)
 	makeTaskRoutes(apiRouter, app)
+	makeBandwidthRoutes(apiRouter, app)
+	makeScheduleRoutes(apiRouter, app)
+	makePolicyRoutes(apiRouter, app)
+	makeAuditRoutes(apiRouter, app)
+	makeUploadTokenRoutes(apiRouter, app, auth)
+
+	// Every route requires the scope the auth policy gives it
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		handler := route.GetHandler()
+		if handler == nil {
+			return nil
+		}
+		template, _ := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+		route.Handler(auth.Authorize(template, methods, handler))
+		return nil
+	})
 	return middleware.NewLogger(r)
 }
@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/inview-team/gorynych/internal/application"
+	"github.com/inview-team/gorynych/internal/domain/service"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/controllers"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/views"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func AddSchedule(s *service.SchedulerService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorMessage := "Error creating schedule"
+		ctx := r.Context()
+
+		cSchedule := new(controllers.ScheduleInput)
+		if err := json.NewDecoder(r.Body).Decode(&cSchedule); err != nil {
+			log.Errorf("failed to decode payload")
+			http.Error(w, errorMessage, http.StatusBadRequest)
+			return
+		}
+
+		schedule, err := cSchedule.Schedule()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id, err := s.AddSchedule(ctx, schedule)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCron) || errors.Is(err, service.ErrInvalidSchedule) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&views.ID{ID: id})
+	})
+}
+
+func ListSchedules(s *service.SchedulerService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		schedules, err := s.ListSchedules(ctx)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewSchedules(schedules))
+	})
+}
+
+func GetSchedule(s *service.SchedulerService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		scheduleID := mux.Vars(r)["schedule_id"]
+
+		schedule, err := s.GetSchedule(ctx, scheduleID)
+		if err != nil {
+			if errors.Is(err, service.ErrScheduleNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewSchedule(schedule))
+	})
+}
+
+func DeleteSchedule(s *service.SchedulerService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		scheduleID := mux.Vars(r)["schedule_id"]
+
+		err := s.DeleteSchedule(ctx, scheduleID)
+		if err != nil {
+			if errors.Is(err, service.ErrScheduleNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func makeScheduleRoutes(r *mux.Router, app *application.Application) {
+	path := "/schedules"
+	serviceRouter := r.PathPrefix(path).Subrouter()
+	serviceRouter.Handle("", AddSchedule(app.SchedulerService)).Methods("POST")
+	serviceRouter.Handle("", ListSchedules(app.SchedulerService)).Methods("GET")
+	serviceRouter.Handle("/{schedule_id}", GetSchedule(app.SchedulerService)).Methods("GET")
+	serviceRouter.Handle("/{schedule_id}", DeleteSchedule(app.SchedulerService)).Methods("DELETE")
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -26,18 +27,218 @@ func ReplicateFile(s *service.TaskService) http.Handler {
 			return
 		}
 
-		taskID, err := s.Replication(ctx, objectID, entity.Storage(cTask.SourceStorage), entity.Storage(cTask.TargetStorage))
+		opts, err := cTask.Options()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		targets := cTask.Targets()
+		if len(targets) == 0 {
+			http.Error(w, service.ErrNoTargets.Error(), http.StatusBadRequest)
+			return
+		}
+
+		taskID, err := s.Replication(ctx, objectID, entity.Storage(cTask.SourceStorage), targets, opts)
 		if err != nil {
 			http.Error(w, "", http.StatusInternalServerError)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(&views.ID{ID: taskID})
+	})
+}
+
+func SyncBucket(s *service.TaskService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorMessage := "Error sync bucket"
+		ctx := r.Context()
+
+		cSync := new(controllers.SyncInput)
+		if err := json.NewDecoder(r.Body).Decode(&cSync); err != nil {
+			log.Errorf("failed to decode payload")
+			http.Error(w, errorMessage, http.StatusBadRequest)
+			return
+		}
+
+		opts, err := cSync.Options()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		taskID, err := s.Sync(ctx, entity.Storage(cSync.SourceStorage), entity.Storage(cSync.TargetStorage), opts)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
 		json.NewEncoder(w).Encode(&views.ID{ID: taskID})
 	})
 }
 
+func MirrorBucket(s *service.TaskService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorMessage := "Error mirror bucket"
+		ctx := r.Context()
+
+		cMirror := new(controllers.MirrorInput)
+		if err := json.NewDecoder(r.Body).Decode(&cMirror); err != nil {
+			log.Errorf("failed to decode payload")
+			http.Error(w, errorMessage, http.StatusBadRequest)
+			return
+		}
+
+		opts, err := cMirror.Options()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		taskID, err := s.Mirror(ctx, entity.Storage(cMirror.SourceStorage), entity.Storage(cMirror.TargetStorage), opts)
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(&views.ID{ID: taskID})
+	})
+}
+
+func MoveObjects(s *service.TaskService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorMessage := "Error move objects"
+		ctx := r.Context()
+
+		cMove := new(controllers.MoveInput)
+		if err := json.NewDecoder(r.Body).Decode(&cMove); err != nil {
+			log.Errorf("failed to decode payload")
+			http.Error(w, errorMessage, http.StatusBadRequest)
+			return
+		}
+
+		opts, err := cMove.Options()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sourceStorage := entity.Storage(cMove.SourceStorage)
+		targetStorage := entity.Storage(cMove.TargetStorage)
+		if cMove.DryRun {
+			items, err := s.PlanMove(ctx, sourceStorage, targetStorage, opts)
+			if err != nil {
+				writeMoveError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(views.NewMoveItems(items))
+			return
+		}
+
+		taskID, err := s.Move(ctx, sourceStorage, targetStorage, opts)
+		if err != nil {
+			writeMoveError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(&views.ID{ID: taskID})
+	})
+}
+
+func writeMoveError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrInvalidMove) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if errors.Is(err, service.ErrObjectNotFound) {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "", http.StatusInternalServerError)
+}
+
+func GetTask(s *service.TaskService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		taskID := mux.Vars(r)["task_id"]
+
+		task, err := s.GetTask(ctx, taskID)
+		if err != nil {
+			if errors.Is(err, service.ErrTaskNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewTask(task))
+	})
+}
+
+func ListChildTasks(s *service.TaskService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		taskID := mux.Vars(r)["task_id"]
+
+		tasks, err := s.ListChildTasks(ctx, taskID)
+		if err != nil {
+			if errors.Is(err, service.ErrTaskNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views.NewTasks(tasks))
+	})
+}
+
+func SetTaskBandwidth(s *service.TaskService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		taskID := mux.Vars(r)["task_id"]
+
+		cBandwidth, ok := decodeBandwidth(w, r)
+		if !ok {
+			return
+		}
+
+		err := s.SetTaskBandwidth(ctx, taskID, cBandwidth.Limit)
+		if err != nil {
+			if errors.Is(err, service.ErrTaskNotFound) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+
+			if errors.Is(err, service.ErrTaskFinished) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
 func makeTaskRoutes(r *mux.Router, app *application.Application) {
 	path := "/tasks"
 	serviceRouter := r.PathPrefix(path).Subrouter()
 	serviceRouter.Handle("/replicate/{object_id}", ReplicateFile(app.TaskService)).Methods("POST")
+	serviceRouter.Handle("/sync", SyncBucket(app.TaskService)).Methods("POST")
+	serviceRouter.Handle("/mirror", MirrorBucket(app.TaskService)).Methods("POST")
+	serviceRouter.Handle("/move", MoveObjects(app.TaskService)).Methods("POST")
+	serviceRouter.Handle("/{task_id}", GetTask(app.TaskService)).Methods("GET")
+	serviceRouter.Handle("/{task_id}/children", ListChildTasks(app.TaskService)).Methods("GET")
+	serviceRouter.Handle("/{task_id}/bandwidth", SetTaskBandwidth(app.TaskService)).Methods("PUT")
 }
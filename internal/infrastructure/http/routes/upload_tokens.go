@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/inview-team/gorynych/internal/application"
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"github.com/inview-team/gorynych/internal/domain/service"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/controllers"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/middleware"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/views"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MintUploadToken signs a token letting a browser create and write a single upload
+// without the credentials of the caller.
+func MintUploadToken(auth *middleware.Authenticator, audit *service.AuditService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		tokens := auth.UploadTokens()
+		principal := middleware.PrincipalFromContext(ctx)
+		if tokens == nil || principal == nil {
+			http.Error(w, "upload tokens are not enabled", http.StatusNotImplemented)
+			return
+		}
+
+		cToken := new(controllers.UploadTokenInput)
+		if err := json.NewDecoder(r.Body).Decode(&cToken); err != nil {
+			log.Errorf("failed to decode payload")
+			http.Error(w, "Error minting upload token", http.StatusBadRequest)
+			return
+		}
+		storage, err := cToken.Storage()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ttl, err := cToken.TTL()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		token, grant, err := tokens.Mint(principal, cToken.Size, storage, cToken.Metadata, ttl)
+		if err != nil {
+			if errors.Is(err, middleware.ErrInvalidUploadToken) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		details := map[string]string{
+			"size":       strconv.FormatInt(grant.MaxSize, 10),
+			"expires_at": grant.ExpiresAt.Format(time.RFC3339),
+		}
+		if storage != nil {
+			details["storage"] = storage.String()
+		}
+		audit.Record(ctx, entity.AuditUploadTokenMint, "upload_token/"+grant.ID, nil, details)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&views.UploadToken{
+			Token:     token,
+			ExpiresAt: grant.ExpiresAt,
+			UploadURL: "/files?" + url.Values{middleware.UploadTokenParam: {token}}.Encode(),
+		})
+	})
+}
+
+func makeUploadTokenRoutes(r *mux.Router, app *application.Application, auth *middleware.Authenticator) {
+	path := "/upload-tokens"
+	serviceRouter := r.PathPrefix(path).Subrouter()
+	serviceRouter.Handle("", MintUploadToken(auth, app.AuditService)).Methods("POST")
+}
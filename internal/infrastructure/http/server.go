@@ -8,10 +8,12 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/inview-team/gorynych/internal/application"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/listener"
+	"github.com/inview-team/gorynych/internal/infrastructure/http/middleware"
 	"github.com/inview-team/gorynych/internal/infrastructure/http/routes"
+	"github.com/inview-team/gorynych/internal/infrastructure/mongo"
 )
 
 //	@title			Swagger Backend API
@@ -22,57 +24,103 @@ import (
 // @host		127.0.0.1
 // @BasePath	/
 type Server struct {
-	srv http.Server
+	srv      http.Server
+	admin    *http.Server
+	reloader *listener.Reloader
+	cfg      listener.Config
 }
 
-type Config struct {
-	Timeout TimeoutConfig `yaml:"timeout,omitempty"`
-}
+func NewServer(app *application.Application, auth *middleware.Authenticator, client *mongo.Client, cfg listener.Config) (*Server, error) {
+	s := &Server{
+		srv: http.Server{
+			Handler:           middleware.MaxBodySize(cfg.MaxBodyBytes)(routes.Make(app, auth)),
+			Addr:              cfg.Address,
+			IdleTimeout:       cfg.Timeout.Idle,
+			ReadTimeout:       cfg.Timeout.Read,
+			ReadHeaderTimeout: cfg.Timeout.ReadHeader,
+			WriteTimeout:      cfg.Timeout.Write,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		},
+		cfg: cfg,
+	}
 
-type TimeoutConfig struct {
-	Idle  time.Duration `yaml:"idle"`
-	Read  time.Duration `yaml:"read"`
-	Write time.Duration `yaml:"write"`
-}
+	if cfg.TLS.Enabled() {
+		reloader, err := listener.NewReloader(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		s.reloader = reloader
+		s.srv.TLSConfig = reloader.Config()
+	}
 
-var (
-	DefaultConfig = Config{
-		Timeout: TimeoutConfig{
-			Idle:  time.Second * 30,
-			Read:  time.Second * 30,
-			Write: time.Second * 30,
-		},
+	if cfg.Admin.Address != "" {
+		if cfg.Admin.TLS && s.reloader == nil {
+			return nil, errors.New("admin tls needs the tls configuration of the server")
+		}
+		s.admin = &http.Server{
+			Handler:           routes.MakeAdmin(client, cfg.Admin.Profiling),
+			Addr:              cfg.Admin.Address,
+			IdleTimeout:       cfg.Timeout.Idle,
+			ReadTimeout:       cfg.Timeout.Read,
+			ReadHeaderTimeout: cfg.Timeout.ReadHeader,
+			WriteTimeout:      cfg.Timeout.Write,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		}
+		if cfg.Admin.TLS {
+			s.admin.TLSConfig = s.reloader.Config()
+		}
 	}
-)
+	return s, nil
+}
 
-func NewServer(app *application.Application) *Server {
-	return &Server{
-		srv: http.Server{
-			Handler:      routes.Make(app),
-			Addr:         ":30000",
-			IdleTimeout:  DefaultConfig.Timeout.Idle,
-			ReadTimeout:  DefaultConfig.Timeout.Read,
-			WriteTimeout: DefaultConfig.Timeout.Write,
-		},
+func serve(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		// The certificate comes from the TLS configuration
+		return srv.ListenAndServeTLS("", "")
 	}
+	return srv.ListenAndServe()
 }
 
 func (s *Server) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if s.reloader != nil {
+		go s.reloader.Watch(ctx)
+	}
+
 	go func() {
 		listener := make(chan os.Signal, 1)
 		signal.Notify(listener, os.Interrupt, syscall.SIGTERM)
 		fmt.Println("Received a shutdown signal:", <-listener)
 		// Listen on application shutdown signals.
 
-		// Shutdown HTTP server.
-		if err := s.srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		shutdownCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Shutdown)
+		defer cancel()
+
+		// Shutdown HTTP servers.
+		if s.admin != nil {
+			if err := s.admin.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Printf("Failed to shutdown admin listener: %s", err)
+			}
+		}
+		if err := s.srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			fmt.Printf("Failed to shutdown: %s", err)
 		}
 	}()
 
+	if s.admin != nil {
+		go func() {
+			fmt.Println("Admin listening on ", s.admin.Addr)
+			if err := serve(s.admin); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Printf("Failed to listen and serve admin: %s", err)
+			}
+		}()
+	}
+
 	fmt.Println("Listening on ", s.srv.Addr)
 	// Start HTTP server.
-	if err := s.srv.ListenAndServe(); err != nil {
+	if err := serve(&s.srv); err != nil {
 		fmt.Printf("Failed to listen and serve: %s", err)
 	}
 }
@@ -0,0 +1,51 @@
+package views
+
+import (
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+// Account never exposes the secret of the account.
+type Account struct {
+	ID         string         `json:"id"`
+	TenantID   string         `json:"tenant_id,omitempty"`
+	Provider   string         `json:"provider"`
+	Region     string         `json:"region"`
+	AccessKey  string         `json:"access_key"`
+	Disabled   bool           `json:"disabled"`
+	Buckets    []BucketAccess `json:"buckets"`
+	VerifiedAt time.Time      `json:"verified_at"`
+}
+
+type BucketAccess struct {
+	Bucket string `json:"bucket"`
+	Read   bool   `json:"read"`
+	Write  bool   `json:"write"`
+}
+
+func NewAccount(account *entity.ServiceAccount) *Account {
+	buckets := make([]BucketAccess, 0, len(account.Buckets))
+	for _, access := range account.Buckets {
+		buckets = append(buckets, BucketAccess(access))
+	}
+
+	return &Account{
+		ID:         account.ID,
+		TenantID:   account.TenantID,
+		Provider:   account.ProviderID,
+		Region:     account.Region,
+		AccessKey:  account.AccessKey,
+		Disabled:   account.Disabled,
+		Buckets:    buckets,
+		VerifiedAt: account.VerifiedAt,
+	}
+}
+
+func NewAccounts(accounts []*entity.ServiceAccount) []*Account {
+	views := make([]*Account, 0, len(accounts))
+	for _, account := range accounts {
+		views = append(views, NewAccount(account))
+	}
+	return views
+}
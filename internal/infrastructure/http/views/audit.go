@@ -0,0 +1,50 @@
+package views
+
+import (
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+var auditOutcomes = map[entity.AuditOutcome]string{
+	entity.AuditSuccess: "success",
+	entity.AuditFailure: "failure",
+}
+
+type AuditEvent struct {
+	ID        string            `json:"id"`
+	TenantID  string            `json:"tenant_id,omitempty"`
+	Time      time.Time         `json:"time"`
+	Actor     string            `json:"actor"`
+	Action    string            `json:"action"`
+	Target    string            `json:"target"`
+	RequestID string            `json:"request_id,omitempty"`
+	SourceIP  string            `json:"source_ip,omitempty"`
+	Outcome   string            `json:"outcome"`
+	Error     string            `json:"error,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+func NewAuditEvent(event *entity.AuditEvent) *AuditEvent {
+	return &AuditEvent{
+		ID:        event.ID,
+		TenantID:  event.TenantID,
+		Time:      event.Time,
+		Actor:     event.Actor,
+		Action:    event.Action,
+		Target:    event.Target,
+		RequestID: event.RequestID,
+		SourceIP:  event.SourceIP,
+		Outcome:   auditOutcomes[event.Outcome],
+		Error:     event.Error,
+		Details:   event.Details,
+	}
+}
+
+func NewAuditEvents(events []*entity.AuditEvent) []*AuditEvent {
+	views := make([]*AuditEvent, 0, len(events))
+	for _, event := range events {
+		views = append(views, NewAuditEvent(event))
+	}
+	return views
+}
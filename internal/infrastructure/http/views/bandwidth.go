@@ -0,0 +1,17 @@
+package views
+
+import "github.com/inview-team/gorynych/internal/domain/service"
+
+type BandwidthLimits struct {
+	Global    int64            `json:"global"`
+	Providers map[string]int64 `json:"providers"`
+	Tasks     map[string]int64 `json:"tasks"`
+}
+
+func NewBandwidthLimits(limits service.BandwidthLimits) *BandwidthLimits {
+	return &BandwidthLimits{
+		Global:    limits.Global,
+		Providers: limits.Providers,
+		Tasks:     limits.Tasks,
+	}
+}
@@ -0,0 +1,23 @@
+package views
+
+import "github.com/inview-team/gorynych/internal/domain/entity"
+
+type MoveItem struct {
+	SourceKey    string `json:"source_key"`
+	TargetKey    string `json:"target_key"`
+	Size         int64  `json:"size"`
+	TargetExists bool   `json:"target_exists"`
+}
+
+func NewMoveItems(items []entity.MoveItem) []*MoveItem {
+	views := make([]*MoveItem, 0, len(items))
+	for _, item := range items {
+		views = append(views, &MoveItem{
+			SourceKey:    item.SourceKey,
+			TargetKey:    item.TargetKey,
+			Size:         item.Size,
+			TargetExists: item.TargetExists,
+		})
+	}
+	return views
+}
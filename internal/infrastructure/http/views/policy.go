@@ -0,0 +1,42 @@
+package views
+
+import "github.com/inview-team/gorynych/internal/domain/entity"
+
+type Policy struct {
+	ID             string            `json:"id"`
+	TenantID       string            `json:"tenant_id,omitempty"`
+	Name           string            `json:"name"`
+	Enabled        bool              `json:"enabled"`
+	SourceStorage  Storage           `json:"source_storage"`
+	TargetStorages []Storage         `json:"target_storages"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	MinSize        int64             `json:"min_size"`
+	MaxSize        int64             `json:"max_size"`
+}
+
+func NewPolicy(policy *entity.ReplicationPolicy) *Policy {
+	targets := make([]Storage, 0, len(policy.Targets))
+	for _, target := range policy.Targets {
+		targets = append(targets, Storage(target))
+	}
+
+	return &Policy{
+		ID:             policy.ID,
+		TenantID:       policy.TenantID,
+		Name:           policy.Name,
+		Enabled:        policy.Enabled,
+		SourceStorage:  Storage(policy.Source),
+		TargetStorages: targets,
+		Metadata:       policy.Metadata,
+		MinSize:        policy.MinSize,
+		MaxSize:        policy.MaxSize,
+	}
+}
+
+func NewPolicies(policies []*entity.ReplicationPolicy) []*Policy {
+	views := make([]*Policy, 0, len(policies))
+	for _, policy := range policies {
+		views = append(views, NewPolicy(policy))
+	}
+	return views
+}
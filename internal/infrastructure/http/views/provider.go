@@ -0,0 +1,40 @@
+package views
+
+import "github.com/inview-team/gorynych/internal/domain/entity"
+
+type Provider struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	Endpoint         string `json:"endpoint"`
+	Region           string `json:"region,omitempty"`
+	PathStyle        bool   `json:"path_style"`
+	SkipTLSVerify    bool   `json:"skip_tls_verify"`
+	SignatureVersion string `json:"signature_version"`
+	Encryption       string `json:"encryption,omitempty"`
+	KMSKeyID         string `json:"kms_key_id,omitempty"`
+}
+
+func NewProvider(provider *entity.Provider) *Provider {
+	view := &Provider{
+		ID:               provider.ID,
+		Name:             provider.Name,
+		Endpoint:         provider.Endpoint,
+		Region:           provider.Region,
+		PathStyle:        provider.PathStyle,
+		SkipTLSVerify:    provider.SkipTLSVerify,
+		SignatureVersion: string(provider.SignatureVersion),
+	}
+	if provider.Encryption != nil {
+		view.Encryption = provider.Encryption.Mode.String()
+		view.KMSKeyID = provider.Encryption.KMSKeyID
+	}
+	return view
+}
+
+func NewProviders(providers []*entity.Provider) []*Provider {
+	views := make([]*Provider, 0, len(providers))
+	for _, provider := range providers {
+		views = append(views, NewProvider(provider))
+	}
+	return views
+}
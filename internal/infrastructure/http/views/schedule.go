@@ -0,0 +1,141 @@
+package views
+
+import (
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+var scheduleModes = map[entity.ScheduleMode]string{
+	entity.ScheduleReplication: "replicate",
+	entity.ScheduleSync:        "sync",
+	entity.ScheduleMirror:      "mirror",
+}
+
+var metadataDirectives = map[entity.MetadataDirective]string{
+	entity.MetadataCopy:    "COPY",
+	entity.MetadataReplace: "REPLACE",
+}
+
+var conflictPolicies = map[entity.ConflictPolicy]string{
+	entity.ConflictOverwrite:    "overwrite",
+	entity.ConflictSkipIfExists: "skip_if_exists",
+	entity.ConflictSkipIfSame:   "skip_if_same",
+	entity.ConflictFail:         "fail",
+}
+
+type Schedule struct {
+	ID            string   `json:"id"`
+	TenantID      string   `json:"tenant_id,omitempty"`
+	Cron          string   `json:"cron"`
+	Mode          string   `json:"mode"`
+	SourceStorage Storage  `json:"source_storage"`
+	TargetStorage Storage  `json:"target_storage"`
+	ObjectID      string   `json:"object_id,omitempty"`
+	Prefix        string   `json:"prefix,omitempty"`
+	Include       []string `json:"include,omitempty"`
+	Exclude       []string `json:"exclude,omitempty"`
+	Delete        bool     `json:"delete"`
+	ReplicationOptions
+	LastRun    time.Time     `json:"last_run"`
+	NextRun    time.Time     `json:"next_run"`
+	LastTaskID string        `json:"last_task_id,omitempty"`
+	History    []ScheduleRun `json:"history"`
+}
+
+// ReplicationOptions are the options of the objects replicated by a schedule.
+type ReplicationOptions struct {
+	MaxBandwidth      int64                 `json:"max_bandwidth,omitempty"`
+	MetadataDirective string                `json:"metadata_directive,omitempty"`
+	Attributes        *ObjectAttributes     `json:"attributes,omitempty"`
+	TargetKey         string                `json:"target_key,omitempty"`
+	PrefixRewrite     *PrefixRewrite        `json:"prefix_rewrite,omitempty"`
+	ConflictPolicy    string                `json:"conflict_policy,omitempty"`
+	TargetEncryption  *ServerSideEncryption `json:"target_encryption,omitempty"`
+}
+
+type ObjectAttributes struct {
+	ContentType        string            `json:"content_type,omitempty"`
+	CacheControl       string            `json:"cache_control,omitempty"`
+	ContentEncoding    string            `json:"content_encoding,omitempty"`
+	ContentDisposition string            `json:"content_disposition,omitempty"`
+	ContentLanguage    string            `json:"content_language,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+}
+
+type PrefixRewrite struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ServerSideEncryption struct {
+	Mode     string `json:"mode"`
+	KMSKeyID string `json:"kms_key_id,omitempty"`
+}
+
+func NewReplicationOptions(opts entity.ReplicationOptions) ReplicationOptions {
+	view := ReplicationOptions{
+		MaxBandwidth:      opts.MaxBandwidth,
+		MetadataDirective: metadataDirectives[opts.MetadataDirective],
+		TargetKey:         opts.TargetKey,
+		ConflictPolicy:    conflictPolicies[opts.ConflictPolicy],
+	}
+	if attrs := ObjectAttributes(opts.Attributes); attrs.ContentType != "" || attrs.CacheControl != "" || attrs.ContentEncoding != "" ||
+		attrs.ContentDisposition != "" || attrs.ContentLanguage != "" || len(attrs.Metadata) > 0 || len(attrs.Tags) > 0 {
+		view.Attributes = &attrs
+	}
+	if opts.PrefixRewrite != nil {
+		view.PrefixRewrite = &PrefixRewrite{From: opts.PrefixRewrite.From, To: opts.PrefixRewrite.To}
+	}
+	if opts.TargetEncryption != nil {
+		view.TargetEncryption = &ServerSideEncryption{Mode: opts.TargetEncryption.Mode.String(), KMSKeyID: opts.TargetEncryption.KMSKeyID}
+	}
+	return view
+}
+
+type ScheduleRun struct {
+	Time   time.Time `json:"time"`
+	TaskID string    `json:"task_id,omitempty"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+type Storage struct {
+	ProviderID string `json:"provider_id"`
+	Bucket     string `json:"bucket"`
+}
+
+func NewSchedule(schedule *entity.Schedule) *Schedule {
+	history := make([]ScheduleRun, 0, len(schedule.History))
+	for _, run := range schedule.History {
+		history = append(history, ScheduleRun{Time: run.Time, TaskID: run.TaskID, Status: taskStatuses[run.Status], Error: run.Error})
+	}
+
+	return &Schedule{
+		ID:                 schedule.ID,
+		TenantID:           schedule.TenantID,
+		Cron:               schedule.Cron,
+		Mode:               scheduleModes[schedule.Mode],
+		SourceStorage:      Storage(schedule.SourceStorage),
+		TargetStorage:      Storage(schedule.TargetStorage),
+		ObjectID:           schedule.ObjectID,
+		Prefix:             schedule.Options.Prefix,
+		Include:            schedule.Options.Include,
+		Exclude:            schedule.Options.Exclude,
+		Delete:             schedule.Options.Delete,
+		ReplicationOptions: NewReplicationOptions(schedule.Options.Replication),
+		LastRun:            schedule.LastRun,
+		NextRun:            schedule.NextRun,
+		LastTaskID:         schedule.LastTaskID,
+		History:            history,
+	}
+}
+
+func NewSchedules(schedules []*entity.Schedule) []*Schedule {
+	views := make([]*Schedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		views = append(views, NewSchedule(schedule))
+	}
+	return views
+}
@@ -0,0 +1,81 @@
+package views
+
+import (
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+var taskStatuses = map[entity.TaskStatus]string{
+	entity.TaskCreated:   "created",
+	entity.TaskCompleted: "completed",
+	entity.TaskFailed:    "failed",
+	entity.TaskSkipped:   "skipped",
+}
+
+var taskTypes = map[entity.TaskType]string{
+	entity.Replication: "replication",
+	entity.Sync:        "sync",
+	entity.Mirror:      "mirror",
+	entity.Move:        "move",
+}
+
+type Task struct {
+	ID       string         `json:"id"`
+	TenantID string         `json:"tenant_id,omitempty"`
+	ParentID string         `json:"parent_id,omitempty"`
+	Type     string         `json:"type"`
+	Status   string         `json:"status"`
+	Start    time.Time      `json:"start"`
+	End      time.Time      `json:"end"`
+	Progress *TaskProgress  `json:"progress,omitempty"`
+	Targets  []TargetStatus `json:"targets,omitempty"`
+}
+
+type TargetStatus struct {
+	Storage  Storage `json:"storage"`
+	ObjectID string  `json:"object_id"`
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type TaskProgress struct {
+	Total   int `json:"total"`
+	Copied  int `json:"copied"`
+	Skipped int `json:"skipped"`
+	Deleted int `json:"deleted"`
+	Failed  int `json:"failed"`
+}
+
+func NewTask(task *entity.Task) *Task {
+	view := &Task{
+		ID:       task.ID,
+		TenantID: task.TenantID,
+		ParentID: task.ParentID,
+		Type:     taskTypes[task.Type],
+		Status:   taskStatuses[task.Status],
+		Start:    task.Start,
+		End:      task.End,
+	}
+	if task.Type != entity.Replication {
+		progress := TaskProgress(task.Progress)
+		view.Progress = &progress
+	}
+	for _, target := range task.Targets {
+		view.Targets = append(view.Targets, TargetStatus{
+			Storage:  Storage(target.Storage),
+			ObjectID: target.ObjectID,
+			Status:   taskStatuses[target.Status],
+			Error:    target.Error,
+		})
+	}
+	return view
+}
+
+func NewTasks(tasks []*entity.Task) []*Task {
+	views := make([]*Task, 0, len(tasks))
+	for _, task := range tasks {
+		views = append(views, NewTask(task))
+	}
+	return views
+}
@@ -0,0 +1,10 @@
+package views
+
+import "time"
+
+type UploadToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// UploadURL creates the upload with the token, for tus clients without custom headers.
+	UploadURL string `json:"upload_url"`
+}
@@ -2,25 +2,113 @@ package mongo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/inview-team/gorynych/internal/domain/entity"
 	"github.com/inview-team/gorynych/internal/infrastructure/mongo/model"
+	"github.com/inview-team/gorynych/internal/infrastructure/secrets"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
+// AccountRepository encrypts the keys of the accounts with the keyring, if it has a
+// master key, and decrypts them transparently.
 type AccountRepository struct {
-	coll *mongo.Collection
+	coll    *mongo.Collection
+	keyring *secrets.Keyring
 }
 
-func NewAccountRepository(client *Client) *AccountRepository {
+func NewAccountRepository(client *Client, keyring *secrets.Keyring) *AccountRepository {
 	return &AccountRepository{
-		coll: client.Database.Collection("accounts"),
+		coll:    client.Database.Collection("accounts"),
+		keyring: keyring,
 	}
 }
 
+// CheckKeys makes sure every master key used by the stored accounts is configured.
+func (r *AccountRepository) CheckKeys(ctx context.Context) error {
+	for _, field := range []string{"sealed_access_key.key_id", "sealed_secret.key_id"} {
+		var keyIDs []string
+		err := r.coll.Distinct(ctx, field, bson.M{}).Decode(&keyIDs)
+		if err != nil {
+			return err
+		}
+
+		for _, keyID := range keyIDs {
+			if !r.keyring.Has(keyID) {
+				return fmt.Errorf("accounts are encrypted with master key %s, which is not configured", keyID)
+			}
+		}
+	}
+	return nil
+}
+
+// Rotate encrypts the keys of all accounts with the current master key, including
+// accounts stored in plaintext. It returns the number of accounts written.
+func (r *AccountRepository) Rotate(ctx context.Context) (int, error) {
+	if !r.keyring.Enabled() {
+		return 0, secrets.ErrNoKey
+	}
+
+	accounts, err := r.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, account := range accounts {
+		if err := r.Update(ctx, account); err != nil {
+			return i, fmt.Errorf("failed to re-encrypt account %s: %w", account.ID, err)
+		}
+	}
+	return len(accounts), nil
+}
+
+func (r *AccountRepository) seal(mAccount *model.Account) error {
+	if !r.keyring.Enabled() {
+		return nil
+	}
+
+	accessKey, err := r.keyring.Seal([]byte(mAccount.AccessKey), []byte(mAccount.ID+"/access_key"))
+	if err != nil {
+		return err
+	}
+	secret, err := r.keyring.Seal([]byte(mAccount.Secret), []byte(mAccount.ID+"/secret"))
+	if err != nil {
+		return err
+	}
+
+	mAccount.SealedAccessKey = (*model.SealedValue)(accessKey)
+	mAccount.SealedSecret = (*model.SealedValue)(secret)
+	mAccount.AccessKey = ""
+	mAccount.Secret = ""
+	return nil
+}
+
+func (r *AccountRepository) toEntity(mAccount *model.Account) (*entity.ServiceAccount, error) {
+	if mAccount.SealedAccessKey != nil {
+		accessKey, err := r.keyring.Open((*secrets.Sealed)(mAccount.SealedAccessKey), []byte(mAccount.ID+"/access_key"))
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", mAccount.ID, err)
+		}
+		mAccount.AccessKey = string(accessKey)
+	}
+
+	if mAccount.SealedSecret != nil {
+		secret, err := r.keyring.Open((*secrets.Sealed)(mAccount.SealedSecret), []byte(mAccount.ID+"/secret"))
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", mAccount.ID, err)
+		}
+		mAccount.Secret = string(secret)
+	}
+	return mAccount.ToEntity(), nil
+}
+
 func (r *AccountRepository) Add(ctx context.Context, account *entity.ServiceAccount) error {
 	mAccount := model.NewAccount(account)
+	if err := r.seal(mAccount); err != nil {
+		return err
+	}
 	_, err := r.coll.InsertOne(ctx, mAccount)
 	if err != nil {
 		return err
@@ -30,19 +118,22 @@ func (r *AccountRepository) Add(ctx context.Context, account *entity.ServiceAcco
 
 func (r *AccountRepository) GetByID(ctx context.Context, accountID string) (*entity.ServiceAccount, error) {
 
-	result := r.coll.FindOne(ctx, bson.M{"_id": accountID})
+	result := r.coll.FindOne(ctx, scoped(ctx, bson.M{"_id": accountID}))
 
 	var mAccount model.Account
 	err := result.Decode(&mAccount)
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
-	return mAccount.ToEntity(), nil
+	return r.toEntity(&mAccount)
 }
 
 func (r *AccountRepository) ListByProvider(ctx context.Context, provider string) ([]*entity.ServiceAccount, error) {
-	cursor, err := r.coll.Find(ctx, bson.M{"provider_id": provider})
+	cursor, err := r.coll.Find(ctx, scoped(ctx, bson.M{"provider_id": provider}))
 	if err != nil {
 		return nil, err
 	}
@@ -54,13 +145,17 @@ func (r *AccountRepository) ListByProvider(ctx context.Context, provider string)
 		if err := cursor.Decode(&mAccount); err != nil {
 			return nil, err
 		}
-		accounts = append(accounts, mAccount.ToEntity())
+		account, err := r.toEntity(&mAccount)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
 	}
 	return accounts, nil
 }
 
 func (r *AccountRepository) List(ctx context.Context) ([]*entity.ServiceAccount, error) {
-	cursor, err := r.coll.Find(ctx, bson.M{})
+	cursor, err := r.coll.Find(ctx, scoped(ctx, bson.M{}))
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +167,33 @@ func (r *AccountRepository) List(ctx context.Context) ([]*entity.ServiceAccount,
 		if err := cursor.Decode(&mAccount); err != nil {
 			return nil, err
 		}
-		accounts = append(accounts, mAccount.ToEntity())
+		account, err := r.toEntity(&mAccount)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
 	}
 	return accounts, nil
 }
+
+func (r *AccountRepository) Update(ctx context.Context, account *entity.ServiceAccount) error {
+	mAccount := model.NewAccount(account)
+	if err := r.seal(mAccount); err != nil {
+		return err
+	}
+	// The document is replaced so plaintext keys do not survive encryption
+	_, err := r.coll.ReplaceOne(ctx, scoped(ctx, bson.M{"_id": account.ID}), mAccount)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AccountRepository) Delete(ctx context.Context, accountID string) error {
+	_, err := r.coll.DeleteOne(ctx, scoped(ctx, bson.M{"_id": accountID}))
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"github.com/inview-team/gorynych/internal/infrastructure/mongo/model"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// AuditRepository stores the audit trail. Events are never updated nor deleted.
+type AuditRepository struct {
+	coll *mongo.Collection
+}
+
+func NewAuditRepository(client *Client) *AuditRepository {
+	return &AuditRepository{
+		coll: client.Database.Collection("audit"),
+	}
+}
+
+func (r *AuditRepository) Add(ctx context.Context, event *entity.AuditEvent) error {
+	mEvent := model.NewAuditEvent(event)
+	_, err := r.coll.InsertOne(ctx, mEvent)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AuditRepository) List(ctx context.Context, filter entity.AuditFilter) ([]*entity.AuditEvent, error) {
+	query := bson.M{}
+	if filter.Actor != "" {
+		query["actor"] = filter.Actor
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.Target != "" {
+		query["target"] = filter.Target
+	}
+	period := bson.M{}
+	if !filter.Since.IsZero() {
+		period["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		period["$lt"] = filter.Until
+	}
+	if len(period) > 0 {
+		query["time"] = period
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "time", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	cursor, err := r.coll.Find(ctx, scoped(ctx, query), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []*entity.AuditEvent
+	for cursor.Next(ctx) {
+		var mEvent model.AuditEvent
+		if err := cursor.Decode(&mEvent); err != nil {
+			return nil, err
+		}
+		events = append(events, mEvent.ToEntity())
+	}
+	return events, nil
+}
@@ -14,6 +14,11 @@ type Client struct {
 	Database *mongo.Database
 }
 
+// Ping checks that the primary of the database answers.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.Database.Client().Ping(ctx, readpref.Primary())
+}
+
 type Config struct {
 	Host     string `yaml:"host,omitempty"`
 	Port     int    `yaml:"port,omitempty"`
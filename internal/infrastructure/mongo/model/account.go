@@ -1,31 +1,72 @@
 package model
 
-import "github.com/inview-team/gorynych/internal/domain/entity"
+import (
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
 
 type Account struct {
 	ID         string `bson:"_id"`
+	TenantID   string `bson:"tenant_id,omitempty"`
 	ProviderID string `bson:"provider_id"`
 	Region     string `bson:"region"`
-	AccessKey  string `bson:"access_key"`
-	Secret     string `bson:"secret"`
+	AccessKey  string `bson:"access_key,omitempty"`
+	Secret     string `bson:"secret,omitempty"`
+	// Sealed keys replace the plaintext ones when a master key is configured
+	SealedAccessKey *SealedValue   `bson:"sealed_access_key,omitempty"`
+	SealedSecret    *SealedValue   `bson:"sealed_secret,omitempty"`
+	Disabled        bool           `bson:"disabled"`
+	Buckets         []BucketAccess `bson:"buckets"`
+	VerifiedAt      time.Time      `bson:"verified_at"`
+}
+
+type SealedValue struct {
+	KeyID   string `bson:"key_id"`
+	DataKey []byte `bson:"data_key"`
+	Data    []byte `bson:"data"`
+}
+
+type BucketAccess struct {
+	Bucket string `bson:"bucket"`
+	Read   bool   `bson:"read"`
+	Write  bool   `bson:"write"`
 }
 
 func NewAccount(account *entity.ServiceAccount) *Account {
+	buckets := make([]BucketAccess, 0, len(account.Buckets))
+	for _, access := range account.Buckets {
+		buckets = append(buckets, BucketAccess(access))
+	}
+
 	return &Account{
 		ID:         account.ID,
+		TenantID:   account.TenantID,
 		ProviderID: account.ProviderID,
 		Region:     account.Region,
 		AccessKey:  account.AccessKey,
 		Secret:     account.Secret,
+		Disabled:   account.Disabled,
+		Buckets:    buckets,
+		VerifiedAt: account.VerifiedAt,
 	}
 }
 
 func (m *Account) ToEntity() *entity.ServiceAccount {
+	var buckets []entity.BucketAccess
+	for _, access := range m.Buckets {
+		buckets = append(buckets, entity.BucketAccess(access))
+	}
+
 	return &entity.ServiceAccount{
 		ID:         m.ID,
+		TenantID:   m.TenantID,
 		ProviderID: m.ProviderID,
 		Region:     m.Region,
 		AccessKey:  m.AccessKey,
 		Secret:     m.Secret,
+		Disabled:   m.Disabled,
+		Buckets:    buckets,
+		VerifiedAt: m.VerifiedAt,
 	}
 }
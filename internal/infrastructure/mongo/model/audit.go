@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+type AuditEvent struct {
+	ID        string            `bson:"_id"`
+	TenantID  string            `bson:"tenant_id,omitempty"`
+	Time      time.Time         `bson:"time"`
+	Actor     string            `bson:"actor"`
+	Action    string            `bson:"action"`
+	Target    string            `bson:"target"`
+	RequestID string            `bson:"request_id,omitempty"`
+	SourceIP  string            `bson:"source_ip,omitempty"`
+	Outcome   int               `bson:"outcome"`
+	Error     string            `bson:"error,omitempty"`
+	Details   map[string]string `bson:"details,omitempty"`
+}
+
+func NewAuditEvent(event *entity.AuditEvent) *AuditEvent {
+	return &AuditEvent{
+		ID:        event.ID,
+		TenantID:  event.TenantID,
+		Time:      event.Time,
+		Actor:     event.Actor,
+		Action:    event.Action,
+		Target:    event.Target,
+		RequestID: event.RequestID,
+		SourceIP:  event.SourceIP,
+		Outcome:   int(event.Outcome),
+		Error:     event.Error,
+		Details:   event.Details,
+	}
+}
+
+func (m *AuditEvent) ToEntity() *entity.AuditEvent {
+	return &entity.AuditEvent{
+		ID:        m.ID,
+		TenantID:  m.TenantID,
+		Time:      m.Time,
+		Actor:     m.Actor,
+		Action:    m.Action,
+		Target:    m.Target,
+		RequestID: m.RequestID,
+		SourceIP:  m.SourceIP,
+		Outcome:   entity.AuditOutcome(m.Outcome),
+		Error:     m.Error,
+		Details:   m.Details,
+	}
+}
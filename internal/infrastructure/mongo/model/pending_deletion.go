@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+type PendingDeletion struct {
+	ID       string    `bson:"_id"`
+	TaskID   string    `bson:"task_id"`
+	Storage  Storage   `bson:"storage"`
+	ObjectID string    `bson:"object_id"`
+	DeleteAt time.Time `bson:"delete_at"`
+}
+
+func NewPendingDeletion(deletion *entity.PendingDeletion) *PendingDeletion {
+	return &PendingDeletion{
+		ID:       deletion.ID,
+		TaskID:   deletion.TaskID,
+		Storage:  Storage(deletion.Storage),
+		ObjectID: deletion.ObjectID,
+		DeleteAt: deletion.DeleteAt,
+	}
+}
+
+func (m *PendingDeletion) ToEntity() *entity.PendingDeletion {
+	return &entity.PendingDeletion{
+		ID:       m.ID,
+		TaskID:   m.TaskID,
+		Storage:  entity.Storage(m.Storage),
+		ObjectID: m.ObjectID,
+		DeleteAt: m.DeleteAt,
+	}
+}
@@ -0,0 +1,53 @@
+package model
+
+import "github.com/inview-team/gorynych/internal/domain/entity"
+
+type Policy struct {
+	ID       string            `bson:"_id"`
+	TenantID string            `bson:"tenant_id,omitempty"`
+	Name     string            `bson:"name"`
+	Enabled  bool              `bson:"enabled"`
+	Source   Storage           `bson:"source"`
+	Targets  []Storage         `bson:"targets"`
+	Metadata map[string]string `bson:"metadata,omitempty"`
+	MinSize  int64             `bson:"min_size"`
+	MaxSize  int64             `bson:"max_size"`
+}
+
+func NewPolicy(policy *entity.ReplicationPolicy) *Policy {
+	var targets []Storage
+	for _, target := range policy.Targets {
+		targets = append(targets, Storage(target))
+	}
+
+	return &Policy{
+		ID:       policy.ID,
+		TenantID: policy.TenantID,
+		Name:     policy.Name,
+		Enabled:  policy.Enabled,
+		Source:   Storage(policy.Source),
+		Targets:  targets,
+		Metadata: policy.Metadata,
+		MinSize:  policy.MinSize,
+		MaxSize:  policy.MaxSize,
+	}
+}
+
+func (m *Policy) ToEntity() *entity.ReplicationPolicy {
+	var targets []entity.Storage
+	for _, target := range m.Targets {
+		targets = append(targets, entity.Storage(target))
+	}
+
+	return &entity.ReplicationPolicy{
+		ID:       m.ID,
+		TenantID: m.TenantID,
+		Name:     m.Name,
+		Enabled:  m.Enabled,
+		Source:   entity.Storage(m.Source),
+		Targets:  targets,
+		Metadata: m.Metadata,
+		MinSize:  m.MinSize,
+		MaxSize:  m.MaxSize,
+	}
+}
@@ -3,28 +3,65 @@ package model
 import "github.com/inview-team/gorynych/internal/domain/entity"
 
 type Provider struct {
-	ID       string `bson:"_id"`
-	Name     string `bson:"name"`
-	Endpoint string `bson:"endpoint"`
+	ID               string `bson:"_id"`
+	Name             string `bson:"name"`
+	Endpoint         string `bson:"endpoint"`
+	Region           string `bson:"region,omitempty"`
+	PathStyle        bool   `bson:"path_style"`
+	SkipTLSVerify    bool   `bson:"skip_tls_verify"`
+	SignatureVersion string `bson:"signature_version,omitempty"`
+	// Encryption is the default server side encryption of the provider
+	Encryption *ServerSideEncryption `bson:"encryption,omitempty"`
 }
 
-var Providers = []Provider{
-	{
-		ID:       "1",
-		Name:     "Yandex Cloud",
-		Endpoint: "https://storage.yandexcloud.net",
-	},
-	{
-		ID:       "2",
-		Name:     "Timeweb",
-		Endpoint: "https://s3.twcstorage.ru",
-	},
+// ServerSideEncryption never holds SSE-C keys, which aren't stored
+type ServerSideEncryption struct {
+	Mode     int    `bson:"mode"`
+	KMSKeyID string `bson:"kms_key_id,omitempty"`
+}
+
+func NewServerSideEncryption(sse *entity.ServerSideEncryption) *ServerSideEncryption {
+	if sse == nil {
+		return nil
+	}
+	return &ServerSideEncryption{Mode: int(sse.Mode), KMSKeyID: sse.KMSKeyID}
+}
+
+func (m *ServerSideEncryption) ToEntity() *entity.ServerSideEncryption {
+	if m == nil {
+		return nil
+	}
+	return &entity.ServerSideEncryption{Mode: entity.SSEMode(m.Mode), KMSKeyID: m.KMSKeyID}
+}
+
+func NewProvider(provider *entity.Provider) *Provider {
+	return &Provider{
+		ID:               provider.ID,
+		Name:             provider.Name,
+		Endpoint:         provider.Endpoint,
+		Region:           provider.Region,
+		PathStyle:        provider.PathStyle,
+		SkipTLSVerify:    provider.SkipTLSVerify,
+		SignatureVersion: string(provider.SignatureVersion),
+		Encryption:       NewServerSideEncryption(provider.Encryption),
+	}
 }
 
 func (m *Provider) ToEntity() *entity.Provider {
+	signature := entity.SignatureVersion(m.SignatureVersion)
+	// Providers seeded before signature versions existed
+	if signature == "" {
+		signature = entity.SignatureV4
+	}
+
 	return &entity.Provider{
-		ID:       m.ID,
-		Name:     m.Name,
-		Endpoint: m.Endpoint,
+		ID:               m.ID,
+		Name:             m.Name,
+		Endpoint:         m.Endpoint,
+		Region:           m.Region,
+		PathStyle:        m.PathStyle,
+		SkipTLSVerify:    m.SkipTLSVerify,
+		SignatureVersion: signature,
+		Encryption:       m.Encryption.ToEntity(),
 	}
 }
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+)
+
+type Schedule struct {
+	ID            string   `bson:"_id"`
+	TenantID      string   `bson:"tenant_id,omitempty"`
+	Cron          string   `bson:"cron"`
+	Mode          int      `bson:"mode"`
+	SourceStorage Storage  `bson:"source_storage"`
+	TargetStorage Storage  `bson:"target_storage"`
+	ObjectID      string   `bson:"object_id,omitempty"`
+	Prefix        string   `bson:"prefix,omitempty"`
+	Include       []string `bson:"include,omitempty"`
+	Exclude       []string `bson:"exclude,omitempty"`
+	Delete        bool     `bson:"delete"`
+	// Replication holds the options of replicated objects. Schedules created before
+	// it was stored have none.
+	Replication *ReplicationOptions `bson:"replication,omitempty"`
+	LastRun     time.Time           `bson:"last_run"`
+	NextRun     time.Time           `bson:"next_run"`
+	LastTaskID  string              `bson:"last_task_id,omitempty"`
+	History     []ScheduleRun       `bson:"history"`
+}
+
+// ReplicationOptions never hold SSE-C keys, schedules cannot use SSE-C.
+type ReplicationOptions struct {
+	MaxBandwidth      int64                 `bson:"max_bandwidth,omitempty"`
+	MetadataDirective int                   `bson:"metadata_directive,omitempty"`
+	Attributes        ObjectAttributes      `bson:"attributes"`
+	TargetKey         string                `bson:"target_key,omitempty"`
+	PrefixRewrite     *PrefixRewrite        `bson:"prefix_rewrite,omitempty"`
+	ConflictPolicy    int                   `bson:"conflict_policy,omitempty"`
+	TargetEncryption  *ServerSideEncryption `bson:"target_encryption,omitempty"`
+}
+
+type ObjectAttributes struct {
+	ContentType        string            `bson:"content_type,omitempty"`
+	CacheControl       string            `bson:"cache_control,omitempty"`
+	ContentEncoding    string            `bson:"content_encoding,omitempty"`
+	ContentDisposition string            `bson:"content_disposition,omitempty"`
+	ContentLanguage    string            `bson:"content_language,omitempty"`
+	Metadata           map[string]string `bson:"metadata,omitempty"`
+	Tags               map[string]string `bson:"tags,omitempty"`
+}
+
+type PrefixRewrite struct {
+	From string `bson:"from"`
+	To   string `bson:"to"`
+}
+
+func NewReplicationOptions(opts entity.ReplicationOptions) *ReplicationOptions {
+	m := &ReplicationOptions{
+		MaxBandwidth:      opts.MaxBandwidth,
+		MetadataDirective: int(opts.MetadataDirective),
+		Attributes:        ObjectAttributes(opts.Attributes),
+		TargetKey:         opts.TargetKey,
+		ConflictPolicy:    int(opts.ConflictPolicy),
+		TargetEncryption:  NewServerSideEncryption(opts.TargetEncryption),
+	}
+	if opts.PrefixRewrite != nil {
+		m.PrefixRewrite = &PrefixRewrite{From: opts.PrefixRewrite.From, To: opts.PrefixRewrite.To}
+	}
+	return m
+}
+
+func (m *ReplicationOptions) ToEntity() entity.ReplicationOptions {
+	if m == nil {
+		return entity.ReplicationOptions{}
+	}
+	opts := entity.ReplicationOptions{
+		MaxBandwidth:      m.MaxBandwidth,
+		MetadataDirective: entity.MetadataDirective(m.MetadataDirective),
+		Attributes:        entity.ObjectAttributes(m.Attributes),
+		TargetKey:         m.TargetKey,
+		ConflictPolicy:    entity.ConflictPolicy(m.ConflictPolicy),
+		TargetEncryption:  m.TargetEncryption.ToEntity(),
+	}
+	if m.PrefixRewrite != nil {
+		opts.PrefixRewrite = &entity.PrefixRewrite{From: m.PrefixRewrite.From, To: m.PrefixRewrite.To}
+	}
+	return opts
+}
+
+type ScheduleRun struct {
+	Time   time.Time `bson:"time"`
+	TaskID string    `bson:"task_id,omitempty"`
+	Status int       `bson:"status"`
+	Error  string    `bson:"error,omitempty"`
+}
+
+func NewSchedule(schedule *entity.Schedule) *Schedule {
+	var history []ScheduleRun
+	for _, run := range schedule.History {
+		history = append(history, ScheduleRun{Time: run.Time, TaskID: run.TaskID, Status: int(run.Status), Error: run.Error})
+	}
+
+	return &Schedule{
+		ID:            schedule.ID,
+		TenantID:      schedule.TenantID,
+		Cron:          schedule.Cron,
+		Mode:          int(schedule.Mode),
+		SourceStorage: Storage(schedule.SourceStorage),
+		TargetStorage: Storage(schedule.TargetStorage),
+		ObjectID:      schedule.ObjectID,
+		Prefix:        schedule.Options.Prefix,
+		Include:       schedule.Options.Include,
+		Exclude:       schedule.Options.Exclude,
+		Delete:        schedule.Options.Delete,
+		Replication:   NewReplicationOptions(schedule.Options.Replication),
+		LastRun:       schedule.LastRun,
+		NextRun:       schedule.NextRun,
+		LastTaskID:    schedule.LastTaskID,
+		History:       history,
+	}
+}
+
+func (m *Schedule) ToEntity() *entity.Schedule {
+	var history []entity.ScheduleRun
+	for _, run := range m.History {
+		history = append(history, entity.ScheduleRun{Time: run.Time, TaskID: run.TaskID, Status: entity.TaskStatus(run.Status), Error: run.Error})
+	}
+
+	return &entity.Schedule{
+		ID:            m.ID,
+		TenantID:      m.TenantID,
+		Cron:          m.Cron,
+		Mode:          entity.ScheduleMode(m.Mode),
+		SourceStorage: entity.Storage(m.SourceStorage),
+		TargetStorage: entity.Storage(m.TargetStorage),
+		ObjectID:      m.ObjectID,
+		Options: entity.SyncOptions{
+			Prefix:      m.Prefix,
+			Include:     m.Include,
+			Exclude:     m.Exclude,
+			Delete:      m.Delete,
+			Replication: m.Replication.ToEntity(),
+		},
+		LastRun:    m.LastRun,
+		NextRun:    m.NextRun,
+		LastTaskID: m.LastTaskID,
+		History:    history,
+	}
+}
@@ -11,31 +11,84 @@ const (
 )
 
 type Task struct {
-	ID     string `bson:"_id"`
-	Start  string `bson:"start"`
-	End    string `bson:"end"`
-	Type   int    `bson:"type"`
-	Status int    `bson:"status"`
+	ID       string         `bson:"_id"`
+	TenantID string         `bson:"tenant_id,omitempty"`
+	ParentID string         `bson:"parent_id,omitempty"`
+	Start    string         `bson:"start"`
+	End      string         `bson:"end"`
+	Type     int            `bson:"type"`
+	Status   int            `bson:"status"`
+	Progress TaskProgress   `bson:"progress"`
+	Targets  []TargetStatus `bson:"targets,omitempty"`
+	// UpdatedAt is refreshed while the task is in progress, so tasks left behind by a
+	// stopped instance can be told apart.
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+type TargetStatus struct {
+	Storage  Storage `bson:"storage"`
+	ObjectID string  `bson:"object_id"`
+	Status   int     `bson:"status"`
+	Error    string  `bson:"error,omitempty"`
+}
+
+type TaskProgress struct {
+	Total   int `bson:"total"`
+	Copied  int `bson:"copied"`
+	Skipped int `bson:"skipped"`
+	Deleted int `bson:"deleted"`
+	Failed  int `bson:"failed"`
+}
+
+// FormatTime formats a time as the start and end of tasks are stored.
+func FormatTime(t time.Time) string {
+	return t.Format(layout)
 }
 
 func NewTask(task *entity.Task) *Task {
+	var targets []TargetStatus
+	for _, target := range task.Targets {
+		targets = append(targets, TargetStatus{
+			Storage:  Storage(target.Storage),
+			ObjectID: target.ObjectID,
+			Status:   int(target.Status),
+			Error:    target.Error,
+		})
+	}
 	return &Task{
-		ID:     task.ID,
-		Start:  task.Start.String(),
-		End:    task.End.String(),
-		Type:   int(task.Type),
-		Status: int(task.Status),
+		ID:       task.ID,
+		TenantID: task.TenantID,
+		ParentID: task.ParentID,
+		Start:    task.Start.Format(layout),
+		End:      task.End.Format(layout),
+		Type:     int(task.Type),
+		Status:   int(task.Status),
+		Progress: TaskProgress(task.Progress),
+		Targets:  targets,
 	}
 }
 
 func (m *Task) ToEntity() *entity.Task {
 	start, _ := time.Parse(layout, m.Start)
 	end, _ := time.Parse(layout, m.End)
+	var targets []entity.TargetStatus
+	for _, target := range m.Targets {
+		targets = append(targets, entity.TargetStatus{
+			Storage:  entity.Storage(target.Storage),
+			ObjectID: target.ObjectID,
+			Status:   entity.TaskStatus(target.Status),
+			Error:    target.Error,
+		})
+	}
 	return &entity.Task{
-		ID:     m.ID,
-		Start:  start,
-		End:    end,
-		Type:   entity.TaskType(m.Type),
-		Status: entity.TaskStatus(m.Status),
+		ID:       m.ID,
+		TenantID: m.TenantID,
+		ParentID: m.ParentID,
+		Start:    start,
+		End:      end,
+		Type:     entity.TaskType(m.Type),
+		Status:   entity.TaskStatus(m.Status),
+		Progress: entity.TaskProgress(m.Progress),
+		Targets:  targets,
 	}
 }
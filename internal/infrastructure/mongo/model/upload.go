@@ -1,17 +1,46 @@
 package model
 
 import (
+	"time"
+
 	"github.com/inview-team/gorynych/internal/domain/entity"
 )
 
 type Upload struct {
-	ID       string       `bson:"_id"`
-	ObjectID string       `bson:"object_id"`
-	Size     int64        `bson:"size"`
-	Offset   int64        `bson:"offset"`
+	ID       string  `bson:"_id"`
+	TenantID string  `bson:"tenant_id,omitempty"`
+	Owner    string  `bson:"owner,omitempty"`
+	ObjectID string  `bson:"object_id"`
+	Size     int64   `bson:"size"`
+	Offset   int64   `bson:"offset"`
+	Storage  Storage `bson:"storage"`
+	// StorageUploadID is set once a replica took over from the storage
+	StorageUploadID string            `bson:"storage_upload_id,omitempty"`
+	Parts           []UploadPart      `bson:"parts"`
+	Status          int               `bson:"status"`
+	Metadata        map[string]string `bson:"metadata,omitempty"`
+	Replicas        []UploadReplica   `bson:"replicas,omitempty"`
+	Quorum          int               `bson:"quorum,omitempty"`
+	// Encryption holds the wrapped data key of encrypted uploads
+	Encryption *UploadEncryption `bson:"encryption,omitempty"`
+	// ServerSideEncryption is the one requested on creation
+	ServerSideEncryption *ServerSideEncryption `bson:"server_side_encryption,omitempty"`
+	GrantID              string                `bson:"grant_id,omitempty"`
+	// UpdatedAt is the time the upload was last written to
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+type UploadEncryption struct {
+	KeyID   string `bson:"key_id"`
+	DataKey []byte `bson:"data_key"`
+	Pending []byte `bson:"pending,omitempty"`
+}
+
+type UploadReplica struct {
 	Storage  Storage      `bson:"storage"`
+	UploadID string       `bson:"upload_id"`
 	Parts    []UploadPart `bson:"parts"`
-	Status   int          `bson:"status"`
+	Failed   bool         `bson:"failed"`
 }
 
 type Storage struct {
@@ -30,8 +59,24 @@ func NewUpload(upload *entity.Upload) *Upload {
 		parts = append(parts, UploadPart{ID: part.ID, Position: part.Position})
 	}
 
+	var replicas []UploadReplica
+	for _, replica := range upload.Replicas {
+		var replicaParts []UploadPart
+		for _, part := range replica.Parts {
+			replicaParts = append(replicaParts, UploadPart{ID: part.ID, Position: part.Position})
+		}
+		replicas = append(replicas, UploadReplica{
+			Storage:  Storage(replica.Storage),
+			UploadID: replica.UploadID,
+			Parts:    replicaParts,
+			Failed:   replica.Failed,
+		})
+	}
+
 	return &Upload{
 		ID:       upload.ID,
+		TenantID: upload.TenantID,
+		Owner:    upload.Owner,
 		ObjectID: upload.ObjectID,
 		Size:     upload.Size,
 		Offset:   upload.Offset,
@@ -39,8 +84,15 @@ func NewUpload(upload *entity.Upload) *Upload {
 			ProviderID: upload.Storage.ProviderID,
 			Bucket:     upload.Storage.Bucket,
 		},
-		Parts:  parts,
-		Status: int(upload.Status),
+		StorageUploadID:      upload.StorageUploadID,
+		Parts:                parts,
+		Status:               int(upload.Status),
+		Metadata:             upload.Metadata,
+		Replicas:             replicas,
+		Quorum:               upload.Quorum,
+		Encryption:           (*UploadEncryption)(upload.Encryption),
+		ServerSideEncryption: NewServerSideEncryption(upload.ServerSideEncryption),
+		GrantID:              upload.GrantID,
 	}
 }
 
@@ -50,9 +102,25 @@ func (m *Upload) ToEntity() *entity.Upload {
 		parts = append(parts, entity.UploadPart{ID: part.ID, Position: part.Position})
 	}
 
+	var replicas []entity.UploadReplica
+	for _, replica := range m.Replicas {
+		var replicaParts []entity.UploadPart
+		for _, part := range replica.Parts {
+			replicaParts = append(replicaParts, entity.UploadPart{ID: part.ID, Position: part.Position})
+		}
+		replicas = append(replicas, entity.UploadReplica{
+			Storage:  entity.Storage(replica.Storage),
+			UploadID: replica.UploadID,
+			Parts:    replicaParts,
+			Failed:   replica.Failed,
+		})
+	}
+
 	status := entity.UploadStatus(m.Status)
 	return &entity.Upload{
 		ID:       m.ID,
+		TenantID: m.TenantID,
+		Owner:    m.Owner,
 		ObjectID: m.ObjectID,
 		Size:     m.Size,
 		Offset:   m.Offset,
@@ -60,7 +128,14 @@ func (m *Upload) ToEntity() *entity.Upload {
 			ProviderID: m.Storage.ProviderID,
 			Bucket:     m.Storage.Bucket,
 		},
-		Parts:  parts,
-		Status: status,
+		StorageUploadID:      m.StorageUploadID,
+		Parts:                parts,
+		Status:               status,
+		Metadata:             m.Metadata,
+		Replicas:             replicas,
+		Quorum:               m.Quorum,
+		Encryption:           (*entity.UploadEncryption)(m.Encryption),
+		ServerSideEncryption: m.ServerSideEncryption.ToEntity(),
+		GrantID:              m.GrantID,
 	}
 }
@@ -0,0 +1,57 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"github.com/inview-team/gorynych/internal/infrastructure/mongo/model"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type PendingDeletionRepository struct {
+	coll *mongo.Collection
+}
+
+func NewPendingDeletionRepository(client *Client) *PendingDeletionRepository {
+	return &PendingDeletionRepository{
+		coll: client.Database.Collection("pending_deletions"),
+	}
+}
+
+func (r *PendingDeletionRepository) Add(ctx context.Context, deletion *entity.PendingDeletion) error {
+	mDeletion := model.NewPendingDeletion(deletion)
+	_, err := r.coll.InsertOne(ctx, mDeletion)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *PendingDeletionRepository) ListDue(ctx context.Context, now time.Time) ([]*entity.PendingDeletion, error) {
+	cursor, err := r.coll.Find(ctx, bson.M{"delete_at": bson.M{"$lte": now}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var deletions []*entity.PendingDeletion
+	for cursor.Next(ctx) {
+		var mDeletion model.PendingDeletion
+		if err := cursor.Decode(&mDeletion); err != nil {
+			return nil, err
+		}
+		deletions = append(deletions, mDeletion.ToEntity())
+	}
+	return deletions, nil
+}
+
+func (r *PendingDeletionRepository) Delete(ctx context.Context, deletionID string) error {
+	_, err := r.coll.DeleteOne(ctx, bson.M{"_id": deletionID})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"github.com/inview-team/gorynych/internal/infrastructure/mongo/model"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type PolicyRepository struct {
+	coll *mongo.Collection
+}
+
+func NewPolicyRepository(client *Client) *PolicyRepository {
+	return &PolicyRepository{
+		coll: client.Database.Collection("policies"),
+	}
+}
+
+func (r *PolicyRepository) Add(ctx context.Context, policy *entity.ReplicationPolicy) error {
+	mPolicy := model.NewPolicy(policy)
+	_, err := r.coll.InsertOne(ctx, mPolicy)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *PolicyRepository) GetByID(ctx context.Context, policyID string) (*entity.ReplicationPolicy, error) {
+	result := r.coll.FindOne(ctx, scoped(ctx, bson.M{"_id": policyID}))
+
+	var mPolicy model.Policy
+	err := result.Decode(&mPolicy)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return mPolicy.ToEntity(), nil
+}
+
+func (r *PolicyRepository) List(ctx context.Context) ([]*entity.ReplicationPolicy, error) {
+	cursor, err := r.coll.Find(ctx, scoped(ctx, bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var policies []*entity.ReplicationPolicy
+	for cursor.Next(ctx) {
+		var mPolicy model.Policy
+		if err := cursor.Decode(&mPolicy); err != nil {
+			return nil, err
+		}
+		policies = append(policies, mPolicy.ToEntity())
+	}
+	return policies, nil
+}
+
+func (r *PolicyRepository) Update(ctx context.Context, policy *entity.ReplicationPolicy) error {
+	mPolicy := model.NewPolicy(policy)
+	_, err := r.coll.UpdateOne(
+		ctx,
+		scoped(ctx, bson.M{
+			"_id": bson.M{"$eq": policy.ID},
+		}),
+		bson.M{"$set": mPolicy},
+	)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *PolicyRepository) Delete(ctx context.Context, policyID string) error {
+	_, err := r.coll.DeleteOne(ctx, scoped(ctx, bson.M{"_id": policyID}))
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package mongo
 
 import (
 	"context"
+	"errors"
 
 	"github.com/inview-team/gorynych/internal/domain/entity"
 	"github.com/inview-team/gorynych/internal/infrastructure/mongo/model"
@@ -13,26 +14,19 @@ type ProviderRepository struct {
 	coll *mongo.Collection
 }
 
-func NewProviderRepository(ctx context.Context, client *Client) (*ProviderRepository, error) {
-	coll := client.Database.Collection("providers")
-
-	for _, provider := range model.Providers {
-		count, err := coll.CountDocuments(ctx, bson.M{"_id": provider.ID})
-		if err != nil {
-			return nil, err
-		}
-		if count != 0 {
-			continue
-		}
-		_, err = coll.InsertOne(ctx, provider)
-		if err != nil {
-			return nil, err
-		}
+func NewProviderRepository(client *Client) *ProviderRepository {
+	return &ProviderRepository{
+		coll: client.Database.Collection("providers"),
 	}
+}
 
-	return &ProviderRepository{
-		coll: coll,
-	}, nil
+func (r *ProviderRepository) Add(ctx context.Context, provider *entity.Provider) error {
+	mProvider := model.NewProvider(provider)
+	_, err := r.coll.InsertOne(ctx, mProvider)
+	if err != nil {
+		return err
+	}
+	return nil
 }
 
 func (r *ProviderRepository) GetByID(ctx context.Context, providerID string) (*entity.Provider, error) {
@@ -42,6 +36,9 @@ func (r *ProviderRepository) GetByID(ctx context.Context, providerID string) (*e
 	var mProvider model.Provider
 	err := result.Decode(&mProvider)
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
@@ -65,3 +62,27 @@ func (r *ProviderRepository) List(ctx context.Context) ([]*entity.Provider, erro
 	}
 	return providers, nil
 }
+
+func (r *ProviderRepository) Update(ctx context.Context, provider *entity.Provider) error {
+	mProvider := model.NewProvider(provider)
+	_, err := r.coll.UpdateOne(
+		ctx,
+		bson.M{
+			"_id": bson.M{"$eq": provider.ID},
+		},
+		bson.M{"$set": mProvider},
+	)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *ProviderRepository) Delete(ctx context.Context, providerID string) error {
+	_, err := r.coll.DeleteOne(ctx, bson.M{"_id": providerID})
+	if err != nil {
+		return err
+	}
+	return nil
+}
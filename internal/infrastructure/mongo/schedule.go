@@ -0,0 +1,101 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"github.com/inview-team/gorynych/internal/infrastructure/mongo/model"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type ScheduleRepository struct {
+	coll *mongo.Collection
+}
+
+func NewScheduleRepository(client *Client) *ScheduleRepository {
+	return &ScheduleRepository{
+		coll: client.Database.Collection("schedules"),
+	}
+}
+
+func (r *ScheduleRepository) Add(ctx context.Context, schedule *entity.Schedule) error {
+	mSchedule := model.NewSchedule(schedule)
+	_, err := r.coll.InsertOne(ctx, mSchedule)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *ScheduleRepository) GetByID(ctx context.Context, scheduleID string) (*entity.Schedule, error) {
+	result := r.coll.FindOne(ctx, scoped(ctx, bson.M{"_id": scheduleID}))
+
+	var mSchedule model.Schedule
+	err := result.Decode(&mSchedule)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return mSchedule.ToEntity(), nil
+}
+
+func (r *ScheduleRepository) List(ctx context.Context) ([]*entity.Schedule, error) {
+	cursor, err := r.coll.Find(ctx, scoped(ctx, bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var schedules []*entity.Schedule
+	for cursor.Next(ctx) {
+		var mSchedule model.Schedule
+		if err := cursor.Decode(&mSchedule); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, mSchedule.ToEntity())
+	}
+	return schedules, nil
+}
+
+func (r *ScheduleRepository) Update(ctx context.Context, schedule *entity.Schedule) error {
+	mSchedule := model.NewSchedule(schedule)
+	_, err := r.coll.UpdateOne(
+		ctx,
+		scoped(ctx, bson.M{
+			"_id": bson.M{"$eq": schedule.ID},
+		}),
+		bson.M{"$set": mSchedule},
+	)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *ScheduleRepository) ClaimRun(ctx context.Context, scheduleID string, due, next time.Time) (bool, error) {
+	result, err := r.coll.UpdateOne(
+		ctx,
+		scoped(ctx, bson.M{"_id": scheduleID, "next_run": due}),
+		bson.M{"$set": bson.M{"next_run": next}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *ScheduleRepository) Delete(ctx context.Context, scheduleID string) error {
+	_, err := r.coll.DeleteOne(ctx, scoped(ctx, bson.M{"_id": scheduleID}))
+	if err != nil {
+		return err
+	}
+	return nil
+}
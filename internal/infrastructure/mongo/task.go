@@ -2,6 +2,8 @@ package mongo
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/inview-team/gorynych/internal/domain/entity"
 	"github.com/inview-team/gorynych/internal/infrastructure/mongo/model"
@@ -22,6 +24,7 @@ func NewTaskRepository(client *Client) *TaskRepository {
 
 func (r *TaskRepository) Add(ctx context.Context, Task *entity.Task) error {
 	mTask := model.NewTask(Task)
+	mTask.UpdatedAt = time.Now()
 	_, err := r.coll.InsertOne(ctx, mTask)
 	if err != nil {
 		return err
@@ -31,11 +34,14 @@ func (r *TaskRepository) Add(ctx context.Context, Task *entity.Task) error {
 
 func (r *TaskRepository) GetByID(ctx context.Context, taskID string) (*entity.Task, error) {
 
-	result := r.coll.FindOne(ctx, bson.M{"_id": taskID})
+	result := r.coll.FindOne(ctx, scoped(ctx, bson.M{"_id": taskID}))
 
 	var mTask model.Task
 	err := result.Decode(&mTask)
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
@@ -44,11 +50,12 @@ func (r *TaskRepository) GetByID(ctx context.Context, taskID string) (*entity.Ta
 
 func (r *TaskRepository) Update(ctx context.Context, Task *entity.Task) error {
 	mTask := model.NewTask(Task)
+	mTask.UpdatedAt = time.Now()
 	_, err := r.coll.UpdateOne(
 		ctx,
-		bson.M{
+		scoped(ctx, bson.M{
 			"_id": bson.M{"$eq": Task.ID},
-		},
+		}),
 		bson.M{"$set": mTask},
 	)
 
@@ -57,3 +64,55 @@ func (r *TaskRepository) Update(ctx context.Context, Task *entity.Task) error {
 	}
 	return nil
 }
+
+func (r *TaskRepository) ListByParent(ctx context.Context, parentID string) ([]*entity.Task, error) {
+	cursor, err := r.coll.Find(ctx, scoped(ctx, bson.M{"parent_id": parentID}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var tasks []*entity.Task
+	for cursor.Next(ctx) {
+		var mTask model.Task
+		if err := cursor.Decode(&mTask); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, mTask.ToEntity())
+	}
+	return tasks, nil
+}
+
+// Touch marks the tasks as still in progress.
+func (r *TaskRepository) Touch(ctx context.Context, taskIDs []string) error {
+	if len(taskIDs) == 0 {
+		return nil
+	}
+	_, err := r.coll.UpdateMany(
+		ctx,
+		bson.M{"_id": bson.M{"$in": taskIDs}},
+		bson.M{"$set": bson.M{"updated_at": time.Now()}},
+	)
+	return err
+}
+
+// FailStale fails the tasks in progress which were not touched since before, and
+// returns how many were failed.
+func (r *TaskRepository) FailStale(ctx context.Context, before time.Time) (int64, error) {
+	now := time.Now()
+	result, err := r.coll.UpdateMany(
+		ctx,
+		bson.M{
+			"status": int(entity.TaskCreated),
+			"$or": bson.A{
+				bson.M{"updated_at": bson.M{"$lt": before}},
+				bson.M{"updated_at": bson.M{"$exists": false}},
+			},
+		},
+		bson.M{"$set": bson.M{"status": int(entity.TaskFailed), "end": model.FormatTime(now), "updated_at": now}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
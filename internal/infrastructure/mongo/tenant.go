@@ -0,0 +1,16 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// scoped limits the filter to the tenant of the context, if it has one.
+func scoped(ctx context.Context, filter bson.M) bson.M {
+	if tenantID, ok := entity.TenantFromContext(ctx); ok {
+		filter["tenant_id"] = tenantID
+	}
+	return filter
+}
@@ -0,0 +1,34 @@
+package mongo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/inview-team/gorynych/internal/domain/entity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestScoped(t *testing.T) {
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		filter bson.M
+		want   bson.M
+	}{
+		{name: "tenant", ctx: entity.WithTenant(context.Background(), "acme"), filter: bson.M{"_id": "1"}, want: bson.M{"_id": "1", "tenant_id": "acme"}},
+		{name: "empty filter of a tenant", ctx: entity.WithTenant(context.Background(), "acme"), filter: bson.M{}, want: bson.M{"tenant_id": "acme"}},
+		// A filter must not widen the scope by naming another tenant
+		{name: "filter of another tenant", ctx: entity.WithTenant(context.Background(), "acme"), filter: bson.M{"tenant_id": "globex"}, want: bson.M{"tenant_id": "acme"}},
+		{name: "unscoped", ctx: entity.WithTenant(context.Background(), ""), filter: bson.M{"_id": "1"}, want: bson.M{"_id": "1"}},
+		{name: "without tenant", ctx: context.Background(), filter: bson.M{"_id": "1"}, want: bson.M{"_id": "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scoped(tt.ctx, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("scoped() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
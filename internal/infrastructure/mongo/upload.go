@@ -2,12 +2,17 @@ package mongo
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/inview-team/gorynych/internal/domain/entity"
 	"github.com/inview-team/gorynych/internal/infrastructure/mongo/model"
+	"github.com/inview-team/gorynych/internal/infrastructure/secrets"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type UploadRepository struct {
@@ -20,10 +25,52 @@ func NewUploadRepository(client *Client) *UploadRepository {
 	}
 }
 
+// CheckKeys makes sure every master key wrapping the data key of an encrypted object is
+// configured. Rotations don't re-wrap object keys, so their master keys must be kept.
+func (r *UploadRepository) CheckKeys(ctx context.Context, keyring *secrets.Keyring) error {
+	keyIDs, err := r.EncryptionKeyIDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, keyID := range keyIDs {
+		if !keyring.Has(keyID) {
+			return fmt.Errorf("objects are encrypted with master key %s, which is not configured, keep it in secrets.previous", keyID)
+		}
+	}
+	return nil
+}
+
+// EncryptionKeyIDs returns the master keys wrapping the data keys of encrypted uploads.
+func (r *UploadRepository) EncryptionKeyIDs(ctx context.Context) ([]string, error) {
+	var keyIDs []string
+	err := r.coll.Distinct(ctx, "encryption.key_id", bson.M{"encryption.key_id": bson.M{"$exists": true}}).Decode(&keyIDs)
+	if err != nil {
+		return nil, err
+	}
+	return keyIDs, nil
+}
+
+// EnsureIndexes creates the indexes uploads rely on: an upload token creates a single
+// upload, even when instances check it at the same time.
+func (r *UploadRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "grant_id", Value: 1}},
+		Options: options.Index().
+			SetName("grant_id_unique").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"grant_id": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
 func (r *UploadRepository) Add(ctx context.Context, upload *entity.Upload) error {
 	mUpload := model.NewUpload(upload)
+	mUpload.UpdatedAt = time.Now()
 	_, err := r.coll.InsertOne(ctx, mUpload)
 	if err != nil {
+		if upload.GrantID != "" && mongo.IsDuplicateKeyError(err) {
+			return entity.ErrGrantUsed
+		}
 		return err
 	}
 	return nil
@@ -31,11 +78,29 @@ func (r *UploadRepository) Add(ctx context.Context, upload *entity.Upload) error
 
 func (r *UploadRepository) GetByID(ctx context.Context, objectId string) (*entity.Upload, error) {
 
-	result := r.coll.FindOne(ctx, bson.M{"object_id": objectId})
+	result := r.coll.FindOne(ctx, scoped(ctx, bson.M{"object_id": objectId}))
 
 	var mUpload model.Upload
 	err := result.Decode(&mUpload)
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return mUpload.ToEntity(), nil
+}
+
+func (r *UploadRepository) GetByGrantID(ctx context.Context, grantID string) (*entity.Upload, error) {
+	result := r.coll.FindOne(ctx, scoped(ctx, bson.M{"grant_id": grantID}))
+
+	var mUpload model.Upload
+	err := result.Decode(&mUpload)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
@@ -44,11 +109,12 @@ func (r *UploadRepository) GetByID(ctx context.Context, objectId string) (*entit
 
 func (r *UploadRepository) Update(ctx context.Context, upload *entity.Upload) error {
 	mUpload := model.NewUpload(upload)
+	mUpload.UpdatedAt = time.Now()
 	_, err := r.coll.UpdateOne(
 		ctx,
-		bson.M{
+		scoped(ctx, bson.M{
 			"_id": bson.M{"$eq": upload.ID},
-		},
+		}),
 		bson.M{"$set": mUpload},
 	)
 
@@ -57,3 +123,98 @@ func (r *UploadRepository) Update(ctx context.Context, upload *entity.Upload) er
 	}
 	return nil
 }
+
+// Usage sums the size of active and completed uploads per storage, replicas included.
+func (r *UploadRepository) Usage(ctx context.Context) (map[entity.Storage]int64, error) {
+	filter := bson.M{"status": bson.M{"$in": bson.A{int(entity.Active), int(entity.Complete)}}}
+	opts := options.Find().SetProjection(bson.M{"size": 1, "storage": 1, "replicas": 1})
+	cursor, err := r.coll.Find(ctx, scoped(ctx, filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	usage := make(map[entity.Storage]int64)
+	for cursor.Next(ctx) {
+		var mUpload model.Upload
+		if err := cursor.Decode(&mUpload); err != nil {
+			return nil, err
+		}
+		for _, storage := range mUpload.ToEntity().Storages() {
+			usage[storage] += mUpload.Size
+		}
+	}
+	return usage, nil
+}
+
+func (r *UploadRepository) CountActiveByProvider(ctx context.Context, providerID string) (int64, error) {
+	filter := bson.M{
+		"status": int(entity.Active),
+		"$or": bson.A{
+			bson.M{"storage.provider_id": providerID},
+			bson.M{"replicas": bson.M{"$elemMatch": bson.M{"storage.provider_id": providerID, "failed": false}}},
+		},
+	}
+	return r.coll.CountDocuments(ctx, scoped(ctx, filter))
+}
+
+func (r *UploadRepository) Stats(ctx context.Context, owner string) (entity.UploadStats, error) {
+	filter := bson.M{"status": bson.M{"$in": bson.A{int(entity.Active), int(entity.Complete)}}}
+	if owner != "" {
+		filter["owner"] = owner
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": scoped(ctx, filter)},
+		bson.M{"$group": bson.M{
+			"_id":     nil,
+			"bytes":   bson.M{"$sum": "$size"},
+			"objects": bson.M{"$sum": 1},
+			"active":  bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", int(entity.Active)}}, 1, 0}}},
+		}},
+	}
+	cursor, err := r.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return entity.UploadStats{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var stats struct {
+		Bytes   int64 `bson:"bytes"`
+		Objects int64 `bson:"objects"`
+		Active  int64 `bson:"active"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&stats); err != nil {
+			return entity.UploadStats{}, err
+		}
+	}
+	return entity.UploadStats(stats), cursor.Err()
+}
+
+// ListStale returns the active uploads not written to since before, uploads which never
+// recorded it included.
+func (r *UploadRepository) ListStale(ctx context.Context, before time.Time) ([]*entity.Upload, error) {
+	filter := bson.M{
+		"status": int(entity.Active),
+		"$or": bson.A{
+			bson.M{"updated_at": bson.M{"$lt": before}},
+			bson.M{"updated_at": bson.M{"$exists": false}},
+		},
+	}
+	cursor, err := r.coll.Find(ctx, scoped(ctx, filter))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var uploads []*entity.Upload
+	for cursor.Next(ctx) {
+		var mUpload model.Upload
+		if err := cursor.Decode(&mUpload); err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, mUpload.ToEntity())
+	}
+	return uploads, cursor.Err()
+}
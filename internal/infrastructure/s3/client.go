@@ -3,12 +3,17 @@ package s3
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
@@ -20,45 +25,117 @@ import (
 )
 
 type ClientS3 struct {
-	s3Client *s3.Client
+	s3Client   *s3.Client
+	encryption *entity.ServerSideEncryption
 }
 
-func New(ctx context.Context, endpoint, region, accessKey, secret string) (*ClientS3, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithCredentialsProvider(
-		credentials.NewStaticCredentialsProvider(accessKey, secret, "")),
-	)
+// Config describes how to reach an S3 compatible storage.
+type Config struct {
+	Endpoint         string
+	Region           string
+	AccessKey        string
+	Secret           string
+	PathStyle        bool
+	SkipTLSVerify    bool
+	SignatureVersion entity.SignatureVersion
+	// Encryption applies to objects created without a server side encryption.
+	Encryption *entity.ServerSideEncryption
+}
+
+func New(ctx context.Context, c Config) (*ClientS3, error) {
+	opts := []func(*config.LoadOptions) error{
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(c.AccessKey, c.Secret, "")),
+	}
+	if c.SkipTLSVerify {
+		httpClient := awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		})
+		opts = append(opts, config.WithHTTPClient(httpClient))
+	}
 
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
 		fmt.Println("Couldn't load default configuration. Have you set up your AWS account?")
 		fmt.Println(err)
 		return nil, err
 	}
 	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(endpoint)
-		o.Region = region
+		o.BaseEndpoint = aws.String(c.Endpoint)
+		o.Region = c.Region
+		o.UsePathStyle = c.PathStyle
+		if c.SignatureVersion == entity.SignatureV4UnsignedPayload {
+			o.APIOptions = append(o.APIOptions, v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware)
+		}
 	})
 
 	return &ClientS3{
-		s3Client: client,
+		s3Client:   client,
+		encryption: c.Encryption,
 	}, nil
 }
 
-func (s *ClientS3) Create(ctx context.Context, storageID string, id string, metadata map[string]string) (string, error) {
+func (s *ClientS3) Create(ctx context.Context, storageID string, id string, attrs entity.ObjectAttributes, sse *entity.ServerSideEncryption) (string, error) {
 	input := &s3.CreateMultipartUploadInput{
-		Bucket:   aws.String(storageID),
-		Key:      aws.String(id),
-		Metadata: metadata,
+		Bucket:             aws.String(storageID),
+		Key:                aws.String(id),
+		Metadata:           attrs.Metadata,
+		ContentType:        optionalString(attrs.ContentType),
+		CacheControl:       optionalString(attrs.CacheControl),
+		ContentEncoding:    optionalString(attrs.ContentEncoding),
+		ContentDisposition: optionalString(attrs.ContentDisposition),
+		ContentLanguage:    optionalString(attrs.ContentLanguage),
+	}
+
+	if len(attrs.Tags) != 0 {
+		tagging := url.Values{}
+		for key, value := range attrs.Tags {
+			tagging.Set(key, value)
+		}
+		input.Tagging = aws.String(tagging.Encode())
+	}
+
+	if sse == nil {
+		sse = s.encryption
+	}
+	if sse != nil {
+		switch sse.Mode {
+		case entity.SSES3:
+			input.ServerSideEncryption = types.ServerSideEncryptionAes256
+		case entity.SSEKMS:
+			input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+			input.SSEKMSKeyId = optionalString(sse.KMSKeyID)
+		}
 	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = customerKey(sse)
 
 	resp, err := s.s3Client.CreateMultipartUpload(ctx, input)
 	if err != nil {
+		if isAccessDenied(err) {
+			return "", fmt.Errorf("failed to create upload: %w", entity.ErrAccessDenied)
+		}
 		return "", fmt.Errorf("failed to create upload: %v", err)
 	}
 
 	return *resp.UploadId, nil
 }
 
-func (s *ClientS3) WritePart(ctx context.Context, bucket string, uploadID string, objectID string, position int, data *[]byte) (string, error) {
+// customerKey returns the algorithm, key and key digest headers of SSE-C requests.
+func customerKey(sse *entity.ServerSideEncryption) (*string, *string, *string) {
+	if sse == nil || sse.Mode != entity.SSEC {
+		return nil, nil, nil
+	}
+	digest := md5.Sum(sse.CustomerKey)
+	return aws.String("AES256"),
+		aws.String(base64.StdEncoding.EncodeToString(sse.CustomerKey)),
+		aws.String(base64.StdEncoding.EncodeToString(digest[:]))
+}
+
+func isAccessDenied(err error) bool {
+	var responseError *awshttp.ResponseError
+	return errors.As(err, &responseError) && responseError.ResponseError.HTTPStatusCode() == http.StatusForbidden
+}
+
+func (s *ClientS3) WritePart(ctx context.Context, bucket string, uploadID string, objectID string, position int, data *[]byte, sse *entity.ServerSideEncryption) (string, error) {
 	input := &s3.UploadPartInput{
 		Bucket:     aws.String(bucket),
 		Key:        aws.String(objectID),
@@ -66,6 +143,7 @@ func (s *ClientS3) WritePart(ctx context.Context, bucket string, uploadID string
 		PartNumber: aws.Int32(int32(position)),
 		Body:       bytes.NewReader(*data),
 	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = customerKey(sse)
 
 	resp, err := s.s3Client.UploadPart(ctx, input)
 	if err != nil {
@@ -100,11 +178,28 @@ func (s *ClientS3) FinishUpload(ctx context.Context, bucket, uploadID, objectID
 	return nil
 }
 
+func (s *ClientS3) AbortUpload(ctx context.Context, bucket, uploadID, objectID string) error {
+	input := &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(objectID),
+		UploadId: aws.String(uploadID),
+	}
+
+	_, err := s.s3Client.AbortMultipartUpload(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to abort upload: %v", err)
+	}
+
+	return nil
+}
+
 func (s *ClientS3) ListBuckets(ctx context.Context) ([]string, error) {
 	result, err := s.s3Client.ListBuckets(ctx, &s3.ListBucketsInput{})
 	if err != nil {
-		fmt.Print("failed to list buckets")
-		return nil, err
+		if isAccessDenied(err) {
+			return nil, fmt.Errorf("failed to list buckets: %w", entity.ErrAccessDenied)
+		}
+		return nil, fmt.Errorf("failed to list buckets: %v", err)
 	}
 
 	var buckets []string
@@ -126,19 +221,59 @@ func (s *ClientS3) IsBucketExist(ctx context.Context, bucket string) (bool, erro
 		if errors.As(err, &responseError) && responseError.ResponseError.HTTPStatusCode() == http.StatusNotFound {
 			return false, nil
 		}
+		if isAccessDenied(err) {
+			return false, fmt.Errorf("failed to check bucket %s: %w", bucket, entity.ErrAccessDenied)
+		}
 		return false, err
 	}
 
 	return true, nil
 }
 
+// Prefix of the objects written to probe write access
+const probePrefix = ".gorynych-probe/"
+
+func (s *ClientS3) ProbeAccess(ctx context.Context, bucket string) (entity.BucketAccess, error) {
+	access := entity.BucketAccess{Bucket: bucket}
+
+	_, err := s.s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		MaxKeys: aws.Int32(1),
+	})
+	switch {
+	case err == nil:
+		access.Read = true
+	case !isAccessDenied(err):
+		return access, fmt.Errorf("failed to probe read access to %s: %v", bucket, err)
+	}
+
+	key := probePrefix + entity.NewObjectID()
+	_, err = s.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(nil),
+	})
+	switch {
+	case err == nil:
+		access.Write = true
+		if err := s.DeleteObject(ctx, bucket, key); err != nil {
+			log.Warnf("failed to delete probe object %s from %s: %v", key, bucket, err)
+		}
+	case !isAccessDenied(err):
+		return access, fmt.Errorf("failed to probe write access to %s: %v", bucket, err)
+	}
+
+	return access, nil
+}
+
 // DownloadObject implements entity.ObjectRepository.
-func (s *ClientS3) DownloadObject(ctx context.Context, bucket string, objectID string, startOffset int64, endOffset int64) (*[]byte, error) {
+func (s *ClientS3) DownloadObject(ctx context.Context, bucket string, objectID string, startOffset int64, endOffset int64, sse *entity.ServerSideEncryption) (*[]byte, error) {
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(objectID),
 		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", startOffset, endOffset)),
 	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = customerKey(sse)
 
 	output, err := s.s3Client.GetObject(ctx, input)
 	if err != nil {
@@ -155,11 +290,12 @@ func (s *ClientS3) DownloadObject(ctx context.Context, bucket string, objectID s
 }
 
 // GetObject implements entity.ObjectRepository.
-func (s *ClientS3) GetObject(ctx context.Context, bucket string, objectID string) (*entity.Object, error) {
+func (s *ClientS3) GetObject(ctx context.Context, bucket string, objectID string, sse *entity.ServerSideEncryption) (*entity.Object, error) {
 	input := &s3.HeadObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(objectID),
 	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = customerKey(sse)
 
 	output, err := s.s3Client.HeadObject(ctx, input)
 	if err != nil {
@@ -171,20 +307,122 @@ func (s *ClientS3) GetObject(ctx context.Context, bucket string, objectID string
 		return nil, err
 	}
 
+	tags, err := s.getTags(ctx, bucket, objectID)
+	if err != nil {
+		log.Warnf("failed to get tags of object %s: %v", objectID, err)
+	}
+
 	return &entity.Object{
-		ID:       entity.ObjectID(objectID),
-		Name:     objectID,
-		Size:     *output.ContentLength,
-		Metadata: output.Metadata,
+		ID:           entity.ObjectID(objectID),
+		Name:         objectID,
+		Size:         *output.ContentLength,
+		ETag:         aws.ToString(output.ETag),
+		LastModified: aws.ToTime(output.LastModified),
+		ObjectAttributes: entity.ObjectAttributes{
+			ContentType:        aws.ToString(output.ContentType),
+			CacheControl:       aws.ToString(output.CacheControl),
+			ContentEncoding:    aws.ToString(output.ContentEncoding),
+			ContentDisposition: aws.ToString(output.ContentDisposition),
+			ContentLanguage:    aws.ToString(output.ContentLanguage),
+			Metadata:           output.Metadata,
+			Tags:               tags,
+		},
 	}, nil
 }
 
-func (s *ClientS3) StreamDownloadObject(ctx context.Context, bucket string, objectID string, startOffset int64, endOffset int64) (io.ReadCloser, error) {
+func (s *ClientS3) DeleteObject(ctx context.Context, bucket string, objectID string) error {
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(objectID),
+	}
+
+	_, err := s.s3Client.DeleteObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %v", err)
+	}
+	return nil
+}
+
+// ListObjects returns all objects of the bucket with keys starting with prefix.
+// Only the listing attributes are filled: name, size, ETag and last modification time.
+func (s *ClientS3) ListObjects(ctx context.Context, bucket string, prefix string) ([]*entity.Object, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: optionalString(prefix),
+	}
+
+	var objects []*entity.Object
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %v", err)
+		}
+
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			objects = append(objects, &entity.Object{
+				ID:           entity.ObjectID(key),
+				Name:         key,
+				Size:         aws.ToInt64(object.Size),
+				ETag:         aws.ToString(object.ETag),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+// SetTags replaces the tag set of the object.
+func (s *ClientS3) SetTags(ctx context.Context, bucket string, objectID string, tags map[string]string) error {
+	tagSet := make([]types.Tag, 0, len(tags))
+	for key, value := range tags {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	input := &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(objectID),
+		Tagging: &types.Tagging{TagSet: tagSet},
+	}
+
+	_, err := s.s3Client.PutObjectTagging(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to set tags: %v", err)
+	}
+	return nil
+}
+
+func (s *ClientS3) getTags(ctx context.Context, bucket string, objectID string) (map[string]string, error) {
+	input := &s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(objectID),
+	}
+
+	output, err := s.s3Client.GetObjectTagging(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(output.TagSet) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string, len(output.TagSet))
+	for _, tag := range output.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+func (s *ClientS3) StreamDownloadObject(ctx context.Context, bucket string, objectID string, startOffset int64, endOffset int64, sse *entity.ServerSideEncryption) (io.ReadCloser, error) {
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(objectID),
 		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", startOffset, endOffset)),
 	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = customerKey(sse)
 
 	output, err := s.s3Client.GetObject(ctx, input)
 	if err != nil {
@@ -198,14 +436,16 @@ func (s *ClientS3) StreamDownloadObject(ctx context.Context, bucket string, obje
 	return output.Body, nil
 }
 
-func (s *ClientS3) StreamWritePart(ctx context.Context, bucket string, uploadID string, objectID string, position int, reader io.ReadCloser) (string, error) {
+func (s *ClientS3) StreamWritePart(ctx context.Context, bucket string, uploadID string, objectID string, position int, reader io.ReadCloser, size int64, sse *entity.ServerSideEncryption) (string, error) {
 	input := &s3.UploadPartInput{
-		Bucket:     aws.String(bucket),
-		Key:        aws.String(objectID),
-		UploadId:   aws.String(uploadID),
-		PartNumber: aws.Int32(int32(position)),
-		Body:       reader,
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(objectID),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(int32(position)),
+		Body:          reader,
+		ContentLength: aws.Int64(size),
 	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = customerKey(sse)
 
 	resp, err := s.s3Client.UploadPart(ctx, input)
 	if err != nil {
@@ -214,3 +454,10 @@ func (s *ClientS3) StreamWritePart(ctx context.Context, bucket string, uploadID
 
 	return *resp.ETag, nil
 }
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
@@ -0,0 +1,217 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	ErrNoKey      = errors.New("no master key configured")
+	ErrUnknownKey = errors.New("unknown master key")
+)
+
+// KeyConfig points to a master key. Keys are 16, 24 or 32 random bytes, base64 encoded.
+type KeyConfig struct {
+	ID string `yaml:"id"`
+	// File holding the key.
+	File string `yaml:"file,omitempty"`
+	// Env names the environment variable holding the key.
+	Env string `yaml:"env,omitempty"`
+}
+
+type Config struct {
+	// Key encrypts new secrets. Without a key, secrets are stored in plaintext.
+	Key *KeyConfig `yaml:"key,omitempty"`
+	// Previous keys only decrypt secrets written before a key rotation.
+	Previous []KeyConfig `yaml:"previous,omitempty"`
+}
+
+var (
+	DefaultConfig = Config{}
+)
+
+// Sealed is a value encrypted with its own data key. The data key is encrypted with
+// the master key named by KeyID. Nonces prefix both ciphertexts.
+type Sealed struct {
+	KeyID   string
+	DataKey []byte
+	Data    []byte
+}
+
+// Keyring holds the master keys used for envelope encryption with AES-GCM.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+func NewKeyring(cfg Config) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+
+	keys := cfg.Previous
+	if cfg.Key != nil {
+		k.current = cfg.Key.ID
+		keys = append([]KeyConfig{*cfg.Key}, keys...)
+	}
+
+	for _, kc := range keys {
+		if kc.ID == "" {
+			return nil, errors.New("master key needs an id")
+		}
+		if _, exists := k.keys[kc.ID]; exists {
+			return nil, fmt.Errorf("master key %s is configured twice", kc.ID)
+		}
+
+		key, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("master key %s: %w", kc.ID, err)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("master key %s: %w", kc.ID, err)
+		}
+		k.keys[kc.ID] = aead
+	}
+	return k, nil
+}
+
+func loadKey(kc KeyConfig) ([]byte, error) {
+	var encoded string
+	switch {
+	case kc.File != "" && kc.Env != "":
+		return nil, errors.New("file and env are mutually exclusive")
+	case kc.File != "":
+		content, err := os.ReadFile(kc.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %v", err)
+		}
+		encoded = string(content)
+	case kc.Env != "":
+		encoded = os.Getenv(kc.Env)
+		if encoded == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", kc.Env)
+		}
+	default:
+		return nil, errors.New("key needs a file or an env")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key is not base64 encoded: %v", err)
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Enabled reports whether new secrets are encrypted.
+func (k *Keyring) Enabled() bool {
+	return k.current != ""
+}
+
+// Has reports whether the master key is configured.
+func (k *Keyring) Has(keyID string) bool {
+	_, exists := k.keys[keyID]
+	return exists
+}
+
+// Seal encrypts the plaintext with a new data key under the current master key.
+// The additional data binds the value to its owner and must be given to Open.
+func (k *Keyring) Seal(plaintext []byte, additionalData []byte) (*Sealed, error) {
+	if !k.Enabled() {
+		return nil, ErrNoKey
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %v", err)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sealedData, err := seal(data, plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	keyID, sealedKey, err := k.WrapKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealed{KeyID: keyID, DataKey: sealedKey, Data: sealedData}, nil
+}
+
+// Open decrypts a value sealed under any of the configured master keys.
+func (k *Keyring) Open(s *Sealed, additionalData []byte) ([]byte, error) {
+	dataKey, err := k.UnwrapKey(s.KeyID, s.DataKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := open(data, s.Data, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %v", err)
+	}
+	return plaintext, nil
+}
+
+// WrapKey encrypts a data key under the current master key and returns the id of the key.
+func (k *Keyring) WrapKey(dataKey []byte) (string, []byte, error) {
+	if !k.Enabled() {
+		return "", nil, ErrNoKey
+	}
+
+	wrapped, err := seal(k.keys[k.current], dataKey, []byte(k.current))
+	if err != nil {
+		return "", nil, err
+	}
+	return k.current, wrapped, nil
+}
+
+// UnwrapKey decrypts a data key wrapped under any of the configured master keys.
+func (k *Keyring) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	master, exists := k.keys[keyID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	dataKey, err := open(master, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %v", err)
+	}
+	return dataKey, nil
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
@@ -0,0 +1,183 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeKey writes a new random master key of the given size and returns its config.
+func writeKey(t *testing.T, id string, size int) KeyConfig {
+	t.Helper()
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(file, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return KeyConfig{ID: id, File: file}
+}
+
+func TestNewKeyring(t *testing.T) {
+	t.Setenv("GORYNYCH_TEST_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	t.Setenv("GORYNYCH_TEST_EMPTY", "")
+
+	badBase64 := filepath.Join(t.TempDir(), "bad")
+	if err := os.WriteFile(badBase64, []byte("not base64!"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "no key", cfg: Config{}},
+		{name: "key from file", cfg: Config{Key: ptr(writeKey(t, "a", 32))}},
+		{name: "key from env", cfg: Config{Key: &KeyConfig{ID: "a", Env: "GORYNYCH_TEST_KEY"}}},
+		{name: "previous keys only", cfg: Config{Previous: []KeyConfig{writeKey(t, "a", 16)}}},
+		{name: "key of 24 bytes", cfg: Config{Key: ptr(writeKey(t, "a", 24))}},
+		{name: "missing id", cfg: Config{Key: ptr(writeKey(t, "", 32))}, wantErr: true},
+		{name: "same id twice", cfg: Config{Key: ptr(writeKey(t, "a", 32)), Previous: []KeyConfig{writeKey(t, "a", 32)}}, wantErr: true},
+		{name: "file and env", cfg: Config{Key: &KeyConfig{ID: "a", File: badBase64, Env: "GORYNYCH_TEST_KEY"}}, wantErr: true},
+		{name: "neither file nor env", cfg: Config{Key: &KeyConfig{ID: "a"}}, wantErr: true},
+		{name: "missing file", cfg: Config{Key: &KeyConfig{ID: "a", File: filepath.Join(t.TempDir(), "missing")}}, wantErr: true},
+		{name: "empty env", cfg: Config{Key: &KeyConfig{ID: "a", Env: "GORYNYCH_TEST_EMPTY"}}, wantErr: true},
+		{name: "not base64", cfg: Config{Key: &KeyConfig{ID: "a", File: badBase64}}, wantErr: true},
+		{name: "key of a wrong size", cfg: Config{Key: ptr(writeKey(t, "a", 20))}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKeyring() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	current, previous := writeKey(t, "current", 32), writeKey(t, "previous", 32)
+	keyring, err := NewKeyring(Config{Key: &current, Previous: []KeyConfig{previous}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("secret access key")
+	additionalData := []byte("provider-1")
+	sealed, err := keyring.Seal(plaintext, additionalData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed.KeyID != "current" {
+		t.Fatalf("KeyID = %s, want current", sealed.KeyID)
+	}
+
+	flip := func(data []byte, i int) []byte {
+		tampered := bytes.Clone(data)
+		tampered[i] ^= 1
+		return tampered
+	}
+
+	tests := []struct {
+		name           string
+		sealed         Sealed
+		additionalData []byte
+		wantErr        bool
+		// errIs is the error expected when one is wanted, if a specific one.
+		errIs error
+	}{
+		{name: "same additional data", sealed: *sealed, additionalData: additionalData},
+		{name: "unknown key id", sealed: Sealed{KeyID: "other", DataKey: sealed.DataKey, Data: sealed.Data}, additionalData: additionalData, wantErr: true, errIs: ErrUnknownKey},
+		{name: "id of another configured key", sealed: Sealed{KeyID: "previous", DataKey: sealed.DataKey, Data: sealed.Data}, additionalData: additionalData, wantErr: true},
+		{name: "other additional data", sealed: *sealed, additionalData: []byte("provider-2"), wantErr: true},
+		{name: "no additional data", sealed: *sealed, wantErr: true},
+		{name: "tampered data", sealed: Sealed{KeyID: sealed.KeyID, DataKey: sealed.DataKey, Data: flip(sealed.Data, len(sealed.Data)-1)}, additionalData: additionalData, wantErr: true},
+		{name: "tampered data key", sealed: Sealed{KeyID: sealed.KeyID, DataKey: flip(sealed.DataKey, len(sealed.DataKey)-1), Data: sealed.Data}, additionalData: additionalData, wantErr: true},
+		{name: "truncated data", sealed: Sealed{KeyID: sealed.KeyID, DataKey: sealed.DataKey, Data: sealed.Data[:4]}, additionalData: additionalData, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keyring.Open(&tt.sealed, tt.additionalData)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Open() succeeded")
+				}
+				if tt.errIs != nil && !errors.Is(err, tt.errIs) {
+					t.Fatalf("Open() error = %v, want %v", err, tt.errIs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("Open() = %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old, next := writeKey(t, "old", 32), writeKey(t, "new", 32)
+
+	before, err := NewKeyring(Config{Key: &old})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := before.Seal([]byte("value"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewKeyring(Config{Key: &next, Previous: []KeyConfig{old}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := rotated.Open(sealed, nil); err != nil || string(got) != "value" {
+		t.Fatalf("Open() with the previous key = %q, %v", got, err)
+	}
+	resealed, err := rotated.Seal([]byte("value"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resealed.KeyID != "new" {
+		t.Fatalf("KeyID after rotation = %s, want new", resealed.KeyID)
+	}
+
+	dropped, err := NewKeyring(Config{Key: &next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dropped.Open(sealed, nil); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Open() without the previous key error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestNoKey(t *testing.T) {
+	keyring, err := NewKeyring(Config{Previous: []KeyConfig{writeKey(t, "old", 32)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyring.Enabled() {
+		t.Fatal("Enabled() with previous keys only")
+	}
+	if _, err := keyring.Seal([]byte("value"), nil); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("Seal() error = %v, want %v", err, ErrNoKey)
+	}
+	if _, _, err := keyring.WrapKey(make([]byte, 32)); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("WrapKey() error = %v, want %v", err, ErrNoKey)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}